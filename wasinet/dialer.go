@@ -10,10 +10,14 @@ import (
 
 	"github.com/egdaemon/wasinet/wasinet/ffierrors"
 	"github.com/egdaemon/wasinet/wasinet/internal/errorsx"
+	"github.com/egdaemon/wasinet/wasinet/internal/langx"
 	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1net"
 	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 )
 
+// aLongTimeAgo is a non-zero time, far in the past, used for immediate cancelation of dials.
+var aLongTimeAgo = time.Unix(1, 0)
+
 // Dialer is a type similar to net.Dialer but it uses the dial functions defined
 // in this package instead of those from the standard library.
 //
@@ -25,14 +29,16 @@ type Dialer struct {
 	Timeout        time.Duration
 	Deadline       time.Time
 	LocalAddr      net.Addr
-	DualStack      bool
-	FallbackDelay  time.Duration
+	DualStack      bool            // deprecated, tcp addresses of both ip families are raced unless FallbackDelay is negative.
+	FallbackDelay  time.Duration   // delay before racing the other ip family, defaults to 300ms.
 	Resolver       *net.Resolver   // ignored
 	Cancel         <-chan struct{} // ignored
 	Control        func(network, address string, c syscall.RawConn) error
 	ControlContext func(ctx context.Context, network, address string, c syscall.RawConn) error
 }
 
+const defaultFallbackDelay = 300 * time.Millisecond
+
 func (d *Dialer) Dial(network, address string) (net.Conn, error) {
 	return d.DialContext(context.Background(), network, address)
 }
@@ -49,27 +55,15 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
-	if d.LocalAddr != nil {
-		log.Println("wasip1.Dialer: LocalAddr not yet supported on GOOS=wasip1")
-	}
 	if d.Resolver != nil {
 		log.Println("wasip1.Dialer: Resolver ignored because it is not supported on GOOS=wasip1")
 	}
 	if d.Cancel != nil {
 		log.Println("wasip1.Dialer: Cancel channel not implemented on GOOS=wasip1")
 	}
-	if d.Control != nil {
-		log.Println("wasip1.Dialer: Control function not yet supported on GOOS=wasip1")
-	}
-	if d.ControlContext != nil {
-		log.Println("wasip1.Dialer: ControlContext function not yet supported on GOOS=wasip1")
-	}
 	// TOOD:
-	// - use LocalAddr to bind to a socket prior to establishing the connection
-	// - use DualStack and FallbackDelay
-	// - use Control and ControlContext functions
 	// - emulate the Cancel channel with context.Context
-	return DialContext(ctx, network, address)
+	return d.dial(ctx, network, address)
 }
 
 // Dial connects to the address on the named network.
@@ -79,14 +73,113 @@ func Dial(network, address string) (net.Conn, error) {
 
 // DialContext is a variant of Dial that accepts a context.
 func DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return (&Dialer{}).dial(ctx, network, address)
+}
+
+// dial resolves the address and connects to it, for tcp networks ip4 and ip6
+// addresses are raced against each other (RFC 8305).
+func (d *Dialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
 	addrs, err := wasip1syscall.LookupAddress(ctx, opdial, network, address)
 	if err != nil {
 		return nil, netOpErr(opdial, unresolvedaddr(network, address), err)
 	}
 
-	for _, addr := range addrs {
-		var conn net.Conn
-		conn, err = dialAddr(ctx, addr)
+	primaries, fallbacks := addrs, []net.Addr(nil)
+	if network == "tcp" && d.FallbackDelay >= 0 {
+		primaries, fallbacks = partition(addrs)
+	}
+
+	var conn net.Conn
+	if len(fallbacks) > 0 {
+		conn, err = d.dialParallel(ctx, primaries, fallbacks)
+	} else {
+		conn, err = d.dialSerial(ctx, primaries)
+	}
+
+	return conn, netOpErr(opdial, unresolvedaddr(network, address), err)
+}
+
+// dialParallel races the primary addresses against the fallbacks, the fallbacks
+// are started once the fallback delay elapses or the primaries fail. the first
+// connection established wins and the remaining attempts are cancelled.
+func (d *Dialer) dialParallel(ctx context.Context, primaries, fallbacks []net.Addr) (net.Conn, error) {
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+		done    bool
+	}
+
+	results := make(chan result)
+	returned := make(chan struct{})
+	defer close(returned)
+
+	race := func(ctx context.Context, primary bool) {
+		addrs := fallbacks
+		if primary {
+			addrs = primaries
+		}
+
+		conn, err := d.dialSerial(ctx, addrs)
+		select {
+		case results <- result{conn: conn, err: err, primary: primary, done: true}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	var primary, fallback result
+
+	pctx, pcancel := context.WithCancel(ctx)
+	defer pcancel()
+	go race(pctx, true)
+
+	fctx, fcancel := context.WithCancel(ctx)
+	defer fcancel()
+
+	delay := time.NewTimer(langx.DefaultIfZero(defaultFallbackDelay, d.FallbackDelay))
+	defer delay.Stop()
+
+	for {
+		select {
+		case <-delay.C:
+			go race(fctx, false)
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+
+			if res.primary {
+				primary = res
+			} else {
+				fallback = res
+			}
+
+			if primary.done && fallback.done {
+				return nil, primary.err
+			}
+
+			if res.primary && delay.Stop() {
+				// the primaries failed before the delay elapsed, start the fallbacks immediately.
+				delay.Reset(0)
+			}
+		}
+	}
+}
+
+// dialSerial connects to each address in turn until one succeeds.
+func (d *Dialer) dialSerial(ctx context.Context, addrs []net.Addr) (conn net.Conn, err error) {
+	for i, addr := range addrs {
+		var local net.Addr
+		if local, err = localaddr(d.LocalAddr, addr); err != nil {
+			continue
+		}
+
+		dctx, cancel := partialDeadline(ctx, len(addrs)-i)
+		conn, err = d.dialAddr(dctx, local, addr)
+		cancel()
 		if err == nil {
 			return conn, nil
 		}
@@ -96,10 +189,113 @@ func DialContext(ctx context.Context, network, address string) (net.Conn, error)
 		}
 	}
 
-	return nil, netOpErr(opdial, unresolvedaddr(network, address), err)
+	return nil, err
 }
 
-func dialAddr(ctx context.Context, addr net.Addr) (_ net.Conn, err error) {
+// partialDeadline splits the time remaining until the context's deadline between
+// the remaining addresses, an unresponsive address cannot consume all of it.
+func partialDeadline(ctx context.Context, remaining int) (context.Context, context.CancelFunc) {
+	const minimum = 2 * time.Second
+
+	deadline, ok := ctx.Deadline()
+	if !ok || remaining <= 1 {
+		return context.WithCancel(ctx)
+	}
+
+	until := time.Until(deadline)
+	timeout := until / time.Duration(remaining)
+	if timeout < minimum {
+		timeout = min(minimum, until)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// partition the addresses into those sharing the family of the first address and the remainder.
+func partition(addrs []net.Addr) (primaries, fallbacks []net.Addr) {
+	ip4 := func(addr net.Addr) bool {
+		switch a := addr.(type) {
+		case *net.TCPAddr:
+			return a.IP.To4() != nil
+		case *net.UDPAddr:
+			return a.IP.To4() != nil
+		case *net.IPAddr:
+			return a.IP.To4() != nil
+		default:
+			return false
+		}
+	}
+
+	for _, addr := range addrs {
+		if ip4(addr) == ip4(addrs[0]) {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+
+	return primaries, fallbacks
+}
+
+// localaddr determines the address to bind to when connecting to the remote address.
+// a local address without an ip binds to the unspecified address of the remote's family.
+func localaddr(laddr, raddr net.Addr) (net.Addr, error) {
+	if laddr == nil {
+		return nil, nil
+	}
+
+	ipbind := func(local, remote net.IP) (net.IP, error) {
+		switch {
+		case len(local) == 0 && remote.To4() != nil:
+			return net.IPv4zero, nil
+		case len(local) == 0:
+			return net.IPv6unspecified, nil
+		case (local.To4() == nil) != (remote.To4() == nil):
+			return nil, &net.AddrError{Err: "no suitable address found", Addr: laddr.String()}
+		default:
+			return local, nil
+		}
+	}
+
+	switch r := raddr.(type) {
+	case *net.TCPAddr:
+		if l, ok := laddr.(*net.TCPAddr); ok {
+			ip, err := ipbind(l.IP, r.IP)
+			if err != nil {
+				return nil, err
+			}
+			return &net.TCPAddr{IP: ip, Port: l.Port, Zone: l.Zone}, nil
+		}
+	case *net.UDPAddr:
+		if l, ok := laddr.(*net.UDPAddr); ok {
+			ip, err := ipbind(l.IP, r.IP)
+			if err != nil {
+				return nil, err
+			}
+			return &net.UDPAddr{IP: ip, Port: l.Port, Zone: l.Zone}, nil
+		}
+	case *net.UnixAddr:
+		if l, ok := laddr.(*net.UnixAddr); ok {
+			return l, nil
+		}
+	}
+
+	return nil, &net.AddrError{Err: "mismatched local address type", Addr: laddr.String()}
+}
+
+// control invokes the dialer's control hooks against the newly created socket.
+func (d *Dialer) control(ctx context.Context, addr net.Addr, fd int) error {
+	switch {
+	case d.ControlContext != nil:
+		return d.ControlContext(ctx, ctrlnetwork(addr), addr.String(), rawsocket(fd))
+	case d.Control != nil:
+		return d.Control(ctrlnetwork(addr), addr.String(), rawsocket(fd))
+	default:
+		return nil
+	}
+}
+
+func (d *Dialer) dialAddr(ctx context.Context, laddr net.Addr, addr net.Addr) (_ net.Conn, err error) {
 	defer func() {
 		if err == nil {
 			return
@@ -118,7 +314,7 @@ func dialAddr(ctx context.Context, addr net.Addr) (_ net.Conn, err error) {
 	}
 	defer func() {
 		if fd >= 0 {
-			syscall.Close(fd)
+			wasip1syscall.Close(fd)
 		}
 	}()
 
@@ -128,6 +324,21 @@ func dialAddr(ctx context.Context, addr net.Addr) (_ net.Conn, err error) {
 		}
 	}
 
+	if err := d.control(ctx, addr, fd); err != nil {
+		return nil, err
+	}
+
+	if laddr != nil {
+		baddr, err := wasip1syscall.NetaddrToRaw(af, sotype, laddr)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := wasip1syscall.Bind(fd, baddr); err != nil {
+			return nil, err
+		}
+	}
+
 	caddr, err := wasip1syscall.NetaddrToRaw(af, sotype, addr)
 	if err != nil {
 		return nil, err
@@ -180,19 +391,19 @@ func dialAddr(ctx context.Context, addr net.Addr) (_ net.Conn, err error) {
 				return true // done
 			}
 
-			switch ffierrors.Errno(err) {
-			case syscall.EINPROGRESS, syscall.EINTR:
+			switch errno := syscall.Errno(value); errno {
+			case syscall.EINPROGRESS, syscall.EALREADY, syscall.EINTR:
 				return false // continue
 			case syscall.EISCONN:
-				err = nil
 				return true
 			case ffierrors.ErrnoSuccess():
 				// The net poller can wake up spuriously. Check that we are
-				// are really connected.
-				_, err := wasip1syscall.Getpeername(int(fd))
-				return err == nil
+				// are really connected. the raw address is used to avoid
+				// allocating while the connect is pending.
+				_, perr := wasip1syscall.GetpeernameRaw(int(fd))
+				return perr == nil
 			default:
-				err = syscall.Errno(value)
+				err = errno
 				return true
 			}
 		})
@@ -202,13 +413,14 @@ func dialAddr(ctx context.Context, addr net.Addr) (_ net.Conn, err error) {
 	select {
 	case err := <-errch:
 		if err != nil {
-			sconn.Close()
 			return nil, os.NewSyscallError("connect", err)
 		}
 	case <-ctx.Done():
-		// This should interrupt the async connect operation handled by the
-		// goroutine.
-		sconn.Close()
+		// interrupt the async connect operation handled by the goroutine,
+		// the descriptor is closed once the goroutine has stopped using it.
+		if err := sconn.SetWriteDeadline(aLongTimeAgo); err != nil {
+			_ = sconn.Close()
+		}
 		// Wait for the goroutine to complete, we can safely discard the
 		// error here because we don't care about the socket anymore.
 		<-errch
@@ -0,0 +1,49 @@
+package wasinet
+
+import (
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/internal/langx"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+type dnscache struct {
+	size     int
+	negative time.Duration
+}
+
+type DNSCacheOption func(*dnscache)
+
+// OptionDNSCacheSize the maximum number of names retained, defaults to 256.
+func OptionDNSCacheSize(n int) DNSCacheOption {
+	return func(c *dnscache) {
+		c.size = n
+	}
+}
+
+// OptionDNSCacheNegativeTTL how long names that do not exist are retained, zero
+// disables negative caching. defaults to 5 seconds.
+func OptionDNSCacheNegativeTTL(d time.Duration) DNSCacheOption {
+	return func(c *dnscache) {
+		c.negative = d
+	}
+}
+
+// EnableDNSCache caches the addresses names resolve to within the guest, avoiding
+// a host round trip for every dial. addresses are retained for the lifetime the
+// host reports, by default hosts report zero and nothing is cached. enabling
+// the cache again replaces the existing cache.
+func EnableDNSCache(opts ...DNSCacheOption) {
+	c := langx.Clone(dnscache{size: 256, negative: 5 * time.Second}, opts...)
+	wasip1syscall.SetDNSCache(wasip1syscall.NewDNSCache(c.size, c.negative))
+}
+
+// DisableDNSCache discards the cache, every lookup is resolved by the host.
+func DisableDNSCache() {
+	wasip1syscall.SetDNSCache(nil)
+}
+
+// FlushDNSCache discards every cached name, a noop when the cache is disabled.
+func FlushDNSCache() {
+	wasip1syscall.FlushDNSCache()
+}
@@ -1,7 +1,10 @@
 package ffi
 
 import (
+	"context"
+	"math"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -20,11 +23,17 @@ type Memory interface {
 	WriteUint32Le(offset unsafe.Pointer, v uint32) bool
 }
 
+// SliceRead reads dlen elements of T, not dlen bytes. the entire
+// dlen * sizeof(T) range is bounds checked against the memory.
 func SliceRead[T any](m Memory, offset unsafe.Pointer, dlen uint32) (zero []T, err error) {
-	if binary, ok := m.Read(offset, dlen); !ok {
+	if dlen == 0 {
+		return zero, nil
+	}
+
+	if binary, ok := m.Read(offset, dlen*uint32(unsafe.Sizeof(*new(T)))); !ok {
 		return zero, syscall.EFAULT
 	} else {
-		return *(*[]T)(unsafe.Pointer(&binary)), nil
+		return unsafe.Slice((*T)(unsafe.Pointer(unsafe.SliceData(binary))), dlen), nil
 	}
 }
 
@@ -51,8 +60,14 @@ func RawRead(m Memory, dm Memory, dst unsafe.Pointer, ptr unsafe.Pointer, dlen u
 	}
 }
 
+// RawWrite copies v into dst, dlen is the size of the destination and
+// must be large enough to hold v.
 func RawWrite[T any](m Memory, v *T, dst unsafe.Pointer, dlen uint32) error {
 	sz := unsafe.Sizeof(*v)
+	if uintptr(dlen) < sz {
+		return syscall.EINVAL
+	}
+
 	ptr := (*byte)(unsafe.Pointer(v))
 	bytes := unsafe.Slice(ptr, sz)
 	if !m.Write(dst, bytes) {
@@ -81,7 +96,13 @@ func Uint32Write(m Memory, dst unsafe.Pointer, v uint32) error {
 	return nil
 }
 
+// BytesWrite copies v into dst, dlen is the size of the destination and
+// must be large enough to hold v.
 func BytesWrite(m Memory, v []byte, dst unsafe.Pointer, dlen uint32) error {
+	if uint32(len(v)) > dlen {
+		return syscall.EINVAL
+	}
+
 	if !m.Write(dst, v) {
 		return syscall.EFAULT
 	}
@@ -159,3 +180,13 @@ func String(s string) (unsafe.Pointer, uint32) {
 func Slice[T any](d []T) (unsafe.Pointer, uint32) {
 	return unsafe.Pointer(unsafe.SliceData(d)), uint32(len(d))
 }
+
+// ContextDeadline derives a context bounded by the deadline the guest provided
+// via ffiguest.ContextDeadline, math.MaxInt64 indicates the guest had no deadline.
+func ContextDeadline(ctx context.Context, deadline int64) (context.Context, context.CancelFunc) {
+	if deadline == math.MaxInt64 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, time.UnixMicro(deadline))
+}
@@ -1,9 +1,14 @@
 package ffi_test
 
 import (
+	"context"
+	"syscall"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/egdaemon/wasinet/wasinet/ffi"
+	"github.com/egdaemon/wasinet/wasinet/ffiguest"
 	"github.com/egdaemon/wasinet/wasinet/internal/bytesx"
 	"github.com/stretchr/testify/require"
 )
@@ -27,6 +32,25 @@ func TestRawWrite(t *testing.T) {
 	require.Equal(t, int32(512), exv2.Foo)
 }
 
+func TestWriteBounds(t *testing.T) {
+	type m struct {
+		Foo int32
+		Bar int32
+	}
+
+	var (
+		dst [8]byte
+	)
+
+	dstptr, _ := ffi.Pointer(&dst)
+	require.ErrorIs(t, ffi.RawWrite(ffi.Native{}, &m{Foo: 1, Bar: 2}, dstptr, 4), syscall.EINVAL)
+	require.ErrorIs(t, ffi.BytesWrite(ffi.Native{}, []byte{1, 2, 3, 4, 5}, dstptr, 4), syscall.EINVAL)
+	require.Equal(t, [8]byte{}, dst)
+
+	require.NoError(t, ffi.BytesWrite(ffi.Native{}, []byte{1, 2, 3, 4}, dstptr, 4))
+	require.Equal(t, [8]byte{1, 2, 3, 4}, dst)
+}
+
 func TestUint32(t *testing.T) {
 	var (
 		ex uint32
@@ -64,3 +88,58 @@ func TestReadSliceBytes(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, ex, result, "%+v vs %+v", bytesx.Debug(ex[0]), bytesx.Debug(result[0]))
 }
+
+func TestContextDeadline(t *testing.T) {
+	ctx, done := ffi.ContextDeadline(context.Background(), ffiguest.ContextDeadline(context.Background()))
+	defer done()
+	_, ok := ctx.Deadline()
+	require.False(t, ok)
+
+	deadline := time.Now().Add(time.Minute).Truncate(time.Microsecond)
+	guest, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ctx, done = ffi.ContextDeadline(context.Background(), ffiguest.ContextDeadline(guest))
+	defer done()
+	ts, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.True(t, deadline.Equal(ts))
+}
+
+// boundedmemory fails reads larger than limit.
+type boundedmemory struct {
+	ffi.Native
+	limit uint32
+}
+
+func (t boundedmemory) Read(offset unsafe.Pointer, byteCount uint32) ([]byte, bool) {
+	if byteCount > t.limit {
+		return nil, false
+	}
+
+	return t.Native.Read(offset, byteCount)
+}
+
+func TestSliceReadElements(t *testing.T) {
+	var (
+		src = [4]uint32{1, 2, 3, 4}
+	)
+
+	srcptr, _ := ffi.Pointer(&src)
+
+	// the length is a count of elements, not bytes.
+	v, err := ffi.SliceRead[uint32](ffi.Native{}, srcptr, 4)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2, 3, 4}, v)
+
+	// the whole slice is bounds checked.
+	_, err = ffi.SliceRead[uint32](boundedmemory{limit: 8}, srcptr, 4)
+	require.ErrorIs(t, err, syscall.EFAULT)
+	v, err = ffi.SliceRead[uint32](boundedmemory{limit: 8}, srcptr, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2}, v)
+
+	v, err = ffi.SliceRead[uint32](ffi.Native{}, srcptr, 0)
+	require.NoError(t, err)
+	require.Empty(t, v)
+}
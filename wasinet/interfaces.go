@@ -0,0 +1,74 @@
+package wasinet
+
+import (
+	"net"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+// Interfaces returns the network interfaces the host exposes, the host
+// may hide interfaces from the guest.
+//
+// For details, see: https://pkg.go.dev/net#Interfaces
+func Interfaces() ([]net.Interface, error) {
+	ifaces, err := wasip1syscall.Interfaces()
+	if err != nil {
+		return nil, &net.OpError{Op: "route", Net: "ip+net", Err: err}
+	}
+
+	ift := make([]net.Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		ift = append(ift, iface.Net())
+	}
+
+	return ift, nil
+}
+
+// InterfaceByName returns the interface specified by name.
+//
+// For details, see: https://pkg.go.dev/net#InterfaceByName
+func InterfaceByName(name string) (*net.Interface, error) {
+	iface, err := wasip1syscall.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ifi := iface.Net()
+	return &ifi, nil
+}
+
+// InterfaceByIndex returns the interface specified by index.
+//
+// For details, see: https://pkg.go.dev/net#InterfaceByIndex
+func InterfaceByIndex(index int) (*net.Interface, error) {
+	iface, err := wasip1syscall.InterfaceByIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	ifi := iface.Net()
+	return &ifi, nil
+}
+
+// InterfaceAddrs returns the unicast addresses of the interface, a nil
+// interface returns the addresses of every interface. unlike (*net.Interface).Addrs
+// this works within wasi guests.
+//
+// For details, see: https://pkg.go.dev/net#InterfaceAddrs
+func InterfaceAddrs(ifi *net.Interface) ([]net.Addr, error) {
+	ifaces, err := wasip1syscall.Interfaces()
+	if err != nil {
+		return nil, &net.OpError{Op: "route", Net: "ip+net", Err: err}
+	}
+
+	addrs := []net.Addr(nil)
+	for _, iface := range ifaces {
+		if ifi != nil && ifi.Index != iface.Index {
+			continue
+		}
+
+		addrs = append(addrs, iface.NetAddrs()...)
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,38 @@
+package wasinet_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/egdaemon/wasinet/wasinet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaces(t *testing.T) {
+	ifaces, err := wasinet.Interfaces()
+	require.NoError(t, err)
+
+	var lo *net.Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			lo = &iface
+			break
+		}
+	}
+	require.NotNil(t, lo, "expected a loopback interface")
+
+	byname, err := wasinet.InterfaceByName(lo.Name)
+	require.NoError(t, err)
+	require.Equal(t, lo.Index, byname.Index)
+
+	byindex, err := wasinet.InterfaceByIndex(lo.Index)
+	require.NoError(t, err)
+	require.Equal(t, lo.Name, byindex.Name)
+
+	addrs, err := wasinet.InterfaceAddrs(lo)
+	require.NoError(t, err)
+	require.Contains(t, addrs, net.Addr(&net.IPNet{IP: net.IPv4(127, 0, 0, 1).To4(), Mask: net.CIDRMask(8, 32)}))
+
+	_, err = wasinet.InterfaceByName("wasinet-missing")
+	require.Error(t, err)
+}
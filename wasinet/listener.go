@@ -6,13 +6,42 @@ import (
 	"net"
 	"os"
 	"syscall"
+	"time"
 
+	"github.com/egdaemon/wasinet/wasinet/internal/langx"
 	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1net"
 	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 )
 
+// ListenConfig is a type similar to net.ListenConfig but it uses the listen functions
+// defined in this package instead of those from the standard library.
+//
+// For details about the configuration, see: https://pkg.go.dev/net#ListenConfig
+//
+// tcp listeners apply keep-alive while accepting, outside of wasi listeners with
+// a non default keep-alive configuration are wrapped and aren't a *net.TCPListener.
+type ListenConfig struct {
+	Control         func(network, address string, c syscall.RawConn) error
+	KeepAlive       time.Duration       // keep-alive period of accepted tcp connections, zero uses the defaults and negative disables them.
+	KeepAliveConfig net.KeepAliveConfig // takes precedence over KeepAlive when enabled.
+	Backlog         int                 // maximum length of the queue of pending connections, defaults to 64.
+	ReusePort       bool                // allow multiple sockets to bind the same address (SO_REUSEPORT).
+}
+
+const defaultBacklog = 64
+
 // Listen announces on the local network address.
 func Listen(ctx context.Context, network, address string) (net.Listener, error) {
+	return (&ListenConfig{}).Listen(ctx, network, address)
+}
+
+// ListenPacket creates a listening packet connection.
+func ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
+	return (&ListenConfig{}).ListenPacket(ctx, network, address)
+}
+
+// Listen announces on the local network address.
+func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (net.Listener, error) {
 	switch network {
 	case "tcp", "tcp4", "tcp6", "unix":
 	default:
@@ -25,12 +54,16 @@ func Listen(ctx context.Context, network, address string) (net.Listener, error)
 	}
 
 	firstaddr := addrs[0]
-	lstn, err := listenAddr(firstaddr)
-	return lstn, netOpErr(oplisten, firstaddr, err)
+	lstn, err := lc.listenAddr(firstaddr)
+	if err != nil {
+		return nil, netOpErr(oplisten, firstaddr, err)
+	}
+
+	return lstn, nil
 }
 
 // ListenPacket creates a listening packet connection.
-func ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
+func (lc *ListenConfig) ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
 	switch network {
 	case "udp", "udp4", "udp6", "unixgram":
 	default:
@@ -41,7 +74,7 @@ func ListenPacket(ctx context.Context, network, address string) (net.PacketConn,
 		return nil, netOpErr(oplisten, unresolvedaddr(network, address), err)
 	}
 
-	conn, err := listenPacketAddr(addrs[0])
+	conn, err := lc.listenPacketAddr(addrs[0])
 	return conn, netOpErr(oplisten, addrs[0], err)
 }
 
@@ -49,7 +82,28 @@ func unsupportedNetwork(network, address string) error {
 	return fmt.Errorf("unsupported network: %s://%s", network, address)
 }
 
-func listenAddr(addr net.Addr) (net.Listener, error) {
+// keepalive the configuration applied to accepted connections.
+func (lc *ListenConfig) keepalive() net.KeepAliveConfig {
+	switch {
+	case lc.KeepAliveConfig.Enable:
+		return lc.KeepAliveConfig
+	case lc.KeepAlive < 0:
+		return net.KeepAliveConfig{}
+	default:
+		return net.KeepAliveConfig{Enable: true, Idle: lc.KeepAlive}
+	}
+}
+
+// control invokes the control hook against the newly created socket.
+func (lc *ListenConfig) control(addr net.Addr, fd int) error {
+	if lc.Control == nil {
+		return nil
+	}
+
+	return lc.Control(ctrlnetwork(addr), addr.String(), rawsocket(fd))
+}
+
+func (lc *ListenConfig) listenAddr(addr net.Addr) (net.Listener, error) {
 	af := wasip1syscall.NetaddrAFFamily(addr)
 	sotype, err := socketType(addr)
 	if err != nil {
@@ -61,7 +115,7 @@ func listenAddr(addr net.Addr) (net.Listener, error) {
 	}
 	defer func() {
 		if fd >= 0 {
-			syscall.Close(fd)
+			wasip1syscall.Close(fd)
 		}
 	}()
 
@@ -69,6 +123,16 @@ func listenAddr(addr net.Addr) (net.Listener, error) {
 		return nil, err
 	}
 
+	if lc.ReusePort {
+		if err := wasip1syscall.SetReusePort(fd); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := lc.control(addr, fd); err != nil {
+		return nil, err
+	}
+
 	baddr, err := wasip1syscall.NetaddrToRaw(af, sotype, addr)
 	if err != nil {
 		return nil, os.NewSyscallError("raw address", err)
@@ -78,12 +142,11 @@ func listenAddr(addr net.Addr) (net.Listener, error) {
 		return nil, err
 	}
 
-	const backlog = 64 // TODO: configurable?
-	if err := wasip1syscall.Listen(fd, backlog); err != nil {
+	if err := wasip1syscall.Listen(fd, langx.DefaultIfZero(defaultBacklog, lc.Backlog)); err != nil {
 		return nil, err
 	}
 
-	l, err := wasip1net.Listen(af, sotype, uintptr(fd))
+	l, err := wasip1net.ListenKeepAlive(af, sotype, uintptr(fd), lc.keepalive())
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +155,7 @@ func listenAddr(addr net.Addr) (net.Listener, error) {
 	return l, nil
 }
 
-func listenPacketAddr(addr net.Addr) (net.PacketConn, error) {
+func (lc *ListenConfig) listenPacketAddr(addr net.Addr) (net.PacketConn, error) {
 	af := wasip1syscall.NetaddrAFFamily(addr)
 	sotype, err := socketType(addr)
 	if err != nil {
@@ -104,7 +167,7 @@ func listenPacketAddr(addr net.Addr) (net.PacketConn, error) {
 	}
 	defer func() {
 		if fd >= 0 {
-			syscall.Close(fd)
+			wasip1syscall.Close(fd)
 		}
 	}()
 
@@ -112,6 +175,16 @@ func listenPacketAddr(addr net.Addr) (net.PacketConn, error) {
 		return nil, os.NewSyscallError("set_socketopt_int", err)
 	}
 
+	if lc.ReusePort {
+		if err := wasip1syscall.SetReusePort(fd); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := lc.control(addr, fd); err != nil {
+		return nil, err
+	}
+
 	baddr, err := wasip1syscall.NetaddrToRaw(af, sotype, addr)
 	if err != nil {
 		return nil, os.NewSyscallError("bind", err)
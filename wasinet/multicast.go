@@ -0,0 +1,132 @@
+package wasinet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+var errMissingAddress = errors.New("missing address")
+
+// ListenMulticastUDP acts like ListenPacket for udp networks but takes a group address
+// on a specific network interface, a nil interface lets the host choose.
+//
+// For details, see: https://pkg.go.dev/net#ListenMulticastUDP
+func ListenMulticastUDP(ctx context.Context, network string, ifi *net.Interface, gaddr *net.UDPAddr) (net.PacketConn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, unsupportedNetwork(network, gaddr.String())
+	}
+
+	if gaddr == nil || gaddr.IP == nil {
+		return nil, netOpErr(oplisten, gaddr, errMissingAddress)
+	}
+
+	// bsd hosts only deliver a group's datagrams to every listener with SO_REUSEPORT.
+	pc, err := (&ListenConfig{ReusePort: true}).listenPacketAddr(gaddr)
+	if err != nil {
+		return nil, netOpErr(oplisten, gaddr, err)
+	}
+
+	err = control(pc, func(fd int) error {
+		return multicast(fd, ifi, gaddr.IP)
+	})
+	if err != nil {
+		pc.Close()
+		return nil, netOpErr(oplisten, gaddr, err)
+	}
+
+	return pc, nil
+}
+
+// JoinGroup joins the multicast group on the network interface, a nil interface lets the host choose.
+func JoinGroup(c net.PacketConn, ifi *net.Interface, group net.IP) error {
+	return control(c, func(fd int) error {
+		return membership(fd, true, ifi, group)
+	})
+}
+
+// LeaveGroup leaves the multicast group on the network interface.
+func LeaveGroup(c net.PacketConn, ifi *net.Interface, group net.IP) error {
+	return control(c, func(fd int) error {
+		return membership(fd, false, ifi, group)
+	})
+}
+
+// control invokes fn with the connection's file descriptor.
+func control(c any, fn func(fd int) error) (err error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return syscall.EOPNOTSUPP
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	if cerr := raw.Control(func(fd uintptr) { err = fn(int(fd)) }); cerr != nil {
+		return cerr
+	}
+
+	return err
+}
+
+// multicast configures the socket to receive the group's datagrams, like the standard
+// library outgoing datagrams are not looped back.
+func multicast(fd int, ifi *net.Interface, group net.IP) (err error) {
+	if group.To4() != nil {
+		if ifi != nil {
+			if err = wasip1syscall.SetsockoptIPMreqn(fd, wasip1syscall.IP_MULTICAST_IF, wasip1syscall.IPMreqn{Ifindex: int32(ifi.Index)}); err != nil {
+				return err
+			}
+		}
+
+		if err = wasip1syscall.SetSockoptInt(fd, wasip1syscall.IPPROTO_IP, wasip1syscall.IP_MULTICAST_LOOP, 0); err != nil {
+			return err
+		}
+	} else {
+		if ifi != nil {
+			if err = wasip1syscall.SetSockoptInt(fd, wasip1syscall.IPPROTO_IPV6, wasip1syscall.IPV6_MULTICAST_IF, ifi.Index); err != nil {
+				return err
+			}
+		}
+
+		if err = wasip1syscall.SetSockoptInt(fd, wasip1syscall.IPPROTO_IPV6, wasip1syscall.IPV6_MULTICAST_LOOP, 0); err != nil {
+			return err
+		}
+	}
+
+	return membership(fd, true, ifi, group)
+}
+
+func membership(fd int, join bool, ifi *net.Interface, group net.IP) error {
+	ifindex := 0
+	if ifi != nil {
+		ifindex = ifi.Index
+	}
+
+	if ip4 := group.To4(); ip4 != nil {
+		opt := wasip1syscall.IP_ADD_MEMBERSHIP
+		if !join {
+			opt = wasip1syscall.IP_DROP_MEMBERSHIP
+		}
+
+		return wasip1syscall.SetsockoptIPMreqn(fd, opt, wasip1syscall.IPMreqn{Multiaddr: [4]byte(ip4), Ifindex: int32(ifindex)})
+	}
+
+	if ip6 := group.To16(); ip6 != nil {
+		opt := wasip1syscall.IPV6_JOIN_GROUP
+		if !join {
+			opt = wasip1syscall.IPV6_LEAVE_GROUP
+		}
+
+		return wasip1syscall.SetsockoptIPv6Mreq(fd, opt, wasip1syscall.IPv6Mreq{Multiaddr: [16]byte(ip6), Interface: uint32(ifindex)})
+	}
+
+	return &net.AddrError{Err: "invalid multicast group", Addr: group.String()}
+}
@@ -0,0 +1,117 @@
+package wasinet
+
+import (
+	"context"
+	"net"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+// Resolver looks up records using the host's resolver, lookups are subject to
+// the host's dns policy and bounded by the context's deadline. unlike net.Resolver
+// the guest never speaks to a nameserver.
+type Resolver struct{}
+
+// LookupMX returns the DNS MX records for the given domain name sorted by preference.
+//
+// For details, see: https://pkg.go.dev/net#Resolver.LookupMX
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	res, err := wasip1syscall.LookupRecords(ctx, wasip1syscall.DNS_MX, name)
+	if err != nil {
+		return nil, err
+	}
+
+	mx := make([]*net.MX, 0, len(res.Records))
+	for _, r := range res.Records {
+		mx = append(mx, &net.MX{Host: r.Target, Pref: r.Pref})
+	}
+
+	return mx, nil
+}
+
+// LookupTXT returns the DNS TXT records for the given domain name.
+//
+// For details, see: https://pkg.go.dev/net#Resolver.LookupTXT
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	res, err := wasip1syscall.LookupRecords(ctx, wasip1syscall.DNS_TXT, name)
+	if err != nil {
+		return nil, err
+	}
+
+	txt := make([]string, 0, len(res.Records))
+	for _, r := range res.Records {
+		txt = append(txt, r.Target)
+	}
+
+	return txt, nil
+}
+
+// LookupSRV tries to resolve an SRV query of the given service, protocol, and domain name.
+// when service and proto are empty the name is looked up directly.
+//
+// For details, see: https://pkg.go.dev/net#Resolver.LookupSRV
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+
+	res, err := wasip1syscall.LookupRecords(ctx, wasip1syscall.DNS_SRV, target)
+	if err != nil {
+		return "", nil, err
+	}
+
+	srv := make([]*net.SRV, 0, len(res.Records))
+	for _, r := range res.Records {
+		srv = append(srv, &net.SRV{Target: r.Target, Port: r.Port, Priority: r.Priority, Weight: r.Weight})
+	}
+
+	return res.CNAME, srv, nil
+}
+
+// LookupCNAME returns the canonical name for the given host.
+//
+// For details, see: https://pkg.go.dev/net#Resolver.LookupCNAME
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	res, err := wasip1syscall.LookupRecords(ctx, wasip1syscall.DNS_CNAME, host)
+	if err != nil {
+		return "", err
+	}
+
+	return res.CNAME, nil
+}
+
+// LookupNS returns the DNS NS records for the given domain name.
+//
+// For details, see: https://pkg.go.dev/net#Resolver.LookupNS
+func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	res, err := wasip1syscall.LookupRecords(ctx, wasip1syscall.DNS_NS, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := make([]*net.NS, 0, len(res.Records))
+	for _, r := range res.Records {
+		ns = append(ns, &net.NS{Host: r.Target})
+	}
+
+	return ns, nil
+}
+
+// LookupAddr performs a reverse lookup for the given address, returning a list
+// of names mapping to that address.
+//
+// For details, see: https://pkg.go.dev/net#Resolver.LookupAddr
+func (r *Resolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	res, err := wasip1syscall.LookupRecords(ctx, wasip1syscall.DNS_PTR, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(res.Records))
+	for _, r := range res.Records {
+		names = append(names, r.Target)
+	}
+
+	return names, nil
+}
@@ -33,3 +33,43 @@ func socketType(addr net.Addr) (int, error) {
 		return -1, syscall.EPROTOTYPE
 	}
 }
+
+// ctrlnetwork the network reported to control hooks, ip networks include the address family.
+func ctrlnetwork(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return ipnetwork("tcp", a.IP)
+	case *net.UDPAddr:
+		return ipnetwork("udp", a.IP)
+	case *net.IPAddr:
+		return ipnetwork("ip", a.IP)
+	default:
+		return addr.Network()
+	}
+}
+
+func ipnetwork(network string, ip net.IP) string {
+	if ip.To4() != nil {
+		return network + "4"
+	}
+
+	return network + "6"
+}
+
+// rawsocket exposes a socket to control hooks before it is bound or connected.
+type rawsocket int
+
+func (t rawsocket) Control(f func(fd uintptr)) error {
+	f(uintptr(t))
+	return nil
+}
+
+// Read is unsupported until the socket is bound or connected.
+func (t rawsocket) Read(f func(fd uintptr) (done bool)) error {
+	return syscall.EOPNOTSUPP
+}
+
+// Write is unsupported until the socket is bound or connected.
+func (t rawsocket) Write(f func(fd uintptr) (done bool)) error {
+	return syscall.EOPNOTSUPP
+}
@@ -3,6 +3,7 @@ package wasip1net
 import (
 	"io"
 	"net"
+	"syscall"
 )
 
 type innerpconn interface {
@@ -16,3 +17,12 @@ type pconn struct {
 func makePacketConn(pc innerpconn) *pconn {
 	return &pconn{innerpconn: pc}
 }
+
+// SyscallConn exposes the raw connection of the underlying packet connection when available.
+func (t *pconn) SyscallConn() (syscall.RawConn, error) {
+	if sc, ok := t.innerpconn.(syscall.Conn); ok {
+		return sc.SyscallConn()
+	}
+
+	return nil, syscall.EOPNOTSUPP
+}
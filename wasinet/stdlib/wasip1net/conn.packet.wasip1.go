@@ -5,6 +5,7 @@ package wasip1net
 import (
 	"net"
 	"net/netip"
+	"syscall"
 	"time"
 
 	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
@@ -110,6 +111,11 @@ func (c *packetConn) WriteMsgUDPAddrPort(b, oob []byte, addrPort netip.AddrPort)
 	return c.conn.fd.writeMsg(b, oob, wasip1syscall.NetipAddrPortToRaw(c.conn.fd.family, c.conn.fd.sotype, addrPort))
 }
 
+// SyscallConn returns a raw network connection.
+func (c *packetConn) SyscallConn() (syscall.RawConn, error) {
+	return c.conn.SyscallConn()
+}
+
 func (c *packetConn) LocalAddr() net.Addr {
 	return c.conn.LocalAddr()
 }
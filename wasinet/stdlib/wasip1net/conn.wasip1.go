@@ -150,8 +150,13 @@ func (c *conn) File() (f *os.File, err error) {
 	return
 }
 
-func (c *conn) SyscallConn() syscall.RawConn {
-	return netsysconn{*c}
+// SyscallConn returns a raw network connection.
+// This implements the syscall.Conn interface.
+func (c *conn) SyscallConn() (syscall.RawConn, error) {
+	if !c.ok() {
+		return nil, syscall.EINVAL
+	}
+	return netsysconn{*c}, nil
 }
 
 type netsysconn struct {
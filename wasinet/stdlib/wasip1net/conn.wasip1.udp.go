@@ -103,7 +103,7 @@ func ipToSockaddrInet6(ip net.IP, port int, zone string) (syscall.SockaddrInet6,
 	if ip6 == nil {
 		return syscall.SockaddrInet6{}, &net.AddrError{Err: "non-IPv6 address", Addr: ip.String()}
 	}
-	sa := syscall.SockaddrInet6{Port: port, ZoneId: uint32(zoneCache.index(zone))}
+	sa := syscall.SockaddrInet6{Port: port, ZoneId: uint32(wasip1syscall.ZoneIndex(zone))}
 	copy(sa.Addr[:], ip6)
 	return sa, nil
 }
@@ -122,7 +122,7 @@ func addrPortToSockaddrInet6(ap netip.AddrPort) (syscall.SockaddrInet6, error) {
 	sa := syscall.SockaddrInet6{
 		Addr:   addr.As16(),
 		Port:   int(ap.Port()),
-		ZoneId: uint32(zoneCache.index(addr.Zone())),
+		ZoneId: uint32(wasip1syscall.ZoneIndex(addr.Zone())),
 	}
 	return sa, nil
 }
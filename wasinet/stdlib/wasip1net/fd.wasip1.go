@@ -137,7 +137,10 @@ func (fd *netFD) accept() (netfd *netFD, err error) {
 			}
 
 			switch ffierrors.Errno(err) {
-			case syscall.EINTR, syscall.EAGAIN:
+			case syscall.EINTR, syscall.EAGAIN, syscall.ECONNABORTED:
+				// This means that a socket on the listen
+				// queue was closed before we Accept()ed it;
+				// it's a silly error, so try again.
 				runtime.Gosched()
 			default:
 				return -1, err
@@ -150,12 +153,14 @@ func (fd *netFD) accept() (netfd *netFD, err error) {
 		return nil, err
 	}
 
+	// copy the poll descriptor, it's owned by the file which closes it when finalized.
+	pfd := newFile(nfd, "").PollFD().Copy()
 	netfd = newPollFD(
 		fd.net,
 		fd.family,
 		fd.sotype,
 		nfd,
-		newFile(nfd, "").PollFD(),
+		&pfd,
 	)
 
 	if err = netfd.init(InitConnection); err != nil {
@@ -183,9 +188,14 @@ func (fd *netFD) setAddr(laddr, raddr net.Addr) {
 func (fd *netFD) Close() error {
 	fd.disconnected.Store(true)
 	runtime.SetFinalizer(fd, nil)
+	// the peer may have already disconnected, the shutdown is best effort.
+	_ = wasip1syscall.Shutdown(fd.sysfd, syscall.SHUT_RDWR)
+
+	// the poll descriptor waits for pending operations to complete before
+	// closing, only then is the host socket released.
 	return errorsx.Compact(
-		wasip1syscall.Shutdown(fd.sysfd, syscall.SHUT_RDWR),
 		fd.pfd.Close(),
+		wasip1syscall.Close(fd.sysfd),
 	)
 }
 
@@ -232,13 +242,31 @@ func (fd *netFD) Write(p []byte) (nn int, err error) {
 		return 0, errMissingAddress
 	}
 
-	nn, err = wasip1syscall.SendToSingle(fd.sysfd, p, nil, fd.rsockaddr, 0)
+	// nonblocking streams may accept part of the buffer, the remainder is sent
+	// once the socket is writable again or the write deadline passes.
+	werr := fd.pfd.RawWrite(func(sysfd uintptr) bool {
+		var n int
+		n, err = wasip1syscall.SendToSingle(int(sysfd), p[nn:], nil, fd.rsockaddr, 0)
+		switch ffierrors.Errno(err) {
+		case syscall.EINTR, syscall.EAGAIN:
+			err = nil
+			return false
+		case ffierrors.ErrnoSuccess():
+			nn += n
+			// datagrams are sent whole.
+			return nn == len(p) || fd.sotype != syscall.SOCK_STREAM
+		default:
+			return true
+		}
+	})
+
 	runtime.KeepAlive(fd)
-	return nn, wrapSyscallError(writeSyscallName, err)
+	return nn, wrapSyscallError(writeSyscallName, errorsx.Compact(err, werr))
 }
 
 func (fd *netFD) SetDeadline(t time.Time) error {
 	return errorsx.Compact(
+		fd.pfd.SetWriteDeadline(t),
 		setDeadlineImpl(fd, t, wasip1syscall.SO_SNDTIMEO),
 		setDeadlineImpl(fd, t, wasip1syscall.SO_RCVTIMEO),
 	)
@@ -250,8 +278,11 @@ func (fd *netFD) SetReadDeadline(t time.Time) error {
 }
 
 func (fd *netFD) SetWriteDeadline(t time.Time) error {
-	err := setDeadlineImpl(fd, t, wasip1syscall.SO_SNDTIMEO)
-	return err
+	// writes waiting for the socket to become writable observe the poller's deadline.
+	return errorsx.Compact(
+		fd.pfd.SetWriteDeadline(t),
+		setDeadlineImpl(fd, t, wasip1syscall.SO_SNDTIMEO),
+	)
 }
 
 func zeroEOF(n int) error {
@@ -0,0 +1,66 @@
+//go:build wasip1
+
+package wasip1net
+
+import (
+	"net"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+// If the ifindex is zero, interfaceTable returns mappings of all
+// network interfaces. Otherwise it returns a mapping of a specific
+// interface.
+func interfaceTable(ifindex int) (ift []net.Interface, err error) {
+	ifaces, err := wasip1syscall.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range ifaces {
+		if ifindex == 0 || ifindex == iface.Index {
+			ift = append(ift, iface.Net())
+		}
+	}
+
+	return ift, nil
+}
+
+// If the ifi is nil, interfaceAddrTable returns addresses for all
+// network interfaces. Otherwise it returns addresses for a specific
+// interface.
+func interfaceAddrTable(ifi *net.Interface) (ifat []net.Addr, err error) {
+	ifaces, err := wasip1syscall.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range ifaces {
+		if ifi != nil && ifi.Index != iface.Index {
+			continue
+		}
+
+		ifat = append(ifat, iface.NetAddrs()...)
+	}
+
+	return ifat, nil
+}
+
+// interfaceMulticastAddrTable returns addresses for a specific
+// interface.
+func interfaceMulticastAddrTable(ifi *net.Interface) (ifmat []net.Addr, err error) {
+	ifaces, err := wasip1syscall.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range ifaces {
+		if ifi != nil && ifi.Index != iface.Index {
+			continue
+		}
+
+		ifmat = append(ifmat, iface.NetMulticastAddrs()...)
+	}
+
+	return ifmat, nil
+}
@@ -16,3 +16,8 @@ func Conn(family, sotype int, f *os.File) (c net.Conn, err error) {
 func Listen(family, sotype int, fd uintptr) (net.Listener, error) {
 	return Listener(family, sotype, fd)
 }
+
+// ListenKeepAlive like Listen, accepted tcp connections are configured with the keep-alive config.
+func ListenKeepAlive(family, sotype int, fd uintptr, config net.KeepAliveConfig) (net.Listener, error) {
+	return listenerKeepAlive(family, sotype, fd, config)
+}
@@ -11,6 +11,8 @@ import (
 	"syscall"
 	"time"
 	_ "unsafe"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 )
 
 // This helper is implemented in the syscall package. It means we don't have
@@ -77,6 +79,8 @@ func newFileConn(family, sotype int, f *os.File) (net.Conn, error) {
 func newFdConn(fd *netFD) (net.Conn, error) {
 	switch fd.net {
 	case "tcp":
+		// like the standard library nagle's algorithm is disabled by default, failures are ignored.
+		_ = setNoDelay(fd, true)
 		return &TCPConn{conn{fd: fd}}, nil
 	case "udp":
 		return &UDPConn{conn{fd: fd}}, nil
@@ -101,14 +105,26 @@ func Listener(family, sotype int, fd uintptr) (net.Listener, error) {
 		return nil, err
 	}
 
-	return &listener{pfd}, nil
+	return &listener{netFD: pfd}, nil
+}
+
+func listenerKeepAlive(family, sotype int, fd uintptr, config net.KeepAliveConfig) (net.Listener, error) {
+	pfd, err := newFD(family, sotype, Socket(fd), InitListener)
+	if err != nil {
+		return nil, err
+	}
+
+	return &listener{netFD: pfd, keepalive: config}, nil
 }
 
 type slistener interface {
 	accept() (netfd *netFD, err error)
 }
 
-type listener struct{ *netFD }
+type listener struct {
+	*netFD
+	keepalive net.KeepAliveConfig // applied to accepted tcp connections when enabled.
+}
 
 func (l *listener) Accept() (net.Conn, error) {
 	c, err := l.netFD.accept()
@@ -116,6 +132,11 @@ func (l *listener) Accept() (net.Conn, error) {
 		return nil, err
 	}
 
+	if l.keepalive.Enable && c.net == "tcp" {
+		// like net.ListenConfig keep-alive failures do not fail the accept.
+		_ = wasip1syscall.SetKeepAliveConfig(c.sysfd, l.keepalive)
+	}
+
 	return newFdConn(c)
 }
 
@@ -192,23 +213,38 @@ func setWriteBuffer(fd *netFD, bytes int) (err error) {
 }
 
 func setKeepAlive(fd *netFD, keepalive bool) (err error) {
-	log.Println("set keepalive")
-	// err := fd.pfd.SetsockoptInt(syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, boolint(keepalive))
+	err = wasip1syscall.SetSockoptInt(fd.sysfd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_KEEPALIVE, boolint(keepalive))
+	runtime.KeepAlive(fd)
+	return wrapSyscallError("setsockopt", err)
+}
+
+func setKeepAlivePeriod(fd *netFD, d time.Duration) (err error) {
+	err = wasip1syscall.SetKeepAlivePeriod(fd.sysfd, d)
+	runtime.KeepAlive(fd)
+	return wrapSyscallError("setsockopt", err)
+}
+
+func setKeepAliveConfig(fd *netFD, config net.KeepAliveConfig) (err error) {
+	err = wasip1syscall.SetKeepAliveConfig(fd.sysfd, config)
+	runtime.KeepAlive(fd)
+	return wrapSyscallError("setsockopt", err)
+}
+
+func setNoDelay(fd *netFD, noDelay bool) (err error) {
+	err = wasip1syscall.SetSockoptInt(fd.sysfd, wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_NODELAY, boolint(noDelay))
 	runtime.KeepAlive(fd)
 	return wrapSyscallError("setsockopt", err)
 }
 
 func setLinger(fd *netFD, sec int) (err error) {
-	log.Println("set linger")
-	// var l syscall.Linger
-	// if sec >= 0 {
-	// 	l.Onoff = 1
-	// 	l.Linger = int32(sec)
-	// } else {
-	// 	l.Onoff = 0
-	// 	l.Linger = 0
-	// }
-	// err := fd.pfd.SetsockoptLinger(syscall.SOL_SOCKET, syscall.SO_LINGER, &l)
+	err = wasip1syscall.SetsockoptLinger(fd.sysfd, sec)
 	runtime.KeepAlive(fd)
 	return wrapSyscallError("setsockopt", err)
 }
+
+func boolint(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -5,6 +5,9 @@ package wasip1net
 import (
 	"net"
 	"os"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 )
 
 func newFile(fd int, name string) *os.File {
@@ -26,3 +29,54 @@ func PacketConnFd(family, sotype int, fd uintptr) (*pconn, error) {
 func Listener(family, sotype int, fd uintptr) (net.Listener, error) {
 	return net.FileListener(Socket(fd))
 }
+
+// the standard library's listener already applies the default keep-alive
+// configuration to accepted connections, other configurations are applied
+// by wrapping it.
+func listenerKeepAlive(family, sotype int, fd uintptr, config net.KeepAliveConfig) (net.Listener, error) {
+	l, err := net.FileListener(Socket(fd))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := l.(*net.TCPListener); !ok || config == (net.KeepAliveConfig{Enable: true}) {
+		return l, nil
+	}
+
+	return keepalivelistener{Listener: l, config: config}, nil
+}
+
+// keepalivelistener configures tcp keep-alive probes on accepted connections.
+type keepalivelistener struct {
+	net.Listener
+	config net.KeepAliveConfig
+}
+
+func (t keepalivelistener) Accept() (net.Conn, error) {
+	conn, err := t.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return conn, nil
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return conn, nil
+	}
+
+	// like net.ListenConfig keep-alive failures do not fail the accept.
+	_ = raw.Control(func(fd uintptr) {
+		_ = wasip1syscall.SetKeepAliveConfig(int(fd), t.config)
+	})
+
+	return conn, nil
+}
+
+// SyscallConn exposes the underlying listener's raw connection.
+func (t keepalivelistener) SyscallConn() (syscall.RawConn, error) {
+	return t.Listener.(syscall.Conn).SyscallConn()
+}
@@ -157,7 +157,7 @@ func tcpNetAddr(sa sockaddr) *net.TCPAddr {
 	case *addressany[addrip4]:
 		return &net.TCPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port)}
 	case *addressany[addrip6]:
-		return &net.TCPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port), Zone: ""}
+		return &net.TCPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port), Zone: zonename(unknown.addr.zone)}
 	}
 	return nil
 }
@@ -171,7 +171,7 @@ func udpNetAddr(sa sockaddr) *net.UDPAddr {
 	case *addressany[addrip4]:
 		return &net.UDPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port)}
 	case *addressany[addrip6]:
-		return &net.UDPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port), Zone: ""}
+		return &net.UDPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port), Zone: zonename(unknown.addr.zone)}
 	default:
 		return nil
 	}
@@ -186,7 +186,7 @@ func ipNetAddr(sa sockaddr) *net.IPAddr {
 	case *addressany[addrip4]:
 		return &net.IPAddr{IP: proto.addr.ip[0:]}
 	case *addressany[addrip6]:
-		return &net.IPAddr{IP: proto.addr.ip[0:]}
+		return &net.IPAddr{IP: proto.addr.ip[0:], Zone: zonename(proto.addr.zone)}
 	default:
 		return nil
 	}
@@ -1,32 +1,71 @@
 package wasip1syscall
 
+// socket option levels and names use linux's numbering across the abi.
 const (
 	_0x0 = iota
 	_0x1
 	SO_REUSEADDR
-	_0x3
+	SO_TYPE // 0x3
 	SO_ERROR
 	_0x5         // 0x5
 	SO_BROADCAST // 0x6
-	_
-	_
-	_
+	SO_SNDBUF    // 0x7
+	SO_RCVBUF    // 0x8
+	SO_KEEPALIVE // 0x9
 	_0xa
 	_
 	_
+	SO_LINGER // 0xd
 	_
-	_
-	_0xf
+	SO_REUSEPORT // 0xf
 	_
 	_
 	_
 	_
 	SO_RCVTIMEO
 	SO_SNDTIMEO
+	_
+	_
+	_
+	SO_BINDTODEVICE // 0x19
+)
+
+const (
+	IPPROTO_IP   = 0x0
+	SOL_SOCKET   = 0x1
+	IPPROTO_TCP  = 0x6
+	IPPROTO_IPV6 = 0x29
+)
+
+const (
+	IP_MULTICAST_IF    = 0x20
+	IP_MULTICAST_TTL   = 0x21
+	IP_MULTICAST_LOOP  = 0x22
+	IP_ADD_MEMBERSHIP  = 0x23
+	IP_DROP_MEMBERSHIP = 0x24
 )
 
 const (
-	SOL_SOCKET = iota
+	IPV6_MULTICAST_IF   = 0x11
+	IPV6_MULTICAST_HOPS = 0x12
+	IPV6_MULTICAST_LOOP = 0x13
+	IPV6_JOIN_GROUP     = 0x14
+	IPV6_LEAVE_GROUP    = 0x15
+)
+
+const (
+	_           = iota
+	TCP_NODELAY // 0x1
+	_
+	_
+	TCP_KEEPIDLE  // 0x4
+	TCP_KEEPINTVL // 0x5
+	TCP_KEEPCNT   // 0x6
+	_
+	_
+	_
+	_
+	TCP_INFO // 0xb
 )
 
 const (
@@ -34,3 +73,13 @@ const (
 	SOCK_DGRAM
 	SOCK_STREAM
 )
+
+// dns record types, the values match the dns wire format.
+const (
+	DNS_NS    = 0x2
+	DNS_CNAME = 0x5
+	DNS_PTR   = 0xc
+	DNS_MX    = 0xf
+	DNS_TXT   = 0x10
+	DNS_SRV   = 0x21
+)
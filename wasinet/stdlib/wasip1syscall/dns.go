@@ -2,16 +2,77 @@ package wasip1syscall
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"net"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/egdaemon/wasinet/wasinet/ffi"
 	"github.com/egdaemon/wasinet/wasinet/ffierrors"
+	"github.com/egdaemon/wasinet/wasinet/ffiguest"
 )
 
+// LookupErrno converts a host lookup error into the errno returned to the guest,
+// the errno is the dns error code on the wire.
+//
+//	ENOENT    the name does not exist (NXDOMAIN).
+//	ETIMEDOUT the lookup timed out.
+//	EAGAIN    the lookup failed temporarily e.g. SERVFAIL, retrying may succeed.
+//	ECANCELED the lookup was cancelled.
+//	EIO       any other resolver failure.
+//
+// errors that are already an errno are returned unchanged.
+func LookupErrno(err error) syscall.Errno {
+	var (
+		errno   syscall.Errno
+		dnserr  *net.DNSError
+		timeout interface{ Timeout() bool }
+	)
+
+	switch {
+	case err == nil:
+		return ffierrors.ErrnoSuccess()
+	case errors.As(err, &dnserr) && dnserr.IsNotFound:
+		return syscall.ENOENT
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &timeout) && timeout.Timeout():
+		return syscall.ETIMEDOUT
+	case errors.Is(err, context.Canceled):
+		return syscall.ECANCELED
+	case dnserr != nil && dnserr.IsTemporary:
+		return syscall.EAGAIN
+	case errors.As(err, &errno):
+		return errno
+	default:
+		return syscall.EIO
+	}
+}
+
+// DNSError converts the errno returned by a host lookup into the equivalent
+// *net.DNSError, the inverse of LookupErrno. the errno is retained as the
+// unwrapped error.
+func DNSError(errno syscall.Errno, name string) error {
+	switch errno {
+	case ffierrors.ErrnoSuccess():
+		return nil
+	case syscall.ENOENT:
+		return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true, UnwrapErr: errno}
+	case syscall.ETIMEDOUT:
+		return &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true, IsTemporary: true, UnwrapErr: errno}
+	case syscall.EAGAIN:
+		return &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true, UnwrapErr: errno}
+	case syscall.ECANCELED:
+		return &net.DNSError{Err: "operation was canceled", Name: name, UnwrapErr: errno}
+	default:
+		return &net.DNSError{Err: errno.Error(), Name: name, UnwrapErr: errno}
+	}
+}
+
 func networkip(network string) string {
 	switch network {
 	case "tcp", "udp":
@@ -25,17 +86,27 @@ func networkip(network string) string {
 	}
 }
 
-func netaddr(network string, ip net.IP, port int) net.Addr {
+func netaddr(network string, ip net.IP, port int, zone string) net.Addr {
 	switch network {
 	case "tcp", "tcp4", "tcp6":
-		return &net.TCPAddr{IP: ip, Port: port}
+		return &net.TCPAddr{IP: ip, Port: port, Zone: zone}
 	case "udp", "udp4", "udp6":
-		return &net.UDPAddr{IP: ip, Port: port}
+		return &net.UDPAddr{IP: ip, Port: port, Zone: zone}
 	}
 	return nil
 }
 
-func LookupAddress(_ context.Context, op, network, address string) ([]net.Addr, error) {
+// splitzone separates the zone from an ipv6 literal, e.g. fe80::1%eth0.
+func splitzone(hostname string) (string, string) {
+	i := strings.LastIndexByte(hostname, '%')
+	if i < 0 || net.ParseIP(hostname[:i]) == nil {
+		return hostname, ""
+	}
+
+	return hostname[:i], hostname[i+1:]
+}
+
+func LookupAddress(ctx context.Context, op, network, address string) ([]net.Addr, error) {
 	switch network {
 	case "unix", "unixgram":
 		return []net.Addr{&net.UnixAddr{Name: address, Net: network}}, nil
@@ -47,19 +118,23 @@ func LookupAddress(_ context.Context, op, network, address string) ([]net.Addr,
 		return nil, err
 	}
 
+	hostname, zone := splitzone(hostname)
+
 	port, err := ResolvePort(network, service)
 	if err != nil {
 		return nil, os.NewSyscallError("resolveport", err)
 	}
 
-	ips, err := ResolveAddrip(op, network, hostname)
-	if err != nil {
+	ips, err := ResolveAddrip(ctx, op, network, hostname)
+	if dnserr := (*net.DNSError)(nil); errors.As(err, &dnserr) {
+		return nil, dnserr
+	} else if err != nil {
 		return nil, os.NewSyscallError("resolveaddrip", err)
 	}
 
 	addrs := make([]net.Addr, 0, len(ips))
 	for _, ip := range ips {
-		addrs = append(addrs, netaddr(network, ip, port))
+		addrs = append(addrs, netaddr(network, ip, port, zone))
 	}
 
 	if len(addrs) == 0 {
@@ -73,7 +148,7 @@ func LookupAddress(_ context.Context, op, network, address string) ([]net.Addr,
 	return addrs, nil
 }
 
-func ResolveAddrip(op, network, address string) (res []net.IP, err error) {
+func ResolveAddrip(ctx context.Context, op, network, address string) (res []net.IP, err error) {
 	if ip := net.ParseIP(address); ip != nil {
 		return []net.IP{ip}, nil
 	}
@@ -96,39 +171,153 @@ func ResolveAddrip(op, network, address string) (res []net.IP, err error) {
 		return []net.IP{net.IPv4(127, 0, 0, 1)}, nil
 	}
 
+	records, err := cachedlookupip(ctx, netip, address)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range records {
+		res = append(res, r.IP)
+	}
+
+	return res, nil
+}
+
+// IPRecord a resolved address and how long it may be cached. the ttl is a
+// lifetime imposed by the host rather than the record's, a zero ttl means the
+// address shouldn't be cached.
+type IPRecord struct {
+	IP  net.IP
+	TTL time.Duration
+}
+
+// families of an encoded IPRecord, fixed so they're independent of the host's numbering.
+const (
+	iprecordip4 = 4
+	iprecordip6 = 6
+)
+
+// the abi encoding of an IPRecord, ipv4 addresses are mapped into ipv6.
+type iprecord struct {
+	IP     [net.IPv6len]byte
+	Family uint16
+	_      uint16
+	TTL    uint32 // seconds
+}
+
+// EncodeIPRecords into their abi representation, every record is a fixed size.
+// records with malformed addresses are skipped.
+func EncodeIPRecords(records ...IPRecord) []byte {
+	buf := make([]byte, 0, len(records)*binary.Size(iprecord{}))
+	for _, r := range records {
+		ip := r.IP.To16()
+		if ip == nil {
+			continue
+		}
+
+		encoded := iprecord{IP: [net.IPv6len]byte(ip), Family: iprecordip6, TTL: uint32(r.TTL / time.Second)}
+		if r.IP.To4() != nil {
+			encoded.Family = iprecordip4
+		}
+
+		buf, _ = binary.Append(buf, binary.LittleEndian, encoded)
+	}
+
+	return buf
+}
+
+// DecodeIPRecords from their abi representation, returns EINVAL when the encoding is malformed.
+func DecodeIPRecords(encoded []byte) (records []IPRecord, err error) {
+	size := binary.Size(iprecord{})
+	if len(encoded)%size != 0 {
+		return nil, syscall.EINVAL
+	}
+
+	records = make([]IPRecord, 0, len(encoded)/size)
+	for offset := 0; offset < len(encoded); offset += size {
+		var r iprecord
+		if _, err = binary.Decode(encoded[offset:], binary.LittleEndian, &r); err != nil {
+			return nil, syscall.EINVAL
+		}
+
+		ip := net.IP(r.IP[:])
+		switch r.Family {
+		case iprecordip4:
+			ip = ip.To4()
+		case iprecordip6:
+		default:
+			return nil, syscall.EINVAL
+		}
+
+		records = append(records, IPRecord{IP: ip, TTL: time.Duration(r.TTL) * time.Second})
+	}
+
+	return records, nil
+}
+
+// LookupIP resolves the address using the host's resolver, every address
+// is returned regardless of how many the name resolves to. the host abandons
+// the lookup once the context's deadline passes.
+func LookupIP(ctx context.Context, network, address string) ([]IPRecord, error) {
 	var (
-		bufreslength uint32
+		count uint32
 	)
 
-	buf := make([]byte, net.IPv6len*8)
+	size := binary.Size(iprecord{})
+	buf := make([]byte, 8*size)
+	for {
+		networkptr, networklen := ffi.String(network)
+		addressptr, addresslen := ffi.String(address)
+		bufptr, buflen := ffi.Slice(buf)
+		countptr, _ := ffi.Pointer(&count)
+		if err := contexterr(ctx, address); err != nil {
+			return nil, err
+		}
 
-	networkptr, networklen := ffi.String(netip)
-	addressptr, addresslen := ffi.String(address)
-	bufptr, buflen := ffi.Slice(buf)
-	bufresptr, _ := ffi.Pointer(&bufreslength)
+		errno := sock_lookup_ip(
+			ffiguest.ContextDeadline(ctx),
+			networkptr,
+			networklen,
+			addressptr,
+			addresslen,
+			bufptr,
+			buflen,
+			countptr,
+		)
+		runtime.KeepAlive(network)
+		runtime.KeepAlive(address)
+		runtime.KeepAlive(buf)
 
-	errno := sock_getaddrip(
-		networkptr,
-		networklen,
-		addressptr,
-		addresslen,
-		bufptr,
-		buflen,
-		bufresptr,
-	)
-	runtime.KeepAlive(netip)
-	runtime.KeepAlive(address)
-	runtime.KeepAlive(buf)
+		if err := DNSError(errno, address); err != nil {
+			return nil, err
+		}
 
-	if err = ffierrors.Error(errno); err != nil {
-		return nil, err
+		if needed := int(count) * size; needed > len(buf) {
+			buf = make([]byte, needed)
+			continue
+		}
+
+		return DecodeIPRecords(buf[:int(count)*size])
+	}
+}
+
+// cachedlookupip resolves the address through the installed dns cache, if any.
+func cachedlookupip(ctx context.Context, network, address string) ([]IPRecord, error) {
+	if c := dnscache.Load(); c != nil {
+		return c.LookupIP(ctx, network, address, LookupIP)
 	}
 
-	for i := 0; i < int(bufreslength); i += net.IPv6len {
-		res = append(res, net.IP(buf[i:i+net.IPv6len]))
+	return LookupIP(ctx, network, address)
+}
+
+// contexterr reports a context that is already done as the error the host
+// would have returned for the lookup, avoiding the round trip.
+func contexterr(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return DNSError(LookupErrno(err), name)
 	}
 
-	return res, nil
+	return nil
 }
 
 func ResolvePort(network, service string) (_port int, err error) {
@@ -151,5 +340,9 @@ func ResolvePort(network, service string) (_port int, err error) {
 		portptr,
 	)
 
-	return int(port), syscall.Errno(errno)
+	if syscall.Errno(errno) == syscall.ENOENT {
+		return 0, &net.DNSError{Err: "unknown port", Name: network + "/" + service, IsNotFound: true, UnwrapErr: syscall.ENOENT}
+	}
+
+	return int(port), DNSError(syscall.Errno(errno), network+"/"+service)
 }
@@ -0,0 +1,162 @@
+package wasip1syscall
+
+import (
+	"cmp"
+	"container/list"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var dnscache atomic.Pointer[DNSCache]
+
+// SetDNSCache installs the cache used when resolving addresses, nil disables caching.
+func SetDNSCache(c *DNSCache) {
+	dnscache.Store(c)
+}
+
+// FlushDNSCache discards every entry of the installed cache.
+func FlushDNSCache() {
+	if c := dnscache.Load(); c != nil {
+		c.Flush()
+	}
+}
+
+type dnskey struct {
+	network string
+	name    string
+}
+
+type dnsentry struct {
+	key     dnskey
+	records []IPRecord
+	err     error
+	expires time.Time
+}
+
+// DNSCache caches the records names resolve to keyed by network and name, records
+// are retained for the smallest ttl the host reported for them. names that do not
+// exist are retained for the negative ttl. once full the least recently used
+// entry is evicted.
+type DNSCache struct {
+	mu       sync.Mutex
+	size     int
+	negative time.Duration
+	lru      *list.List
+	entries  map[dnskey]*list.Element
+}
+
+// NewDNSCache retaining at most size entries, a negative ttl of zero disables negative caching.
+func NewDNSCache(size int, negative time.Duration) *DNSCache {
+	return &DNSCache{
+		size:     max(size, 1),
+		negative: negative,
+		lru:      list.New(),
+		entries:  make(map[dnskey]*list.Element, size),
+	}
+}
+
+// LookupIP returns the cached records for the name, on a miss the name is
+// resolved and the result cached according to its ttl.
+func (t *DNSCache) LookupIP(ctx context.Context, network, name string, resolve func(ctx context.Context, network, name string) ([]IPRecord, error)) ([]IPRecord, error) {
+	key := dnskey{network: network, name: name}
+	if e, ok := t.get(key); ok {
+		return cloneiprecords(e.records), e.err
+	}
+
+	records, err := resolve(ctx, network, name)
+	t.put(key, records, err)
+
+	return records, err
+}
+
+// Flush discards every entry.
+func (t *DNSCache) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lru.Init()
+	clear(t.entries)
+}
+
+// Len the number of entries, including those that have expired but have not yet been evicted.
+func (t *DNSCache) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.lru.Len()
+}
+
+func (t *DNSCache) get(key dnskey) (*dnsentry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	el, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*dnsentry)
+	if !time.Now().Before(e.expires) {
+		t.lru.Remove(el)
+		delete(t.entries, key)
+		return nil, false
+	}
+
+	t.lru.MoveToFront(el)
+	return e, true
+}
+
+func (t *DNSCache) put(key dnskey, records []IPRecord, err error) {
+	var (
+		ttl    time.Duration
+		dnserr *net.DNSError
+	)
+
+	switch {
+	case err == nil && len(records) > 0:
+		ttl = slices.MinFunc(records, func(a, b IPRecord) int { return cmp.Compare(a.TTL, b.TTL) }).TTL
+	case errors.As(err, &dnserr) && dnserr.IsNotFound:
+		ttl = t.negative
+	}
+
+	// the host asked for the records not to be cached or the failure may be transient.
+	if ttl <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := &dnsentry{key: key, records: cloneiprecords(records), err: err, expires: time.Now().Add(ttl)}
+	if el, ok := t.entries[key]; ok {
+		el.Value = e
+		t.lru.MoveToFront(el)
+		return
+	}
+
+	t.entries[key] = t.lru.PushFront(e)
+	for t.lru.Len() > t.size {
+		oldest := t.lru.Back()
+		t.lru.Remove(oldest)
+		delete(t.entries, oldest.Value.(*dnsentry).key)
+	}
+}
+
+// callers take ownership of the addresses they're given, copy them to keep the cache intact.
+func cloneiprecords(records []IPRecord) []IPRecord {
+	if records == nil {
+		return nil
+	}
+
+	dup := make([]IPRecord, 0, len(records))
+	for _, r := range records {
+		dup = append(dup, IPRecord{IP: slices.Clone(r.IP), TTL: r.TTL})
+	}
+
+	return dup
+}
@@ -1,7 +1,10 @@
 package wasip1syscall
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"net"
 	"os"
 	"runtime"
 	"syscall"
@@ -10,6 +13,7 @@ import (
 
 	"github.com/egdaemon/wasinet/wasinet/ffi"
 	"github.com/egdaemon/wasinet/wasinet/ffierrors"
+	"github.com/egdaemon/wasinet/wasinet/internal/langx"
 )
 
 func Accept(fd int) (nfd int, addr RawSocketAddress, err error) {
@@ -87,14 +91,14 @@ func Getsockname(fd int) (sa sockaddr, err error) {
 	return sa, os.NewSyscallError("getsockname", err)
 }
 
-func getrawpeername(fd int) (rsa RawSocketAddress, err error) {
+func GetpeernameRaw(fd int) (rsa RawSocketAddress, err error) {
 	rsaptr, rsalength := ffi.Pointer(&rsa)
 	errno := sock_getpeeraddr(int32(fd), rsaptr, rsalength)
 	return rsa, ffierrors.Error(errno)
 }
 
 func Getpeername(fd int) (sockaddr, error) {
-	rsa, err := getrawpeername(fd)
+	rsa, err := GetpeernameRaw(fd)
 	if err != nil {
 		return nil, err
 	}
@@ -102,14 +106,9 @@ func Getpeername(fd int) (sockaddr, error) {
 }
 
 func SetsockoptTimeval(fd int, level uint32, opt uint32, d time.Duration) error {
-	type Timeval struct {
-		Sec  int64
-		Usec int64
-	}
-
 	secs := d.Truncate(time.Second)
-	milli := d - secs
-	tval := &Timeval{Sec: int64(secs / time.Second), Usec: milli.Milliseconds()}
+	micro := d - secs
+	tval := &Timeval{Sec: int64(secs / time.Second), Usec: micro.Microseconds()}
 	// chatgpt'd the range for these....
 	tval.Usec = max(0, min(tval.Usec, 999999))
 	tvalptr, tvallen := ffi.Pointer(tval)
@@ -117,6 +116,43 @@ func SetsockoptTimeval(fd int, level uint32, opt uint32, d time.Duration) error
 	return os.NewSyscallError("setsockopt_timeval", err)
 }
 
+// SetsockoptLinger sets SO_LINGER, a negative duration disables lingering.
+func SetsockoptLinger(fd int, sec int) error {
+	l := &Linger{}
+	if sec >= 0 {
+		l.Onoff, l.Linger = 1, int32(sec)
+	}
+
+	lptr, llen := ffi.Pointer(l)
+	err := ffierrors.Error(sock_setsockopt(int32(fd), SOL_SOCKET, SO_LINGER, lptr, llen))
+	runtime.KeepAlive(l)
+	return os.NewSyscallError("setsockopt_linger", err)
+}
+
+// SetsockoptIPMreqn sets ipv4 multicast membership (IP_ADD_MEMBERSHIP, IP_DROP_MEMBERSHIP)
+// or the interface of outgoing multicast datagrams (IP_MULTICAST_IF).
+func SetsockoptIPMreqn(fd, opt int, mreq IPMreqn) error {
+	return os.NewSyscallError("setsockopt_ipmreqn", setsockopt(fd, IPPROTO_IP, opt, mreq))
+}
+
+// SetsockoptIPv6Mreq sets ipv6 multicast membership (IPV6_JOIN_GROUP, IPV6_LEAVE_GROUP).
+func SetsockoptIPv6Mreq(fd, opt int, mreq IPv6Mreq) error {
+	return os.NewSyscallError("setsockopt_ipv6mreq", setsockopt(fd, IPPROTO_IPV6, opt, mreq))
+}
+
+// setsockopt writes a socket option using its abi encoding.
+func setsockopt(fd, level, opt int, v any) error {
+	encoded, err := SockoptBytes(v)
+	if err != nil {
+		return err
+	}
+
+	encodedptr, encodedlen := ffi.Slice(encoded)
+	err = ffierrors.Error(sock_setsockopt(int32(fd), uint32(level), uint32(opt), encodedptr, encodedlen))
+	runtime.KeepAlive(encoded)
+	return err
+}
+
 func Connect(fd int, rsa *RawSocketAddress) error {
 	rawaddr, rawaddrlen := ffi.Pointer(rsa)
 	err := ffierrors.Error(sock_connect(int32(fd), rawaddr, rawaddrlen))
@@ -148,6 +184,58 @@ func SetReuseAddress(fd int) (err error) {
 	return nil
 }
 
+func SetReusePort(fd int) (err error) {
+	return SetSockoptInt(fd, SOL_SOCKET, SO_REUSEPORT, 1)
+}
+
+// SetKeepAlivePeriod sets the idle duration before keep-alive probes are sent,
+// zero uses the default of 15 seconds and negative values leave it unchanged.
+func SetKeepAlivePeriod(fd int, d time.Duration) error {
+	const defaultidle = 15 * time.Second
+
+	if d < 0 {
+		return nil
+	}
+
+	return SetSockoptInt(fd, IPPROTO_TCP, TCP_KEEPIDLE, int(max(langx.DefaultIfZero(defaultidle, d), time.Second)/time.Second))
+}
+
+// SetKeepAliveConfig configures tcp keep-alive probes, matching the defaults of
+// net.TCPConn.SetKeepAliveConfig: zero values use the defaults and negative
+// values leave the current setting unchanged.
+func SetKeepAliveConfig(fd int, config net.KeepAliveConfig) (err error) {
+	const (
+		defaultinterval = 15 * time.Second
+		defaultcount    = 9
+	)
+
+	if !config.Enable {
+		return SetSockoptInt(fd, SOL_SOCKET, SO_KEEPALIVE, 0)
+	}
+
+	if err = SetSockoptInt(fd, SOL_SOCKET, SO_KEEPALIVE, 1); err != nil {
+		return err
+	}
+
+	if err = SetKeepAlivePeriod(fd, config.Idle); err != nil {
+		return err
+	}
+
+	if config.Interval >= 0 {
+		if err = SetSockoptInt(fd, IPPROTO_TCP, TCP_KEEPINTVL, int(max(langx.DefaultIfZero(defaultinterval, config.Interval), time.Second)/time.Second)); err != nil {
+			return err
+		}
+	}
+
+	if config.Count >= 0 {
+		if err = SetSockoptInt(fd, IPPROTO_TCP, TCP_KEEPCNT, langx.DefaultIfZero(defaultcount, config.Count)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func SetSockoptBroadcast(fd int) (err error) {
 	if err := SetSockoptInt(fd, SOL_SOCKET, SO_BROADCAST, 1); err == nil {
 		return nil
@@ -171,6 +259,62 @@ func GetSockoptInt(fd, level, opt int) (value int, err error) {
 	return int(n), errno
 }
 
+// getsockopt reads a socket option encoded as a fixed size struct.
+func getsockopt[T any](fd, level, opt int) (v T, err error) {
+	buf := make([]byte, binary.Size(v))
+	bufptr, buflen := ffi.Slice(buf)
+	if err = ffierrors.Error(sock_getsockopt(int32(fd), uint32(level), uint32(opt), bufptr, buflen)); err != nil {
+		return v, err
+	}
+
+	_, err = binary.Decode(buf, binary.LittleEndian, &v)
+	return v, err
+}
+
+func GetsockoptTimeval(fd, level, opt int) (time.Duration, error) {
+	tval, err := getsockopt[Timeval](fd, level, opt)
+	if err != nil {
+		return 0, os.NewSyscallError("getsockopt_timeval", err)
+	}
+
+	return time.Duration(tval.Sec)*time.Second + time.Duration(tval.Usec)*time.Microsecond, nil
+}
+
+// GetsockoptLinger reads SO_LINGER, returns a negative duration when lingering is disabled.
+func GetsockoptLinger(fd int) (sec int, err error) {
+	l, err := getsockopt[Linger](fd, SOL_SOCKET, SO_LINGER)
+	if err != nil {
+		return -1, os.NewSyscallError("getsockopt_linger", err)
+	}
+
+	if l.Onoff == 0 {
+		return -1, nil
+	}
+
+	return int(l.Linger), nil
+}
+
+// GetsockoptString reads a string socket option, e.g. SO_BINDTODEVICE.
+func GetsockoptString(fd, level, opt int) (string, error) {
+	buf := make([]byte, 256)
+	bufptr, buflen := ffi.Slice(buf)
+	if err := ffierrors.Error(sock_getsockopt(int32(fd), uint32(level), uint32(opt), bufptr, buflen)); err != nil {
+		return "", os.NewSyscallError("getsockopt_string", err)
+	}
+
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+
+	return string(buf), nil
+}
+
+// GetsockoptTCPInfo reads the statistics of a tcp connection.
+func GetsockoptTCPInfo(fd int) (TCPInfo, error) {
+	info, err := getsockopt[TCPInfo](fd, IPPROTO_TCP, TCP_INFO)
+	return info, os.NewSyscallError("getsockopt_tcpinfo", err)
+}
+
 func Bind(fd int, rsa *RawSocketAddress) error {
 	rawaddr, rawaddrlen := ffi.Pointer(rsa)
 	errno := ffierrors.Error(sock_bind(int32(fd), rawaddr, rawaddrlen))
@@ -191,3 +335,8 @@ func Listen(fd int, backlog int) error {
 func Shutdown(fd int, how int) error {
 	return os.NewSyscallError("sock_shutdown", ffierrors.Error(sock_shutdown(int32(fd), int32(how))))
 }
+
+// Close releases the host socket, the descriptor must not be used afterwards.
+func Close(fd int) error {
+	return os.NewSyscallError("sock_close", ffierrors.Error(sock_close(int32(fd))))
+}
@@ -0,0 +1,265 @@
+package wasip1syscall
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"runtime"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/ffi"
+	"github.com/egdaemon/wasinet/wasinet/ffierrors"
+)
+
+var errNoSuchInterface = errors.New("no such network interface")
+
+// Interface the abi representation of a network interface.
+type Interface struct {
+	Index     int
+	MTU       int
+	Name      string
+	Flags     net.Flags
+	Addrs     []netip.Prefix
+	Multicast []netip.Addr
+}
+
+// Net converts the interface into its standard library equivalent.
+func (t Interface) Net() net.Interface {
+	return net.Interface{Index: t.Index, MTU: t.MTU, Name: t.Name, Flags: t.Flags}
+}
+
+// NetAddrs the interface's unicast addresses as their standard library equivalent.
+func (t Interface) NetAddrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(t.Addrs))
+	for _, p := range t.Addrs {
+		addrs = append(addrs, &net.IPNet{IP: p.Addr().AsSlice(), Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen())})
+	}
+
+	return addrs
+}
+
+// NetMulticastAddrs the interface's multicast group addresses as their standard library equivalent.
+func (t Interface) NetMulticastAddrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(t.Multicast))
+	for _, addr := range t.Multicast {
+		addrs = append(addrs, &net.IPAddr{IP: addr.AsSlice()})
+	}
+
+	return addrs
+}
+
+// NativeInterfaces the interfaces of the host, including their unicast and multicast addresses.
+func NativeInterfaces() (ifaces []Interface, err error) {
+	ift, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	ifaces = make([]Interface, 0, len(ift))
+	for _, ifi := range ift {
+		iface := Interface{Index: ifi.Index, MTU: ifi.MTU, Name: ifi.Name, Flags: ifi.Flags}
+
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			addr, ok := netip.AddrFromSlice(ipnet.IP)
+			if !ok {
+				continue
+			}
+
+			bits, total := ipnet.Mask.Size()
+			if total == 8*net.IPv4len {
+				addr = addr.Unmap()
+			}
+
+			iface.Addrs = append(iface.Addrs, netip.PrefixFrom(addr, bits))
+		}
+
+		// not every platform reports multicast memberships.
+		maddrs, _ := ifi.MulticastAddrs()
+		for _, a := range maddrs {
+			ipaddr, ok := a.(*net.IPAddr)
+			if !ok {
+				continue
+			}
+
+			if addr, ok := netip.AddrFromSlice(ipaddr.IP); ok {
+				iface.Multicast = append(iface.Multicast, addr)
+			}
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	return ifaces, nil
+}
+
+// EncodeInterfaces into their abi representation, every integer is little endian.
+//
+//	count u32
+//	index u32, mtu u32, flags u32, name (u16 length prefixed)
+//	address count u16, (bits u8, length u8, ip) per address
+//	multicast count u16, (length u8, ip) per address
+func EncodeInterfaces(ifaces ...Interface) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(ifaces)))
+	for _, iface := range ifaces {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(iface.Index))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(iface.MTU))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(iface.Flags))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(iface.Name)))
+		buf = append(buf, iface.Name...)
+
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(iface.Addrs)))
+		for _, p := range iface.Addrs {
+			ip := p.Addr().AsSlice()
+			buf = append(buf, byte(p.Bits()), byte(len(ip)))
+			buf = append(buf, ip...)
+		}
+
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(iface.Multicast)))
+		for _, a := range iface.Multicast {
+			ip := a.AsSlice()
+			buf = append(buf, byte(len(ip)))
+			buf = append(buf, ip...)
+		}
+	}
+
+	return buf
+}
+
+// DecodeInterfaces from their abi representation, returns EINVAL when the encoding is malformed.
+func DecodeInterfaces(encoded []byte) (ifaces []Interface, err error) {
+	d := decoder{buf: encoded}
+
+	n := d.uint32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		iface := Interface{
+			Index: int(d.uint32()),
+			MTU:   int(d.uint32()),
+			Flags: net.Flags(d.uint32()),
+			Name:  string(d.bytes(int(d.uint16()))),
+		}
+
+		naddrs := d.uint16()
+		for j := uint16(0); j < naddrs && d.err == nil; j++ {
+			bits := int(d.uint8())
+			if addr, ok := netip.AddrFromSlice(d.bytes(int(d.uint8()))); ok {
+				iface.Addrs = append(iface.Addrs, netip.PrefixFrom(addr, bits))
+			}
+		}
+
+		nmulticast := d.uint16()
+		for j := uint16(0); j < nmulticast && d.err == nil; j++ {
+			if addr, ok := netip.AddrFromSlice(d.bytes(int(d.uint8()))); ok {
+				iface.Multicast = append(iface.Multicast, addr)
+			}
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return ifaces, nil
+}
+
+// Interfaces retrieves the network interfaces the host exposes to the guest.
+func Interfaces() ([]Interface, error) {
+	var (
+		reslength uint32
+	)
+
+	buf := make([]byte, 16*1024)
+	for {
+		bufptr, buflen := ffi.Slice(buf)
+		resptr, _ := ffi.Pointer(&reslength)
+		errno := sock_interfaces(bufptr, buflen, resptr)
+		runtime.KeepAlive(buf)
+
+		if err := ffierrors.Error(errno); err != nil {
+			return nil, err
+		}
+
+		if int(reslength) <= len(buf) {
+			return DecodeInterfaces(buf[:reslength])
+		}
+
+		buf = make([]byte, reslength)
+	}
+}
+
+// InterfaceByName returns the interface specified by name.
+func InterfaceByName(name string) (Interface, error) {
+	return interfaceby(func(iface Interface) bool { return iface.Name == name })
+}
+
+// InterfaceByIndex returns the interface specified by index.
+func InterfaceByIndex(index int) (Interface, error) {
+	return interfaceby(func(iface Interface) bool { return iface.Index == index })
+}
+
+func interfaceby(match func(Interface) bool) (Interface, error) {
+	ifaces, err := Interfaces()
+	if err != nil {
+		return Interface{}, &net.OpError{Op: "route", Net: "ip+net", Err: err}
+	}
+
+	for _, iface := range ifaces {
+		if match(iface) {
+			return iface, nil
+		}
+	}
+
+	return Interface{}, &net.OpError{Op: "route", Net: "ip+net", Err: errNoSuchInterface}
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (t *decoder) bytes(n int) []byte {
+	if t.err != nil || len(t.buf) < n {
+		t.err = syscall.EINVAL
+		return nil
+	}
+
+	b := t.buf[:n]
+	t.buf = t.buf[n:]
+	return b
+}
+
+func (t *decoder) uint8() uint8 {
+	if b := t.bytes(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (t *decoder) uint16() uint16 {
+	if b := t.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+
+	return 0
+}
+
+func (t *decoder) uint32() uint32 {
+	if b := t.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+
+	return 0
+}
@@ -0,0 +1,149 @@
+package wasip1syscall
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"runtime"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/ffi"
+	"github.com/egdaemon/wasinet/wasinet/ffiguest"
+)
+
+// DNSRecord the abi representation of a dns resource record, fields unused by the record's type are zero.
+type DNSRecord struct {
+	Target   string // the name of NS, PTR, MX and SRV records, the text of TXT records.
+	Pref     uint16 // MX
+	Priority uint16 // SRV
+	Weight   uint16 // SRV
+	Port     uint16 // SRV
+}
+
+// DNSRecords the abi representation of a lookup, the canonical name is only
+// provided by CNAME and SRV lookups.
+type DNSRecords struct {
+	CNAME   string
+	Records []DNSRecord
+}
+
+// NativeLookupRecords resolves the records of the given type using the resolver.
+// PTR lookups expect an ip address as the name.
+func NativeLookupRecords(ctx context.Context, r *net.Resolver, rtype int, name string) (res DNSRecords, err error) {
+	var (
+		names []string
+		ns    []*net.NS
+		mx    []*net.MX
+		srv   []*net.SRV
+	)
+
+	switch rtype {
+	case DNS_CNAME:
+		res.CNAME, err = r.LookupCNAME(ctx, name)
+	case DNS_NS:
+		ns, err = r.LookupNS(ctx, name)
+		for _, n := range ns {
+			res.Records = append(res.Records, DNSRecord{Target: n.Host})
+		}
+	case DNS_PTR:
+		names, err = r.LookupAddr(ctx, name)
+		for _, n := range names {
+			res.Records = append(res.Records, DNSRecord{Target: n})
+		}
+	case DNS_MX:
+		mx, err = r.LookupMX(ctx, name)
+		for _, m := range mx {
+			res.Records = append(res.Records, DNSRecord{Target: m.Host, Pref: m.Pref})
+		}
+	case DNS_TXT:
+		names, err = r.LookupTXT(ctx, name)
+		for _, txt := range names {
+			res.Records = append(res.Records, DNSRecord{Target: txt})
+		}
+	case DNS_SRV:
+		res.CNAME, srv, err = r.LookupSRV(ctx, "", "", name)
+		for _, s := range srv {
+			res.Records = append(res.Records, DNSRecord{Target: s.Target, Priority: s.Priority, Weight: s.Weight, Port: s.Port})
+		}
+	default:
+		return res, syscall.ENOTSUP
+	}
+
+	return res, err
+}
+
+// EncodeDNSRecords into their abi representation, every integer is little endian.
+//
+//	cname (u16 length prefixed)
+//	count u32
+//	pref u16, priority u16, weight u16, port u16, target (u16 length prefixed) per record
+func EncodeDNSRecords(res DNSRecords) []byte {
+	buf := binary.LittleEndian.AppendUint16(nil, uint16(len(res.CNAME)))
+	buf = append(buf, res.CNAME...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(res.Records)))
+	for _, r := range res.Records {
+		buf = binary.LittleEndian.AppendUint16(buf, r.Pref)
+		buf = binary.LittleEndian.AppendUint16(buf, r.Priority)
+		buf = binary.LittleEndian.AppendUint16(buf, r.Weight)
+		buf = binary.LittleEndian.AppendUint16(buf, r.Port)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(r.Target)))
+		buf = append(buf, r.Target...)
+	}
+
+	return buf
+}
+
+// DecodeDNSRecords from their abi representation, returns EINVAL when the encoding is malformed.
+func DecodeDNSRecords(encoded []byte) (res DNSRecords, err error) {
+	d := decoder{buf: encoded}
+
+	res.CNAME = string(d.bytes(int(d.uint16())))
+	n := d.uint32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		res.Records = append(res.Records, DNSRecord{
+			Pref:     d.uint16(),
+			Priority: d.uint16(),
+			Weight:   d.uint16(),
+			Port:     d.uint16(),
+			Target:   string(d.bytes(int(d.uint16()))),
+		})
+	}
+
+	if d.err != nil {
+		return DNSRecords{}, d.err
+	}
+
+	return res, nil
+}
+
+// LookupRecords resolves the records of the given type using the host's resolver.
+// the host abandons the lookup once the context's deadline passes.
+func LookupRecords(ctx context.Context, rtype int, name string) (DNSRecords, error) {
+	var (
+		reslength uint32
+	)
+
+	buf := make([]byte, 4*1024)
+	for {
+		if err := contexterr(ctx, name); err != nil {
+			return DNSRecords{}, err
+		}
+
+		nameptr, namelen := ffi.String(name)
+		bufptr, buflen := ffi.Slice(buf)
+		resptr, _ := ffi.Pointer(&reslength)
+		errno := dns_lookup(ffiguest.ContextDeadline(ctx), uint32(rtype), nameptr, namelen, bufptr, buflen, resptr)
+		runtime.KeepAlive(name)
+		runtime.KeepAlive(buf)
+
+		if err := DNSError(errno, name); err != nil {
+			return DNSRecords{}, err
+		}
+
+		if int(reslength) <= len(buf) {
+			return DecodeDNSRecords(buf[:reslength])
+		}
+
+		buf = make([]byte, reslength)
+	}
+}
@@ -0,0 +1,77 @@
+package wasip1syscall
+
+import (
+	"encoding/binary"
+	"syscall"
+)
+
+// Timeval the abi encoding of SO_RCVTIMEO and SO_SNDTIMEO.
+type Timeval struct {
+	Sec  int64
+	Usec int64
+}
+
+// Linger the abi encoding of SO_LINGER.
+type Linger struct {
+	Onoff  int32
+	Linger int32
+}
+
+// TCPInfo the abi encoding of TCP_INFO, a portable subset of linux's tcp_info.
+// states use linux's numbering, durations are in microseconds and windows in segments.
+type TCPInfo struct {
+	State        uint32
+	Retransmits  uint32 // unrecovered timeouts of the oldest unacknowledged segment.
+	Probes       uint32 // unanswered zero window probes.
+	Backoff      uint32
+	RTO          uint32
+	RTT          uint32 // smoothed round trip time.
+	RTTVar       uint32
+	SndMSS       uint32
+	RcvMSS       uint32
+	SndCwnd      uint32
+	SndSsthresh  uint32
+	Unacked      uint32
+	Lost         uint32
+	TotalRetrans uint32
+}
+
+// IPMreqn the abi encoding of ipv4 multicast group membership and of IP_MULTICAST_IF.
+// the interface is identified by its index, or by its address when the index is zero.
+type IPMreqn struct {
+	Multiaddr [4]byte
+	Address   [4]byte
+	Ifindex   int32
+}
+
+// IPv6Mreq the abi encoding of ipv6 multicast group membership.
+type IPv6Mreq struct {
+	Multiaddr [16]byte
+	Interface uint32
+}
+
+// SockoptBytes encodes a socket option value into its abi representation.
+// ints are 32 bits, strings and bytes are passed through and structs are little endian.
+func SockoptBytes(v any) ([]byte, error) {
+	switch actual := v.(type) {
+	case int:
+		return binary.LittleEndian.AppendUint32(nil, uint32(actual)), nil
+	case []byte:
+		return actual, nil
+	case string:
+		return []byte(actual), nil
+	case Timeval, Linger, TCPInfo, IPMreqn, IPv6Mreq:
+		return binary.Append(nil, binary.LittleEndian, actual)
+	default:
+		return nil, syscall.ENOTSUP
+	}
+}
+
+// DecodeSockopt decodes a socket option value from its abi representation.
+func DecodeSockopt[T any](value []byte) (v T, err error) {
+	if _, err = binary.Decode(value, binary.LittleEndian, &v); err != nil {
+		return v, syscall.EINVAL
+	}
+
+	return v, nil
+}
@@ -0,0 +1,34 @@
+package wasip1syscall
+
+import (
+	"runtime"
+
+	"github.com/egdaemon/wasinet/wasinet/ffi"
+	"github.com/egdaemon/wasinet/wasinet/ffierrors"
+)
+
+// TrustBundle retrieves the PEM encoded certificates the host trusts.
+func TrustBundle() ([]byte, error) {
+	var (
+		reslength uint32
+	)
+
+	// large enough for typical system bundles, grown when the host reports a larger bundle.
+	buf := make([]byte, 256*1024)
+	for {
+		bufptr, buflen := ffi.Slice(buf)
+		resptr, _ := ffi.Pointer(&reslength)
+		errno := tls_trust_bundle(bufptr, buflen, resptr)
+		runtime.KeepAlive(buf)
+
+		if err := ffierrors.Error(errno); err != nil {
+			return nil, err
+		}
+
+		if int(reslength) <= len(buf) {
+			return buf[:reslength], nil
+		}
+
+		buf = make([]byte, reslength)
+	}
+}
@@ -8,9 +8,12 @@ import (
 )
 
 const (
+	EACCES        syscall.Errno = 0x2
 	EAGAIN        syscall.Errno = 0x6
+	EBADF         syscall.Errno = 0x8
 	ECANCELED     syscall.Errno = 0xB
 	EDOM          syscall.Errno = 0x12
+	EFAULT        syscall.Errno = 0x15
 	EINPROGRESS   syscall.Errno = 0x1A
 	EINTR         syscall.Errno = 0x1B
 	EINVAL        syscall.Errno = 0x1C
@@ -21,9 +24,16 @@ const (
 	ENOTCONN      syscall.Errno = 0x35
 	ETIMEDOUT     syscall.Errno = 0x49
 	EADDRNOTAVAIL syscall.Errno = 0x63
+	ECONNABORTED  syscall.Errno = 0xD
 	ECONNREFUSED  syscall.Errno = 0xE
+	ECONNRESET    syscall.Errno = 0xF
 	ENOENT        syscall.Errno = 0x2C
 	EOPNOTSUPP    syscall.Errno = 0x3A
+	EPERM         syscall.Errno = 0x3F
+	EPIPE         syscall.Errno = 0x40
+	EPROTO        syscall.Errno = 0x41
+	EHOSTUNREACH  syscall.Errno = 0x17
+	ENETUNREACH   syscall.Errno = 0x28
 )
 
 var mapped = map[syscall.Errno]syscall.Errno{
@@ -38,12 +48,22 @@ var mapped = map[syscall.Errno]syscall.Errno{
 	syscall.EINVAL:           EINVAL,
 	syscall.ETIMEDOUT:        ETIMEDOUT,
 	syscall.EDOM:             EDOM,
+	syscall.EFAULT:           EFAULT,
 	syscall.EMFILE:           EMFILE,
 	syscall.ENOPROTOOPT:      ENOPROTOOPT,
 	syscall.ECONNREFUSED:     ECONNREFUSED,
 	syscall.ENOENT:           ENOENT,
 	syscall.EADDRNOTAVAIL:    EADDRNOTAVAIL,
 	syscall.EOPNOTSUPP:       EOPNOTSUPP,
+	syscall.EACCES:           EACCES,
+	syscall.EPERM:            EPERM,
+	syscall.ECONNABORTED:     ECONNABORTED,
+	syscall.EBADF:            EBADF,
+	syscall.ECONNRESET:       ECONNRESET,
+	syscall.EPIPE:            EPIPE,
+	syscall.EPROTO:           EPROTO,
+	syscall.EHOSTUNREACH:     EHOSTUNREACH,
+	syscall.ENETUNREACH:      ENETUNREACH,
 }
 
 // maps native codes to wasi codes.
@@ -100,12 +100,17 @@ func sock_send_to(
 //go:wasmimport wasinet_v0 sock_shutdown
 func sock_shutdown(fd, how int32) syscall.Errno
 
-//go:wasmimport wasinet_v0 sock_getaddrip
+//go:wasmimport wasinet_v0 sock_close
+func sock_close(fd int32) syscall.Errno
+
+//go:wasmimport wasinet_v0 sock_lookup_ip
 //go:noescape
-func sock_getaddrip(
+func sock_lookup_ip(
+	deadline int64,
 	networkptr unsafe.Pointer, networklen uint32,
 	addressptr unsafe.Pointer, addresslen uint32,
-	ipres unsafe.Pointer, maxResLen uint32, ipreslen unsafe.Pointer,
+	bufptr unsafe.Pointer, buflen uint32,
+	rescount unsafe.Pointer,
 ) syscall.Errno
 
 //go:wasmimport wasinet_v0 sock_getaddrport
@@ -116,6 +121,30 @@ func sock_getaddrport(
 	portptr unsafe.Pointer,
 ) syscall.Errno
 
+//go:wasmimport wasinet_v0 tls_trust_bundle
+//go:noescape
+func tls_trust_bundle(
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno
+
+//go:wasmimport wasinet_v0 dns_lookup
+//go:noescape
+func dns_lookup(
+	deadline int64,
+	rtype uint32,
+	nameptr unsafe.Pointer, namelen uint32,
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno
+
+//go:wasmimport wasinet_v0 sock_interfaces
+//go:noescape
+func sock_interfaces(
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno
+
 //go:wasmimport wasinet_v0 sock_determine_host_af_family
 //go:noescape
 func sock_determine_host_af_family(
@@ -17,6 +17,105 @@ import (
 
 // The native implementation ensure the api interopt is correct.
 
+// darwin's tcp states (netinet/tcp_fsm.h) translated into linux's numbering.
+var tcpstates = [...]uint32{
+	7,  // CLOSED
+	10, // LISTEN
+	2,  // SYN_SENT
+	3,  // SYN_RECEIVED
+	1,  // ESTABLISHED
+	8,  // CLOSE_WAIT
+	4,  // FIN_WAIT_1
+	11, // CLOSING
+	9,  // LAST_ACK
+	5,  // FIN_WAIT_2
+	6,  // TIME_WAIT
+}
+
+func tcpstate(s uint8) uint32 {
+	if int(s) < len(tcpstates) {
+		return tcpstates[s]
+	}
+
+	return 0
+}
+
+// NativeGetsockopt reads a socket option from the host, level and name use the abi's numbering.
+// the value is one of int, Timeval, Linger or TCPInfo.
+func NativeGetsockopt(fd, level, name int) (any, error) {
+	level, name = NativeSockopt(level, name)
+	switch {
+	case level == unix.SOL_SOCKET && name == unix.SO_LINGER_SEC:
+		l, err := unix.GetsockoptLinger(fd, level, name)
+		if err != nil {
+			return nil, err
+		}
+		return Linger{Onoff: l.Onoff, Linger: l.Linger}, nil
+	case level == unix.SOL_SOCKET && (name == unix.SO_RCVTIMEO || name == unix.SO_SNDTIMEO):
+		tv, err := unix.GetsockoptTimeval(fd, level, name)
+		if err != nil {
+			return nil, err
+		}
+		return Timeval{Sec: int64(tv.Sec), Usec: int64(tv.Usec)}, nil
+	case level == unix.IPPROTO_TCP && name == unix.TCP_CONNECTION_INFO:
+		info, err := unix.GetsockoptTCPConnectionInfo(fd, level, name)
+		if err != nil {
+			return nil, err
+		}
+		// darwin reports durations in milliseconds and windows in bytes.
+		mss := max(info.Maxseg, 1)
+		return TCPInfo{
+			State:        tcpstate(info.State),
+			RTO:          info.Rto * 1000,
+			RTT:          info.Srtt * 1000,
+			RTTVar:       info.Rttvar * 1000,
+			SndMSS:       info.Maxseg,
+			SndCwnd:      info.Snd_cwnd / mss,
+			SndSsthresh:  info.Snd_ssthresh / mss,
+			TotalRetrans: uint32(info.Txretransmitpackets),
+		}, nil
+	default:
+		return unix.GetsockoptInt(fd, level, name)
+	}
+}
+
+// NativeSetsockopt sets a socket option on the host, level, name and value use the abi's encoding.
+func NativeSetsockopt(fd, level, name int, value []byte) error {
+	level, name = NativeSockopt(level, name)
+	switch {
+	case level == unix.SOL_SOCKET && name == unix.SO_LINGER_SEC:
+		l, err := DecodeSockopt[Linger](value)
+		if err != nil {
+			return err
+		}
+		return unix.SetsockoptLinger(fd, level, name, &unix.Linger{Onoff: l.Onoff, Linger: l.Linger})
+	case level == unix.SOL_SOCKET && (name == unix.SO_RCVTIMEO || name == unix.SO_SNDTIMEO):
+		tv, err := DecodeSockopt[Timeval](value)
+		if err != nil {
+			return err
+		}
+		return unix.SetsockoptTimeval(fd, level, name, &unix.Timeval{Sec: tv.Sec, Usec: int32(tv.Usec)})
+	case level == unix.IPPROTO_IP && (name == unix.IP_ADD_MEMBERSHIP || name == unix.IP_DROP_MEMBERSHIP || name == unix.IP_MULTICAST_IF):
+		mreq, err := DecodeSockopt[IPMreqn](value)
+		if err != nil {
+			return err
+		}
+		return unix.SetsockoptIPMreqn(fd, level, name, &unix.IPMreqn{Multiaddr: mreq.Multiaddr, Address: mreq.Address, Ifindex: mreq.Ifindex})
+	case level == unix.IPPROTO_IPV6 && (name == unix.IPV6_JOIN_GROUP || name == unix.IPV6_LEAVE_GROUP):
+		mreq, err := DecodeSockopt[IPv6Mreq](value)
+		if err != nil {
+			return err
+		}
+		return unix.SetsockoptIPv6Mreq(fd, level, name, &unix.IPv6Mreq{Multiaddr: mreq.Multiaddr, Interface: mreq.Interface})
+	default:
+		v, err := DecodeSockopt[uint32](value)
+		if err != nil {
+			return err
+		}
+		return unix.SetsockoptInt(fd, level, name, int(v))
+	}
+}
+
 func sock_open(af int32, socktype int32, proto int32, fd unsafe.Pointer) syscall.Errno {
 	log.Println("sock_open", af, socktype, proto)
 	_fd, errno := unix.Socket(int(af), int(socktype), int(proto))
@@ -50,25 +149,31 @@ func sock_connect(fd int32, addr unsafe.Pointer, addrlen uint32) syscall.Errno {
 	return ffierrors.Errno(unix.Connect(int(fd), wsa))
 }
 
-func sock_getsockopt(fd int32, level uint32, name uint32, dst unsafe.Pointer, _ uint32) syscall.Errno {
-	switch name {
-	default:
-		v, err := unix.GetsockoptInt(int(fd), int(level), int(name))
-		errorsx.MaybePanic(ffi.Uint32Write(ffi.Native{}, dst, uint32(v)))
+func sock_getsockopt(fd int32, level uint32, name uint32, dst unsafe.Pointer, dstlen uint32) syscall.Errno {
+	v, err := NativeGetsockopt(int(fd), int(level), int(name))
+	if err != nil {
 		return ffierrors.Errno(err)
 	}
+
+	encoded, err := SockoptBytes(v)
+	if err != nil {
+		return ffierrors.Errno(err)
+	}
+
+	if uint32(len(encoded)) > dstlen {
+		return syscall.EINVAL
+	}
+
+	return ffierrors.Errno(ffi.BytesWrite(ffi.Native{}, encoded, dst, dstlen))
 }
 
 func sock_setsockopt(fd int32, level uint32, name uint32, valueptr unsafe.Pointer, valuelen uint32) syscall.Errno {
-	switch name {
-	case syscall.SO_LINGER: // this is untested.
-		value := ffi.UnsafeClone[unix.Timeval](valueptr)
-		return ffierrors.Errno(unix.SetsockoptTimeval(int(fd), int(level), int(name), &value))
-	default:
-		value := errorsx.Must(ffi.Uint32Read(ffi.Native{}, valueptr, valuelen))
-		log.Println("sock_setsockopt", fd, level, name, value)
-		return ffierrors.Errno(unix.SetsockoptInt(int(fd), int(level), int(name), int(value)))
+	value, err := ffi.BytesRead(ffi.Native{}, valueptr, valuelen)
+	if err != nil {
+		return ffierrors.Errno(err)
 	}
+
+	return ffierrors.Errno(NativeSetsockopt(int(fd), int(level), int(name), value))
 }
 
 func sock_getlocaladdr(fd int32, addrptr unsafe.Pointer, addrlen uint32) syscall.Errno {
@@ -111,13 +216,22 @@ func sock_recv_from(
 	fd int32,
 	iovs unsafe.Pointer, iovslen uint32,
 	oobptr unsafe.Pointer, ooblen uint32,
-	addrptr unsafe.Pointer, _addrlen uint32,
+	addrptr unsafe.Pointer, addrlen uint32,
 	iflags int32,
 	nread unsafe.Pointer,
 	oflags unsafe.Pointer,
 ) syscall.Errno {
 	oob := errorsx.Must(ffi.BytesRead(ffi.Native{}, oobptr, ooblen))
-	vecs := errorsx.Must(ffi.SliceRead[[]byte](ffi.Native{}, iovs, iovslen))
+	vec, err := ffi.SliceRead[ffi.Vector](ffi.Native{}, iovs, iovslen)
+	if err != nil {
+		return ffierrors.Errno(err)
+	}
+
+	vecs, err := ffi.VectorRead[byte](ffi.Native{}, vec...)
+	if err != nil {
+		return ffierrors.Errno(err)
+	}
+
 	for {
 		n, _, roflags, sa, err := unix.RecvmsgBuffers(int(fd), vecs, oob, int(iflags))
 		switch err {
@@ -135,7 +249,7 @@ func sock_recv_from(
 				return ffierrors.Errno(err)
 			}
 
-			if err := ffi.RawWrite(ffi.Native{}, &addr, addrptr, uint32(unsafe.Sizeof(addr))); err != nil {
+			if err := ffi.RawWrite(ffi.Native{}, addr, addrptr, addrlen); err != nil {
 				return ffierrors.Errno(err)
 			}
 		}
@@ -192,6 +306,10 @@ func sock_shutdown(fd, how int32) syscall.Errno {
 	return ffierrors.Errno(unix.Shutdown(int(fd), int(how)))
 }
 
+func sock_close(fd int32) syscall.Errno {
+	return ffierrors.Errno(unix.Close(int(fd)))
+}
+
 func sock_accept(fd int32, nfd unsafe.Pointer, addressptr unsafe.Pointer, addresslen uint32) (errno syscall.Errno) {
 	_nfd, sa, err := unix.Accept(int(fd))
 	if err != nil {
@@ -213,37 +331,37 @@ func sock_accept(fd int32, nfd unsafe.Pointer, addressptr unsafe.Pointer, addres
 	return ffierrors.Errno(nil)
 }
 
-func sock_getaddrip(
+// native programs resolve addresses using the default resolver, which doesn't expose ttls.
+func sock_lookup_ip(
+	deadline int64,
 	networkptr unsafe.Pointer, networklen uint32,
 	addressptr unsafe.Pointer, addresslen uint32,
-	ipres unsafe.Pointer, maxResLen uint32, ipreslen unsafe.Pointer,
+	bufptr unsafe.Pointer, buflen uint32,
+	rescount unsafe.Pointer,
 ) syscall.Errno {
-	var (
-		err error
-		ip  []net.IP
-		buf []byte
-	)
+	ctx, done := ffi.ContextDeadline(context.Background(), deadline)
+	defer done()
 
 	network := errorsx.Must(ffi.StringRead(ffi.Native{}, networkptr, networklen))
 	address := errorsx.Must(ffi.StringRead(ffi.Native{}, addressptr, addresslen))
-	if ip, err = net.DefaultResolver.LookupIP(context.Background(), network, address); err != nil {
-		return syscall.EINVAL
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, address)
+	if err != nil {
+		return LookupErrno(err)
 	}
 
-	reslength := len(ip)
-	if reslength*net.IPv6len > int(maxResLen) {
-		reslength = int(maxResLen / net.IPv6len)
+	records := make([]IPRecord, 0, len(ips))
+	for _, ip := range ips {
+		records = append(records, IPRecord{IP: ip})
 	}
 
-	buf = make([]byte, 0, maxResLen)
-	for _, i := range ip[:reslength] {
-		buf = append(buf, i.To16()...)
+	encoded := EncodeIPRecords(records...)
+	if len(encoded) <= int(buflen) {
+		if err = ffi.BytesWrite(ffi.Native{}, encoded, bufptr, buflen); err != nil {
+			return ffierrors.Errno(err)
+		}
 	}
 
-	*(*unsafe.Pointer)(ipres) = unsafe.Pointer(&buf[0])
-	*(*uint32)(ipreslen) = uint32(len(buf))
-
-	return 0
+	return ffierrors.Errno(ffi.Uint32Write(ffi.Native{}, rescount, uint32(len(records))))
 }
 
 func sock_getaddrport(
@@ -261,7 +379,7 @@ func sock_getaddrport(
 
 	log.Println("sock_getaddrport", network, service)
 	if port, err = net.DefaultResolver.LookupPort(context.Background(), network, service); err != nil {
-		return uint32(ffierrors.Errno(err))
+		return uint32(LookupErrno(err))
 	}
 
 	if err = ffi.Uint32Write(ffi.Native{}, portptr, uint32(port)); err != nil {
@@ -271,6 +389,61 @@ func sock_getaddrport(
 	return 0
 }
 
+// native programs use the system's trusted certificates directly.
+func tls_trust_bundle(
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno {
+	return ffierrors.Errno(syscall.ENOTSUP)
+}
+
+// native programs resolve records using the default resolver.
+func dns_lookup(
+	deadline int64,
+	rtype uint32,
+	nameptr unsafe.Pointer, namelen uint32,
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno {
+	ctx, done := ffi.ContextDeadline(context.Background(), deadline)
+	defer done()
+
+	name := errorsx.Must(ffi.StringRead(ffi.Native{}, nameptr, namelen))
+	res, err := NativeLookupRecords(ctx, net.DefaultResolver, int(rtype), name)
+	if err != nil {
+		return LookupErrno(err)
+	}
+
+	encoded := EncodeDNSRecords(res)
+	if len(encoded) <= int(buflen) {
+		if err = ffi.BytesWrite(ffi.Native{}, encoded, bufptr, buflen); err != nil {
+			return ffierrors.Errno(err)
+		}
+	}
+
+	return ffierrors.Errno(ffi.Uint32Write(ffi.Native{}, reslen, uint32(len(encoded))))
+}
+
+// native programs see every interface of the host.
+func sock_interfaces(
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno {
+	ifaces, err := NativeInterfaces()
+	if err != nil {
+		return ffierrors.Errno(err)
+	}
+
+	encoded := EncodeInterfaces(ifaces...)
+	if len(encoded) <= int(buflen) {
+		if err = ffi.BytesWrite(ffi.Native{}, encoded, bufptr, buflen); err != nil {
+			return ffierrors.Errno(err)
+		}
+	}
+
+	return ffierrors.Errno(ffi.Uint32Write(ffi.Native{}, reslen, uint32(len(encoded))))
+}
+
 // passthrough since there is no diffference.
 func sock_determine_host_af_family(
 	wasi int32,
@@ -42,7 +42,7 @@ func UnixSockaddr(v RawSocketAddress) (sa NativeSocket, err error) {
 	case *addressany[addrip4]:
 		return &unix.SockaddrInet4{Port: int(t.addr.port), Addr: t.addr.ip}, nil
 	case *addressany[addrip6]:
-		return &unix.SockaddrInet6{Port: int(t.addr.port), Addr: t.addr.ip, ZoneId: 0}, nil
+		return &unix.SockaddrInet6{Port: int(t.addr.port), Addr: t.addr.ip, ZoneId: t.addr.zone}, nil
 	case *addressany[addrunix]:
 		return &unix.SockaddrUnix{Name: t.addr.Path()}, nil
 	default:
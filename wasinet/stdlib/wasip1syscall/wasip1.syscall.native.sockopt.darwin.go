@@ -0,0 +1,51 @@
+//go:build !wasip1 && darwin
+
+package wasip1syscall
+
+import "golang.org/x/sys/unix"
+
+type sockopt struct {
+	level int
+	name  int
+}
+
+var sockopts = map[sockopt]sockopt{
+	{SOL_SOCKET, SO_REUSEADDR}:   {unix.SOL_SOCKET, unix.SO_REUSEADDR},
+	{SOL_SOCKET, SO_TYPE}:        {unix.SOL_SOCKET, unix.SO_TYPE},
+	{SOL_SOCKET, SO_ERROR}:       {unix.SOL_SOCKET, unix.SO_ERROR},
+	{SOL_SOCKET, SO_BROADCAST}:   {unix.SOL_SOCKET, unix.SO_BROADCAST},
+	{SOL_SOCKET, SO_SNDBUF}:      {unix.SOL_SOCKET, unix.SO_SNDBUF},
+	{SOL_SOCKET, SO_RCVBUF}:      {unix.SOL_SOCKET, unix.SO_RCVBUF},
+	{SOL_SOCKET, SO_KEEPALIVE}:   {unix.SOL_SOCKET, unix.SO_KEEPALIVE},
+	{SOL_SOCKET, SO_LINGER}:      {unix.SOL_SOCKET, unix.SO_LINGER_SEC},
+	{SOL_SOCKET, SO_REUSEPORT}:   {unix.SOL_SOCKET, unix.SO_REUSEPORT},
+	{SOL_SOCKET, SO_RCVTIMEO}:    {unix.SOL_SOCKET, unix.SO_RCVTIMEO},
+	{SOL_SOCKET, SO_SNDTIMEO}:    {unix.SOL_SOCKET, unix.SO_SNDTIMEO},
+	{IPPROTO_TCP, TCP_NODELAY}:   {unix.IPPROTO_TCP, unix.TCP_NODELAY},
+	{IPPROTO_TCP, TCP_KEEPIDLE}:  {unix.IPPROTO_TCP, unix.TCP_KEEPALIVE},
+	{IPPROTO_TCP, TCP_KEEPINTVL}: {unix.IPPROTO_TCP, unix.TCP_KEEPINTVL},
+	{IPPROTO_TCP, TCP_KEEPCNT}:   {unix.IPPROTO_TCP, unix.TCP_KEEPCNT},
+	{IPPROTO_TCP, TCP_INFO}:      {unix.IPPROTO_TCP, unix.TCP_CONNECTION_INFO},
+
+	{IPPROTO_IP, IP_MULTICAST_IF}:    {unix.IPPROTO_IP, unix.IP_MULTICAST_IF},
+	{IPPROTO_IP, IP_MULTICAST_TTL}:   {unix.IPPROTO_IP, unix.IP_MULTICAST_TTL},
+	{IPPROTO_IP, IP_MULTICAST_LOOP}:  {unix.IPPROTO_IP, unix.IP_MULTICAST_LOOP},
+	{IPPROTO_IP, IP_ADD_MEMBERSHIP}:  {unix.IPPROTO_IP, unix.IP_ADD_MEMBERSHIP},
+	{IPPROTO_IP, IP_DROP_MEMBERSHIP}: {unix.IPPROTO_IP, unix.IP_DROP_MEMBERSHIP},
+
+	{IPPROTO_IPV6, IPV6_MULTICAST_IF}:   {unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_IF},
+	{IPPROTO_IPV6, IPV6_MULTICAST_HOPS}: {unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS},
+	{IPPROTO_IPV6, IPV6_MULTICAST_LOOP}: {unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_LOOP},
+	{IPPROTO_IPV6, IPV6_JOIN_GROUP}:     {unix.IPPROTO_IPV6, unix.IPV6_JOIN_GROUP},
+	{IPPROTO_IPV6, IPV6_LEAVE_GROUP}:    {unix.IPPROTO_IPV6, unix.IPV6_LEAVE_GROUP},
+}
+
+// NativeSockopt translates a socket option level and name from the abi into the host's values.
+// the abi uses linux's numbering, unknown options are passed through unchanged.
+func NativeSockopt(level, name int) (int, int) {
+	if native, ok := sockopts[sockopt{level: level, name: name}]; ok {
+		return native.level, native.name
+	}
+
+	return level, name
+}
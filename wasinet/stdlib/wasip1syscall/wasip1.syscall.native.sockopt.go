@@ -0,0 +1,9 @@
+//go:build !wasip1 && !darwin
+
+package wasip1syscall
+
+// NativeSockopt translates a socket option level and name from the abi into the host's values.
+// the abi uses linux's numbering.
+func NativeSockopt(level, name int) (int, int) {
+	return level, name
+}
@@ -91,14 +91,20 @@ func sock_shutdown(fd, how int32) syscall.Errno {
 	return ffierrors.Errno(syscall.ENOTSUP)
 }
 
+func sock_close(fd int32) syscall.Errno {
+	return ffierrors.Errno(syscall.ENOTSUP)
+}
+
 func sock_accept(fd int32, nfd unsafe.Pointer, addressptr unsafe.Pointer, addresslen uint32) (errno syscall.Errno) {
 	return ffierrors.Errno(syscall.ENOTSUP)
 }
 
-func sock_getaddrip(
+func sock_lookup_ip(
+	deadline int64,
 	networkptr unsafe.Pointer, networklen uint32,
 	addressptr unsafe.Pointer, addresslen uint32,
-	ipres unsafe.Pointer, maxResLen uint32, ipreslen unsafe.Pointer,
+	bufptr unsafe.Pointer, buflen uint32,
+	rescount unsafe.Pointer,
 ) syscall.Errno {
 	return ffierrors.Errno(syscall.ENOTSUP)
 }
@@ -111,6 +117,32 @@ func sock_getaddrport(
 	return 0
 }
 
+// native programs use the system's trusted certificates directly.
+func tls_trust_bundle(
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno {
+	return ffierrors.Errno(syscall.ENOTSUP)
+}
+
+func dns_lookup(
+	deadline int64,
+	rtype uint32,
+	nameptr unsafe.Pointer, namelen uint32,
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno {
+	return ffierrors.Errno(syscall.ENOTSUP)
+}
+
+// interfaces are unavailable on unknown platforms.
+func sock_interfaces(
+	bufptr unsafe.Pointer, buflen uint32,
+	reslen unsafe.Pointer,
+) syscall.Errno {
+	return ffierrors.Errno(syscall.ENOTSUP)
+}
+
 // passthrough since there is no diffference.
 func sock_determine_host_af_family(
 	wasi int32,
@@ -5,7 +5,6 @@ import (
 	"log"
 	"net"
 	"net/netip"
-	"strconv"
 	"syscall"
 
 	"github.com/egdaemon/wasinet/wasinet/ffi"
@@ -79,13 +78,13 @@ func (t addrunix) Path() string {
 
 func NetaddrToRaw(family, soctype int, addr net.Addr) (*RawSocketAddress, error) {
 	ipaddr := func(ip net.IP, zone string, port int) (*RawSocketAddress, error) {
-		if ipv4 := ip.To4(); ipv4 != nil {
+		// ipv6 sockets address ipv4 destinations using ipv4 mapped addresses.
+		if ipv4 := ip.To4(); ipv4 != nil && int32(family) != AF().INET6 {
 			return addressany[addrip4]{family: uint16(family), soctype: uint16(soctype), addr: addrip4{ip: ([4]byte)(ipv4), port: uint32(port)}}.Sockaddr(), nil
-		} else if len(ip) == net.IPv6len {
-			zone, _ := strconv.Atoi(zone)
+		} else if ipv6 := ip.To16(); ipv6 != nil {
 			var addr = addrip6{
-				ip: ([16]byte)(ip), port: uint32(port),
-				zone: uint32(zone),
+				ip: ([16]byte)(ipv6), port: uint32(port),
+				zone: zoneindex(zone),
 			}
 			return addressany[addrip6]{family: uint16(family), soctype: uint16(soctype), addr: addr}.Sockaddr(), nil
 		} else {
@@ -145,11 +144,12 @@ func NetUnix(v RawSocketAddress) (addrPort *net.UnixAddr, err error) {
 }
 
 func NetipAddrPortToRaw(family, sotype int, nap netip.AddrPort) *RawSocketAddress {
-	if nap.Addr().Is4() || nap.Addr().Is4In6() {
-		a := addressany[addrip4]{family: uint16(family), soctype: uint16(sotype), addr: addrip4{port: uint32(nap.Port()), ip: nap.Addr().As4()}}
+	// ipv6 sockets address ipv4 destinations using ipv4 mapped addresses.
+	if (nap.Addr().Is4() || nap.Addr().Is4In6()) && int32(family) != AF().INET6 {
+		a := addressany[addrip4]{family: uint16(family), soctype: uint16(sotype), addr: addrip4{port: uint32(nap.Port()), ip: nap.Addr().Unmap().As4()}}
 		return a.Sockaddr()
 	} else {
-		a := addressany[addrip6]{family: uint16(family), soctype: uint16(sotype), addr: addrip6{port: uint32(nap.Port()), ip: nap.Addr().As16()}}
+		a := addressany[addrip6]{family: uint16(family), soctype: uint16(sotype), addr: addrip6{port: uint32(nap.Port()), ip: nap.Addr().As16(), zone: zoneindex(nap.Addr().Zone())}}
 		return a.Sockaddr()
 	}
 }
@@ -163,6 +163,8 @@ func UDPAddr(v RawSocketAddress) (addr *net.UDPAddr, err error) {
 	switch unknown := sockaddr.(type) {
 	case *addressany[addrip4]:
 		return &net.UDPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port)}, nil
+	case *addressany[addrip6]:
+		return &net.UDPAddr{IP: unknown.addr.ip[:], Port: int(unknown.addr.port), Zone: zonename(unknown.addr.zone)}, nil
 	default:
 		log.Printf("unsupported address %T\n", unknown)
 		return nil, syscall.EINVAL
@@ -179,7 +181,7 @@ func Netipaddrport(v RawSocketAddress) (addrPort netip.AddrPort, err error) {
 	case *addressany[addrip4]:
 		addrPort = netip.AddrPortFrom(netip.AddrFrom4(unknown.addr.ip), uint16(unknown.addr.port))
 	case *addressany[addrip6]:
-		addrPort = netip.AddrPortFrom(netip.AddrFrom16(unknown.addr.ip), uint16(unknown.addr.port))
+		addrPort = netip.AddrPortFrom(netip.AddrFrom16(unknown.addr.ip).WithZone(zonename(unknown.addr.zone)), uint16(unknown.addr.port))
 	default:
 		log.Printf("unsupported address %T\n", unknown)
 		return addrPort, syscall.EINVAL
@@ -213,3 +215,11 @@ func NetaddrAFFamily(addr net.Addr) int {
 
 	return translated(syscall.AF_INET)
 }
+
+func zoneindex(zone string) uint32 {
+	return uint32(ZoneIndex(zone))
+}
+
+func zonename(idx uint32) string {
+	return ZoneName(int(idx))
+}
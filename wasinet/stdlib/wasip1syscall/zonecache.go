@@ -0,0 +1,100 @@
+package wasip1syscall
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ipv6ZoneCache maps ipv6 zones to interface indexes and back, every zoned
+// address would otherwise retrieve the interfaces from the host.
+type ipv6ZoneCache struct {
+	sync.RWMutex                // guard the following
+	lastFetched  time.Time      // last time routing information was fetched
+	toIndex      map[string]int // interface name to its index
+	toName       map[int]string // interface index to its name
+}
+
+var zoneCache = ipv6ZoneCache{
+	toIndex: make(map[string]int),
+	toName:  make(map[int]string),
+}
+
+// update refreshes the network interface information if the cache was last
+// updated more than 1 minute ago, or if force is set. It reports whether the
+// cache was updated.
+func (zc *ipv6ZoneCache) update(force bool) (updated bool) {
+	zc.Lock()
+	defer zc.Unlock()
+	now := time.Now()
+	if !force && zc.lastFetched.After(now.Add(-60*time.Second)) {
+		return false
+	}
+	zc.lastFetched = now
+	ifaces, err := Interfaces()
+	if err != nil {
+		return false
+	}
+	zc.toIndex = make(map[string]int, len(ifaces))
+	zc.toName = make(map[int]string, len(ifaces))
+	for _, ifi := range ifaces {
+		zc.toIndex[ifi.Name] = ifi.Index
+		if _, ok := zc.toName[ifi.Index]; !ok {
+			zc.toName[ifi.Index] = ifi.Name
+		}
+	}
+	return true
+}
+
+func (zc *ipv6ZoneCache) name(index int) string {
+	if index == 0 {
+		return ""
+	}
+	updated := zc.update(false)
+	zc.RLock()
+	name, ok := zc.toName[index]
+	zc.RUnlock()
+	if !ok && !updated {
+		zc.update(true)
+		zc.RLock()
+		name, ok = zc.toName[index]
+		zc.RUnlock()
+	}
+	if !ok { // last resort
+		name = strconv.Itoa(index)
+	}
+	return name
+}
+
+func (zc *ipv6ZoneCache) index(name string) int {
+	if name == "" {
+		return 0
+	}
+	updated := zc.update(false)
+	zc.RLock()
+	index, ok := zc.toIndex[name]
+	zc.RUnlock()
+	if !ok && !updated {
+		zc.update(true)
+		zc.RLock()
+		index, ok = zc.toIndex[name]
+		zc.RUnlock()
+	}
+	if !ok { // last resort
+		idx, _ := strconv.ParseUint(name, 10, 32)
+		index = int(idx)
+	}
+	return index
+}
+
+// ZoneIndex converts an ipv6 zone into its scope id, zones are either
+// interface names or numeric indexes.
+func ZoneIndex(zone string) int {
+	return zoneCache.index(zone)
+}
+
+// ZoneName converts an ipv6 scope id into a zone, the interface name
+// when it is known otherwise the numeric index.
+func ZoneName(idx int) string {
+	return zoneCache.name(idx)
+}
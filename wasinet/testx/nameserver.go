@@ -0,0 +1,144 @@
+package testx
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+)
+
+// dns record types served by Nameserver.
+const (
+	DNSTypeA     = 1
+	DNSTypeNS    = 2
+	DNSTypeCNAME = 5
+	DNSTypePTR   = 12
+	DNSTypeMX    = 15
+	DNSTypeTXT   = 16
+	DNSTypeSRV   = 33
+)
+
+// DNSRecord a resource record served by Nameserver, data is the record's wire encoding.
+type DNSRecord struct {
+	Type uint16
+	Data []byte
+}
+
+// DNSA an address record.
+func DNSA(ip net.IP) DNSRecord {
+	return DNSRecord{Type: DNSTypeA, Data: ip.To4()}
+}
+
+// DNSName a record whose data is a single name, e.g. NS, CNAME and PTR records.
+func DNSName(rtype uint16, name string) DNSRecord {
+	return DNSRecord{Type: rtype, Data: dnsname(nil, name)}
+}
+
+// DNSMX a mail exchange record.
+func DNSMX(pref uint16, host string) DNSRecord {
+	return DNSRecord{Type: DNSTypeMX, Data: dnsname(binary.BigEndian.AppendUint16(nil, pref), host)}
+}
+
+// DNSTXT a text record.
+func DNSTXT(text string) DNSRecord {
+	return DNSRecord{Type: DNSTypeTXT, Data: append([]byte{byte(len(text))}, text...)}
+}
+
+// DNSSRV a service record.
+func DNSSRV(priority, weight, port uint16, target string) DNSRecord {
+	buf := binary.BigEndian.AppendUint16(nil, priority)
+	buf = binary.BigEndian.AppendUint16(buf, weight)
+	buf = binary.BigEndian.AppendUint16(buf, port)
+	return DNSRecord{Type: DNSTypeSRV, Data: dnsname(buf, target)}
+}
+
+// Nameserver returns a resolver answering queries from the records, keyed by their fully
+// qualified name. unknown names are reported as nonexistent domains.
+func Nameserver(records map[string][]DNSRecord) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go nameserve(server, records)
+			return client, nil
+		},
+	}
+}
+
+// nameserve answers a single query, the pipe isn't a packet connection so the
+// resolver uses the length prefixed tcp framing.
+func nameserve(conn net.Conn, records map[string][]DNSRecord) {
+	defer conn.Close()
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return
+	}
+
+	query := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, query); err != nil || len(query) < 12 {
+		return
+	}
+
+	// the question immediately follows the header.
+	labels := []string{}
+	offset := 12
+	for offset < len(query) && query[offset] != 0 {
+		n := int(query[offset])
+		if offset+1+n > len(query) {
+			return
+		}
+		labels = append(labels, string(query[offset+1:offset+1+n]))
+		offset += 1 + n
+	}
+	offset += 1 + 4 // terminating label, type and class.
+	if offset > len(query) {
+		return
+	}
+
+	name := strings.ToLower(strings.Join(labels, ".")) + "."
+	qtype := binary.BigEndian.Uint16(query[offset-4:])
+
+	rrs, found := records[name]
+	flags := uint16(0x8580) // response, authoritative, recursion desired and available.
+	if !found {
+		flags |= 3 // nonexistent domain.
+	}
+
+	answers := []DNSRecord{}
+	for _, rr := range rrs {
+		if rr.Type == qtype {
+			answers = append(answers, rr)
+		}
+	}
+
+	resp := append([]byte(nil), query[:2]...)
+	resp = binary.BigEndian.AppendUint16(resp, flags)
+	resp = binary.BigEndian.AppendUint16(resp, 1)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(len(answers)))
+	resp = binary.BigEndian.AppendUint32(resp, 0)
+	resp = append(resp, query[12:offset]...)
+	for _, rr := range answers {
+		resp = dnsname(resp, name)
+		resp = binary.BigEndian.AppendUint16(resp, rr.Type)
+		resp = binary.BigEndian.AppendUint16(resp, 1)
+		resp = binary.BigEndian.AppendUint32(resp, 60)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(rr.Data)))
+		resp = append(resp, rr.Data...)
+	}
+
+	_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+}
+
+func dnsname(buf []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+
+	return append(buf, 0)
+}
@@ -0,0 +1,23 @@
+package wasinet
+
+import (
+	"crypto/x509"
+	"errors"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+// SystemCertPool builds a certificate pool from the certificates trusted by the host.
+func SystemCertPool() (*x509.CertPool, error) {
+	pem, err := wasip1syscall.TrustBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("host trust bundle contains no certificates")
+	}
+
+	return pool, nil
+}
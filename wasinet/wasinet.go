@@ -9,9 +9,26 @@ import (
 
 // hijack golang's networks net.DefaultResolver
 func Hijack() {
+	hijack(nil)
+}
+
+// HijackTrust hijacks golang's networks like Hijack, the default http transport
+// trusts the certificates provided by the host when they are available. opt in,
+// the host must provide tls_trust_bundle or the guest fails to instantiate.
+func HijackTrust() {
+	var tlsconfig *tls.Config
+	if pool, err := SystemCertPool(); err == nil {
+		tlsconfig = &tls.Config{RootCAs: pool}
+	}
+
+	hijack(tlsconfig)
+}
+
+func hijack(tlsconfig *tls.Config) {
 	net.DefaultResolver.Dial = DialContext
 	http.DefaultTransport = &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: tlsconfig,
+		Proxy:           http.ProxyFromEnvironment,
 		DialContext: (&Dialer{
 			Timeout: 2 * time.Second,
 		}).DialContext,
@@ -26,6 +43,8 @@ func Hijack() {
 }
 
 // internal only. use at your own risk
+//
+// Deprecated: HijackTrust configures the default transport with the host's trusted certificates.
 func InsecureHTTP() *http.Transport {
 	return &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
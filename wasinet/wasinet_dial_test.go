@@ -2,12 +2,14 @@ package wasinet_test
 
 import (
 	"context"
+	"errors"
 	"net"
 	"path/filepath"
 	"syscall"
 	"testing"
 
 	"github.com/egdaemon/wasinet/wasinet"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 	"github.com/egdaemon/wasinet/wasinet/testx"
 	"github.com/stretchr/testify/require"
 )
@@ -95,3 +97,132 @@ func TestDialTCPNoService(t *testing.T) {
 	// close tcp 0.0.0.0:45767: use of closed network connection
 	checkDialErr(ctx, t, l, &net.OpError{Op: "dial", Net: "tcp", Addr: l.Addr(), Err: syscall.ECONNREFUSED})
 }
+
+func freeport(t testing.TB, network string) int {
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket(network, "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port
+	default:
+		li, err := net.Listen(network, "127.0.0.1:0")
+		require.NoError(t, err)
+		defer li.Close()
+		return li.Addr().(*net.TCPAddr).Port
+	}
+}
+
+func TestDialLocalAddrTCP(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		conn, err := li.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		defer conn.Close()
+		accepted <- conn.RemoteAddr()
+	}()
+
+	local := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freeport(t, "tcp")}
+	d := wasinet.Dialer{LocalAddr: local}
+	conn, err := d.DialContext(ctx, "tcp", li.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, local.String(), conn.LocalAddr().String())
+	require.Equal(t, local.String(), (<-accepted).String())
+}
+
+func TestDialLocalAddrUDP(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	d := wasinet.Dialer{LocalAddr: &net.UDPAddr{Port: freeport(t, "udp")}}
+	conn, err := d.DialContext(ctx, "udp", li.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 16)
+	_, from, err := li.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, d.LocalAddr.(*net.UDPAddr).Port, from.(*net.UDPAddr).Port)
+}
+
+func TestDialLocalAddrMismatched(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li := listenstream(t, "tcp", "127.0.0.1:0")
+
+	addrerr := new(net.AddrError)
+	d := wasinet.Dialer{LocalAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}}
+	_, err := d.DialContext(ctx, "tcp", li.Addr().String())
+	require.ErrorAs(t, err, &addrerr)
+	require.Equal(t, "mismatched local address type", addrerr.Err)
+
+	d = wasinet.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv6loopback}}
+	_, err = d.DialContext(ctx, "tcp", li.Addr().String())
+	require.ErrorAs(t, err, &addrerr)
+	require.Equal(t, "no suitable address found", addrerr.Err)
+}
+
+func sockoptint(t testing.TB, c syscall.Conn, level, opt int) (value int) {
+	raw, err := c.SyscallConn()
+	require.NoError(t, err)
+	require.NoError(t, raw.Control(func(fd uintptr) {
+		value, err = wasip1syscall.GetSockoptInt(int(fd), level, opt)
+	}))
+	require.NoError(t, err)
+	return value
+}
+
+func TestDialControl(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li := listenstream(t, "tcp4", "127.0.0.1:0")
+
+	var network, address string
+	d := wasinet.Dialer{
+		ControlContext: func(ctx context.Context, n, a string, c syscall.RawConn) (err error) {
+			network, address = n, a
+			cerr := c.Control(func(fd uintptr) {
+				err = wasip1syscall.SetSockoptInt(int(fd), wasip1syscall.SOL_SOCKET, wasip1syscall.SO_KEEPALIVE, 1)
+			})
+			return errors.Join(cerr, err)
+		},
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", li.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "tcp4", network)
+	require.Equal(t, li.Addr().String(), address)
+	require.Equal(t, 1, sockoptint(t, conn.(syscall.Conn), wasip1syscall.SOL_SOCKET, wasip1syscall.SO_KEEPALIVE))
+
+	rejected := errors.New("rejected")
+	d = wasinet.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return rejected
+		},
+	}
+	_, err = d.DialContext(ctx, "tcp", li.Addr().String())
+	require.ErrorIs(t, err, rejected)
+}
@@ -1,9 +1,15 @@
 package wasinet_test
 
 import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/egdaemon/wasinet/wasinet"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 	"github.com/egdaemon/wasinet/wasinet/testx"
 	"github.com/stretchr/testify/require"
 )
@@ -64,3 +70,120 @@ func TestListenUDP6IPv6(t *testing.T) {
 func TestListenUnix(t *testing.T) {
 	checkListen(t, "unix", "derp.socks")
 }
+
+func TestListenControl(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	var networks []string
+	lc := wasinet.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) (err error) {
+			networks = append(networks, network)
+			cerr := c.Control(func(fd uintptr) {
+				err = wasip1syscall.SetSockoptInt(int(fd), wasip1syscall.SOL_SOCKET, wasip1syscall.SO_BROADCAST, 1)
+			})
+			return errors.Join(cerr, err)
+		},
+	}
+
+	li, err := lc.Listen(ctx, "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+	require.Equal(t, 1, sockoptint(t, li.(syscall.Conn), wasip1syscall.SOL_SOCKET, wasip1syscall.SO_BROADCAST))
+
+	pc, err := lc.ListenPacket(ctx, "udp", "[::1]:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	require.Equal(t, []string{"tcp4", "udp6"}, networks)
+
+	rejected := errors.New("rejected")
+	lc = wasinet.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return rejected
+		},
+	}
+	_, err = lc.Listen(ctx, "tcp", "127.0.0.1:0")
+	require.ErrorIs(t, err, rejected)
+}
+
+func acceptedsockopts(t *testing.T, lc wasinet.ListenConfig, opts ...[2]int) []int {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := lc.Listen(ctx, "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	client, err := net.Dial("tcp", li.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := li.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	values := make([]int, 0, len(opts))
+	for _, opt := range opts {
+		values = append(values, sockoptint(t, conn.(syscall.Conn), opt[0], opt[1]))
+	}
+
+	return values
+}
+
+func TestListenKeepAlive(t *testing.T) {
+	var (
+		keepalive = [2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_KEEPALIVE}
+		idle      = [2]int{wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPIDLE}
+		interval  = [2]int{wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPINTVL}
+		count     = [2]int{wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPCNT}
+	)
+
+	require.Equal(t, []int{1, 15, 15, 9}, acceptedsockopts(t, wasinet.ListenConfig{}, keepalive, idle, interval, count))
+	require.Equal(t, []int{1, 30, 15}, acceptedsockopts(t, wasinet.ListenConfig{KeepAlive: 30 * time.Second}, keepalive, idle, interval))
+	require.Equal(t, []int{0}, acceptedsockopts(t, wasinet.ListenConfig{KeepAlive: -1}, keepalive))
+
+	config := net.KeepAliveConfig{Enable: true, Idle: 5 * time.Second, Interval: 2 * time.Second, Count: 3}
+	require.Equal(t, []int{1, 5, 2, 3}, acceptedsockopts(t, wasinet.ListenConfig{KeepAlive: -1, KeepAliveConfig: config}, keepalive, idle, interval, count))
+}
+
+func TestListenConcreteType(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := wasinet.Listen(ctx, "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	tl, ok := li.(*net.TCPListener)
+	require.True(t, ok, "%T", li)
+	require.NoError(t, tl.SetDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = tl.AcceptTCP()
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestListenReusePort(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	lc := wasinet.ListenConfig{ReusePort: true, Backlog: 1024}
+	li, err := lc.Listen(ctx, "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+	require.Equal(t, 1, sockoptint(t, li.(syscall.Conn), wasip1syscall.SOL_SOCKET, wasip1syscall.SO_REUSEPORT))
+
+	shared, err := lc.Listen(ctx, "tcp", li.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, shared.Close())
+
+	_, err = wasinet.Listen(ctx, "tcp", li.Addr().String())
+	require.ErrorIs(t, err, syscall.EADDRINUSE)
+
+	pc, err := lc.ListenPacket(ctx, "udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	spc, err := lc.ListenPacket(ctx, "udp", pc.LocalAddr().String())
+	require.NoError(t, err)
+	require.NoError(t, spc.Close())
+}
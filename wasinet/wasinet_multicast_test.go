@@ -0,0 +1,64 @@
+package wasinet_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/stretchr/testify/require"
+)
+
+// multicastiface returns a multicast capable interface and one of its ipv4 addresses.
+func multicastiface(t testing.TB) (*net.Interface, net.IP) {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		require.NoError(t, err)
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				return &iface, ipnet.IP
+			}
+		}
+	}
+
+	t.Skip("no multicast capable interface available")
+	return nil, nil
+}
+
+func TestListenMulticastUDP(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	ifi, ifaddr := multicastiface(t)
+	group := &net.UDPAddr{IP: net.IPv4(239, 255, 77, 77), Port: freeport(t, "udp")}
+
+	pc, err := wasinet.ListenMulticastUDP(ctx, "udp4", ifi, group)
+	require.NoError(t, err)
+	defer pc.Close()
+
+	// the sender's bound address selects the outgoing interface.
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: ifaddr})
+	require.NoError(t, err)
+	defer sender.Close()
+
+	_, err = sender.WriteTo([]byte("hello"), group)
+	require.NoError(t, err)
+
+	buf := make([]byte, 128)
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, from, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf[:n]))
+	require.Equal(t, sender.LocalAddr().String(), from.String())
+
+	require.NoError(t, wasinet.LeaveGroup(pc, ifi, group.IP))
+	require.NoError(t, wasinet.JoinGroup(pc, ifi, group.IP))
+}
@@ -0,0 +1,486 @@
+//go:build !wasip1 && !windows
+
+package vnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Listen announces on the virtual network, the network must be tcp, tcp4, tcp6 or unix.
+func (t *Network) Listen(network, address string) (net.Listener, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, &net.OpError{Op: "listen", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	family, sotype, sa, err := t.sockaddr(network, address, true)
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: err}
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	s := newsocket(family, sotype)
+	if err = t.bind(s, sa); err != nil {
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: netaddr(sotype, sa), Err: os.NewSyscallError("bind", err)}
+	}
+
+	if err = t.listen(s); err != nil {
+		t.close(s)
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: netaddr(sotype, sa), Err: os.NewSyscallError("listen", err)}
+	}
+
+	return &listener{n: t, s: s, network: network}, nil
+}
+
+// ListenPacket announces on the virtual network, the network must be udp, udp4, udp6 or unixgram.
+func (t *Network) ListenPacket(network, address string) (net.PacketConn, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, &net.OpError{Op: "listen", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	family, sotype, sa, err := t.sockaddr(network, address, true)
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: err}
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	s := newsocket(family, sotype)
+	if err = t.bind(s, sa); err != nil {
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: netaddr(sotype, sa), Err: os.NewSyscallError("bind", err)}
+	}
+
+	return &conn{n: t, s: s, network: network}, nil
+}
+
+// Dial connects to the address on the virtual network.
+func (t *Network) Dial(network, address string) (net.Conn, error) {
+	return t.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the virtual network.
+// connections are established immediately or refused.
+func (t *Network) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	family, sotype, sa, err := t.sockaddr(network, address, false)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	s := newsocket(family, sotype)
+	if err = t.connect(s, sa); err != nil {
+		t.close(s)
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: netaddr(sotype, sa), Err: os.NewSyscallError("connect", err)}
+	}
+
+	return &conn{n: t, s: s, network: network}, nil
+}
+
+// sockaddr resolves the address of a host side socket.
+func (t *Network) sockaddr(network, address string, listen bool) (family int, sotype int, sa unix.Sockaddr, err error) {
+	switch network {
+	case "unix":
+		return syscall.AF_UNIX, syscall.SOCK_STREAM, &unix.SockaddrUnix{Name: address}, nil
+	case "unixgram":
+		return syscall.AF_UNIX, syscall.SOCK_DGRAM, &unix.SockaddrUnix{Name: address}, nil
+	case "udp", "udp4", "udp6":
+		sotype = syscall.SOCK_DGRAM
+	default:
+		sotype = syscall.SOCK_STREAM
+	}
+
+	host, service, err := net.SplitHostPort(address)
+	if err != nil {
+		return family, sotype, nil, err
+	}
+
+	port, err := net.LookupPort(network, service)
+	if err != nil {
+		return family, sotype, nil, err
+	}
+
+	var (
+		ip net.IP
+	)
+
+	v6 := strings.HasSuffix(network, "6")
+	switch {
+	case host == "" && listen && v6:
+		ip = net.IPv6zero
+	case host == "" && listen:
+		ip = net.IPv4zero
+	case host == "" && v6:
+		ip = net.IPv6loopback
+	case host == "":
+		ip = net.IPv4(127, 0, 0, 1)
+	default:
+		ipnetwork := "ip"
+		if strings.HasSuffix(network, "4") {
+			ipnetwork = "ip4"
+		} else if v6 {
+			ipnetwork = "ip6"
+		}
+
+		ips, err := t.resolve(ipnetwork, host)
+		if err != nil {
+			return family, sotype, nil, err
+		}
+		ip = ips[0]
+	}
+
+	if ip4 := ip.To4(); ip4 != nil && !v6 {
+		return syscall.AF_INET, sotype, &unix.SockaddrInet4{Addr: [4]byte(ip4), Port: port}, nil
+	}
+
+	return syscall.AF_INET6, sotype, &unix.SockaddrInet6{Addr: [16]byte(ip.To16()), Port: port}, nil
+}
+
+func netaddr(sotype int, sa unix.Sockaddr) net.Addr {
+	var (
+		ip   net.IP
+		port int
+		zone string
+	)
+
+	switch actual := sa.(type) {
+	case *unix.SockaddrInet4:
+		ip, port = slices.Clone(actual.Addr[:]), actual.Port
+	case *unix.SockaddrInet6:
+		ip, port = slices.Clone(actual.Addr[:]), actual.Port
+		if actual.ZoneId != 0 {
+			zone = strconv.Itoa(int(actual.ZoneId))
+		}
+	case *unix.SockaddrUnix:
+		if sotype == syscall.SOCK_DGRAM {
+			return &net.UnixAddr{Name: actual.Name, Net: "unixgram"}
+		}
+		return &net.UnixAddr{Name: actual.Name, Net: "unix"}
+	default:
+		return nil
+	}
+
+	if sotype == syscall.SOCK_DGRAM {
+		return &net.UDPAddr{IP: ip, Port: port, Zone: zone}
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port, Zone: zone}
+}
+
+func netsockaddr(family int, addr net.Addr) (unix.Sockaddr, error) {
+	var (
+		ip   net.IP
+		port int
+	)
+
+	switch actual := addr.(type) {
+	case *net.UDPAddr:
+		ip, port = actual.IP, actual.Port
+	case *net.TCPAddr:
+		ip, port = actual.IP, actual.Port
+	case *net.UnixAddr:
+		return &unix.SockaddrUnix{Name: actual.Name}, nil
+	default:
+		return nil, syscall.EINVAL
+	}
+
+	if ip4 := ip.To4(); ip4 != nil && family == syscall.AF_INET {
+		return &unix.SockaddrInet4{Addr: [4]byte(ip4), Port: port}, nil
+	}
+
+	if ip16 := ip.To16(); ip16 != nil {
+		return &unix.SockaddrInet6{Addr: [16]byte(ip16), Port: port}, nil
+	}
+
+	return nil, syscall.EINVAL
+}
+
+// wait until the socket changes state or the deadline passes, must be called holding the lock.
+func (t *Network) wait(s *socket, deadline time.Time) error {
+	var (
+		timeout <-chan time.Time
+	)
+
+	changed := s.changed
+	t.m.Unlock()
+	defer t.m.Lock()
+
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-changed:
+		return nil
+	case <-timeout:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+func expired(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+type listener struct {
+	n       *Network
+	s       *socket
+	network string
+}
+
+func (t *listener) Accept() (net.Conn, error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	for {
+		if t.s.closed {
+			return nil, &net.OpError{Op: "accept", Net: t.network, Addr: netaddr(t.s.sotype, t.s.local), Err: net.ErrClosed}
+		}
+
+		c, err := t.n.accept(t.s)
+		if err == syscall.EAGAIN {
+			_ = t.n.wait(t.s, time.Time{})
+			continue
+		} else if err != nil {
+			return nil, &net.OpError{Op: "accept", Net: t.network, Addr: netaddr(t.s.sotype, t.s.local), Err: os.NewSyscallError("accept", err)}
+		}
+
+		return &conn{n: t.n, s: c, network: t.network}, nil
+	}
+}
+
+func (t *listener) Close() error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	if t.s.closed {
+		return &net.OpError{Op: "close", Net: t.network, Addr: netaddr(t.s.sotype, t.s.local), Err: net.ErrClosed}
+	}
+
+	t.n.close(t.s)
+	return nil
+}
+
+func (t *listener) Addr() net.Addr {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	return netaddr(t.s.sotype, t.s.local)
+}
+
+// conn is a host side connection, it implements both net.Conn and net.PacketConn.
+type conn struct {
+	n          *Network
+	s          *socket
+	network    string
+	rdeadline  time.Time
+	wrdeadline time.Time
+}
+
+func (t *conn) operr(op string, addr unix.Sockaddr, err error) error {
+	if errno, ok := err.(syscall.Errno); ok {
+		err = os.NewSyscallError(op, errno)
+	}
+
+	return &net.OpError{Op: op, Net: t.network, Source: netaddr(t.s.sotype, t.s.local), Addr: netaddr(t.s.sotype, addr), Err: err}
+}
+
+func (t *conn) recv(b []byte) (n int, from unix.Sockaddr, err error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	for {
+		if t.s.closed {
+			return 0, nil, net.ErrClosed
+		}
+
+		if expired(t.rdeadline) {
+			return 0, nil, os.ErrDeadlineExceeded
+		}
+
+		if n, _, from, err = t.s.recv([][]byte{b}); err != syscall.EAGAIN {
+			return n, from, err
+		}
+
+		if err = t.n.wait(t.s, t.rdeadline); err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+func (t *conn) send(b []byte, sa unix.Sockaddr) (n int, err error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	for {
+		if t.s.closed {
+			return n, net.ErrClosed
+		}
+
+		if expired(t.wrdeadline) {
+			return n, os.ErrDeadlineExceeded
+		}
+
+		c, err := t.n.send(t.s, sa, [][]byte{b[n:]})
+		n += c
+		if err != syscall.EAGAIN && (err != nil || n == len(b)) {
+			return n, err
+		}
+
+		// the peer's receive buffer is full, block until it reads.
+		if err = t.n.wait(t.s, t.wrdeadline); err != nil {
+			return n, err
+		}
+	}
+}
+
+func (t *conn) Read(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	if n, _, err = t.recv(b); err != nil {
+		return n, t.operr("read", t.s.peer, err)
+	}
+
+	if n == 0 && t.s.sotype == syscall.SOCK_STREAM {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+func (t *conn) Write(b []byte) (n int, err error) {
+	if n, err = t.send(b, nil); err != nil {
+		return n, t.operr("write", t.s.peer, err)
+	}
+
+	return n, nil
+}
+
+func (t *conn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, from, err := t.recv(b)
+	if err != nil {
+		return n, nil, t.operr("read", nil, err)
+	}
+
+	return n, netaddr(t.s.sotype, from), nil
+}
+
+func (t *conn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	sa, err := netsockaddr(t.s.family, addr)
+	if err != nil {
+		return 0, &net.OpError{Op: "write", Net: t.network, Addr: addr, Err: err}
+	}
+
+	if n, err = t.send(b, sa); err != nil {
+		return n, t.operr("write", sa, err)
+	}
+
+	return n, nil
+}
+
+func (t *conn) Close() error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	if t.s.closed {
+		return t.operr("close", t.s.peer, net.ErrClosed)
+	}
+
+	t.n.close(t.s)
+	return nil
+}
+
+func (t *conn) LocalAddr() net.Addr {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	return netaddr(t.s.sotype, t.s.local)
+}
+
+func (t *conn) RemoteAddr() net.Addr {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	return netaddr(t.s.sotype, t.s.peer)
+}
+
+func (t *conn) SetDeadline(d time.Time) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	t.rdeadline, t.wrdeadline = d, d
+	t.s.notify()
+	return nil
+}
+
+func (t *conn) SetReadDeadline(d time.Time) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	t.rdeadline = d
+	t.s.notify()
+	return nil
+}
+
+func (t *conn) SetWriteDeadline(d time.Time) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	t.wrdeadline = d
+	t.s.notify()
+	return nil
+}
+
+// CloseWrite shuts down the writing side of the connection.
+func (t *conn) CloseWrite() error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	if err := t.n.shutdown(t.s, syscall.SHUT_WR); err != nil {
+		return t.operr("close", t.s.peer, err)
+	}
+
+	return nil
+}
+
+// CloseRead shuts down the reading side of the connection.
+func (t *conn) CloseRead() error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	if err := t.n.shutdown(t.s, syscall.SHUT_RD); err != nil {
+		return t.operr("close", t.s.peer, err)
+	}
+
+	return nil
+}
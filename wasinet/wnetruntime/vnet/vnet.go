@@ -0,0 +1,873 @@
+//go:build !wasip1 && !windows
+
+// Package vnet provides an in memory network. Network.Socket implements
+// wnetruntime.Socket for guests while Network.Listen, Network.ListenPacket
+// and Network.Dial provide host side access. sockets never touch the host
+// kernel, guests and host code communicate over a virtual address space in
+// which every address is local. streams are reliable and bounded by the
+// receiver's SO_RCVBUF (128KiB by default), datagrams sent to an address
+// without a bound socket or that overflow the receive buffer are dropped.
+package vnet
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ephemeralmin = 49152
+	ephemeralmax = 65535
+)
+
+type Option func(*Network)
+
+// OptionHosts statically resolves hostnames to addresses within the network.
+// localhost always resolves to the loopback addresses.
+func OptionHosts(hosts map[string][]net.IP) Option {
+	return func(n *Network) {
+		for name, ips := range hosts {
+			n.hosts[hostname(name)] = ips
+		}
+	}
+}
+
+// OptionTrustBundle PEM encoded certificates trusted by guests on the network.
+// by default guests are not provided any trusted certificates.
+func OptionTrustBundle(pem []byte) Option {
+	return func(n *Network) {
+		n.trust = pem
+	}
+}
+
+// New virtual network.
+func New(opts ...Option) *Network {
+	n := &Network{
+		hosts: map[string][]net.IP{
+			"localhost": {net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		},
+		sockets: make(map[int]*socket),
+		bound:   make(map[bindkey]*socket),
+		nextfd:  1,
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// Network is an in memory network, a single network can be shared by
+// any number of guest modules and host side listeners and connections.
+type Network struct {
+	hosts     map[string][]net.IP
+	m         sync.Mutex
+	sockets   map[int]*socket
+	bound     map[bindkey]*socket
+	nextfd    int
+	ephemeral int
+	trust     []byte
+}
+
+// Socket the guest facing implementation of the network, every guest
+// module sharing the network can communicate with each other and with
+// host side listeners and connections.
+func (t *Network) Socket() wnetruntime.Socket {
+	return sockets{n: t}
+}
+
+type sockets struct {
+	n *Network
+}
+
+var _ wnetruntime.Socket = sockets{}
+
+func hostname(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func endpoint(sotype int, sa unix.Sockaddr) (bindkey, error) {
+	switch actual := sa.(type) {
+	case *unix.SockaddrInet4:
+		return bindkey{sotype: sotype, addr: netip.AddrFrom4(actual.Addr), port: actual.Port}, nil
+	case *unix.SockaddrInet6:
+		return bindkey{sotype: sotype, addr: netip.AddrFrom16(actual.Addr).Unmap(), port: actual.Port}, nil
+	case *unix.SockaddrUnix:
+		return bindkey{sotype: sotype, path: actual.Name}, nil
+	default:
+		return bindkey{}, syscall.EINVAL
+	}
+}
+
+// sockaddr converts the key into an address of the given family.
+func sockaddr(family int, k bindkey) unix.Sockaddr {
+	switch family {
+	case syscall.AF_UNIX:
+		return &unix.SockaddrUnix{Name: k.path}
+	case syscall.AF_INET6:
+		return &unix.SockaddrInet6{Addr: k.addr.As16(), Port: k.port}
+	default:
+		sa := &unix.SockaddrInet4{Port: k.port}
+		if k.addr.Is4() {
+			sa.Addr = k.addr.As4()
+		}
+		return sa
+	}
+}
+
+func unspecified(family int) netip.Addr {
+	if family == syscall.AF_INET6 {
+		return netip.IPv6Unspecified()
+	}
+
+	return netip.IPv4Unspecified()
+}
+
+func loopback(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		return netip.AddrFrom4([4]byte{127, 0, 0, 1})
+	}
+
+	return netip.IPv6Loopback()
+}
+
+// destination of a connect or sendto, the unspecified address is treated as loopback.
+func destination(s *socket, sa unix.Sockaddr) (k bindkey, err error) {
+	if k, err = endpoint(s.sotype, sa); err != nil {
+		return k, err
+	}
+
+	if (s.family == syscall.AF_UNIX) != (k.path != "") {
+		return k, syscall.EINVAL
+	}
+
+	if k.unspecified() {
+		k.addr = loopback(k.addr)
+	}
+
+	return k, nil
+}
+
+func (t *socket) key() bindkey {
+	if t.bound != nil {
+		return *t.bound
+	}
+
+	return bindkey{sotype: t.sotype}
+}
+
+func (t *Network) insert(s *socket) int {
+	fd := t.nextfd
+	t.nextfd++
+	t.sockets[fd] = s
+	return fd
+}
+
+func (t *Network) socket(fd int) (*socket, error) {
+	if s, ok := t.sockets[fd]; ok {
+		return s, nil
+	}
+
+	return nil, syscall.EBADF
+}
+
+func (t *Network) conflicts(k bindkey) bool {
+	if k.path != "" {
+		_, ok := t.bound[k]
+		return ok
+	}
+
+	for b := range t.bound {
+		if b.sotype != k.sotype || b.path != "" || b.port != k.port {
+			continue
+		}
+
+		if b.addr == k.addr || b.unspecified() || k.unspecified() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Network) register(s *socket, k bindkey) error {
+	if k.path == "" && k.port == 0 {
+		span := ephemeralmax - ephemeralmin + 1
+		for i := 0; ; i++ {
+			if i == span {
+				return syscall.EADDRINUSE
+			}
+
+			k.port = ephemeralmin + t.ephemeral%span
+			t.ephemeral++
+			if !t.conflicts(k) {
+				break
+			}
+		}
+	} else if t.conflicts(k) {
+		return syscall.EADDRINUSE
+	}
+
+	t.bound[k] = s
+	s.bound = &k
+	s.local = sockaddr(s.family, k)
+	return nil
+}
+
+// release the address bound by the socket, the local address remains visible.
+func (t *Network) release(s *socket) {
+	if s.bound == nil {
+		return
+	}
+
+	if t.bound[*s.bound] == s {
+		delete(t.bound, *s.bound)
+	}
+	s.bound = nil
+}
+
+// autobind assigns an ephemeral address to an unbound socket.
+// streams are bound to the address being connected to, datagrams
+// to the unspecified address.
+func (t *Network) autobind(s *socket, dst bindkey) error {
+	if s.local != nil {
+		return nil
+	}
+
+	if s.family == syscall.AF_UNIX {
+		// unnamed unix socket.
+		s.local = &unix.SockaddrUnix{}
+		return nil
+	}
+
+	k := bindkey{sotype: s.sotype, addr: unspecified(s.family)}
+	if s.sotype == syscall.SOCK_STREAM && dst.addr.IsValid() {
+		k.addr = dst.addr
+	}
+
+	return t.register(s, k)
+}
+
+// lookup the socket bound to the destination, sockets bound to the
+// unspecified address receive for every address of their family.
+func (t *Network) lookup(dst bindkey) *socket {
+	if s, ok := t.bound[dst]; ok {
+		return s
+	}
+
+	if dst.path != "" || !dst.addr.IsValid() {
+		return nil
+	}
+
+	candidates := []netip.Addr{netip.IPv6Unspecified()}
+	if dst.addr.Is4() {
+		candidates = append(candidates, netip.IPv4Unspecified())
+	}
+
+	for _, addr := range candidates {
+		k := dst
+		k.addr = addr
+		if s, ok := t.bound[k]; ok {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (t *Network) open(af, socktype int) (*socket, error) {
+	switch af {
+	case syscall.AF_INET, syscall.AF_INET6, syscall.AF_UNIX:
+	default:
+		return nil, syscall.EINVAL
+	}
+
+	switch socktype {
+	case syscall.SOCK_STREAM, syscall.SOCK_DGRAM:
+	default:
+		return nil, syscall.EINVAL
+	}
+
+	return newsocket(af, socktype), nil
+}
+
+func (t *Network) bind(s *socket, sa unix.Sockaddr) error {
+	if s.local != nil || s.closed {
+		return syscall.EINVAL
+	}
+
+	k, err := endpoint(s.sotype, sa)
+	if err != nil {
+		return err
+	}
+
+	if (s.family == syscall.AF_UNIX) != (k.path != "") {
+		return syscall.EINVAL
+	}
+
+	return t.register(s, k)
+}
+
+func (t *Network) connect(s *socket, sa unix.Sockaddr) error {
+	dst, err := destination(s, sa)
+	if err != nil {
+		return err
+	}
+
+	if s.sotype == syscall.SOCK_DGRAM {
+		if err = t.autobind(s, dst); err != nil {
+			return err
+		}
+
+		s.peer = sockaddr(s.family, dst)
+		return nil
+	}
+
+	if s.listening {
+		return syscall.EINVAL
+	}
+
+	if s.connected() {
+		return syscall.EISCONN
+	}
+
+	target := t.lookup(dst)
+	if target == nil || !target.listening {
+		return syscall.ECONNREFUSED
+	}
+
+	if err = t.autobind(s, dst); err != nil {
+		return err
+	}
+
+	// the accepted end of the connection, it shares the listener's
+	// port but does not own the binding.
+	serverkey := bindkey{sotype: s.sotype, path: dst.path}
+	if dst.path == "" {
+		serverkey.addr, serverkey.port = dst.addr, target.bound.port
+	}
+
+	server := newsocket(target.family, s.sotype)
+	server.local = sockaddr(target.family, serverkey)
+	server.peer = sockaddr(target.family, s.key())
+	server.remote = s
+
+	s.peer = sockaddr(s.family, serverkey)
+	s.remote = server
+
+	target.pending = append(target.pending, server)
+	target.notify()
+	s.notify()
+
+	return nil
+}
+
+func (t *Network) listen(s *socket) error {
+	if s.sotype != syscall.SOCK_STREAM {
+		return syscall.EOPNOTSUPP
+	}
+
+	if s.connected() || s.closed {
+		return syscall.EINVAL
+	}
+
+	if s.local == nil {
+		if s.family == syscall.AF_UNIX {
+			return syscall.EINVAL
+		}
+
+		if err := t.register(s, bindkey{sotype: s.sotype, addr: unspecified(s.family)}); err != nil {
+			return err
+		}
+	}
+
+	s.listening = true
+	return nil
+}
+
+func (t *Network) accept(s *socket) (*socket, error) {
+	if !s.listening {
+		return nil, syscall.EINVAL
+	}
+
+	if len(s.pending) == 0 {
+		return nil, syscall.EAGAIN
+	}
+
+	c := s.pending[0]
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+
+	return c, nil
+}
+
+func (t *Network) send(s *socket, sa unix.Sockaddr, vecs [][]byte) (int, error) {
+	if s.wrshut || s.closed {
+		return 0, syscall.EPIPE
+	}
+
+	if s.sotype == syscall.SOCK_STREAM {
+		// like tcp the address is ignored for connected streams.
+		if !s.connected() {
+			return 0, syscall.ENOTCONN
+		}
+
+		r := s.remote
+		if r == nil || r.closed || r.rdshut {
+			return 0, syscall.EPIPE
+		}
+
+		// like tcp writes are bounded by the peer's receive buffer,
+		// a full buffer accepts nothing until the peer reads.
+		space := r.available()
+		if space == 0 {
+			return 0, syscall.EAGAIN
+		}
+
+		n := 0
+		for _, v := range vecs {
+			c := min(len(v), space-n)
+			r.buf = append(r.buf, v[:c]...)
+			n += c
+		}
+		r.notify()
+
+		return n, nil
+	}
+
+	var (
+		dst bindkey
+		err error
+	)
+
+	switch {
+	case sa != nil:
+		if dst, err = destination(s, sa); err != nil {
+			return 0, err
+		}
+	case s.connected():
+		if dst, err = endpoint(s.sotype, s.peer); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, syscall.ENOTCONN
+	}
+
+	if err = t.autobind(s, dst); err != nil {
+		return 0, err
+	}
+
+	r := t.lookup(dst)
+	if r == nil || r.closed || r.rdshut {
+		// nothing is listening, the datagram is dropped.
+		return vecsize(vecs), nil
+	}
+
+	src := s.key()
+	if src.unspecified() {
+		src.addr = dst.addr
+	}
+
+	if r.available() < vecsize(vecs) {
+		// like udp datagrams that don't fit the receive buffer are dropped.
+		return vecsize(vecs), nil
+	}
+
+	r.dgrams = append(r.dgrams, datagram{from: sockaddr(r.family, src), data: vecjoin(vecs)})
+	r.notify()
+
+	return vecsize(vecs), nil
+}
+
+// unlisten stops accepting connections and resets any pending connections.
+func (t *Network) unlisten(s *socket) {
+	s.listening = false
+	for _, c := range s.pending {
+		t.close(c)
+	}
+	s.pending = nil
+	t.release(s)
+	s.notify()
+}
+
+func (t *Network) shutdown(s *socket, how int) error {
+	switch how {
+	case syscall.SHUT_RD, syscall.SHUT_WR, syscall.SHUT_RDWR:
+	default:
+		return syscall.EINVAL
+	}
+
+	// like linux shutting down a listener stops it from accepting connections.
+	if s.listening {
+		t.unlisten(s)
+		return nil
+	}
+
+	if s.sotype == syscall.SOCK_STREAM && !s.connected() {
+		return syscall.ENOTCONN
+	}
+
+	if how == syscall.SHUT_RD || how == syscall.SHUT_RDWR {
+		s.rdshut = true
+		s.buf = nil
+		s.dgrams = nil
+	}
+
+	if (how == syscall.SHUT_WR || how == syscall.SHUT_RDWR) && !s.wrshut {
+		s.wrshut = true
+		if r := s.remote; r != nil {
+			r.eof = true
+			r.notify()
+		}
+	}
+
+	if s.rdshut && s.wrshut {
+		t.release(s)
+	}
+
+	s.notify()
+	return nil
+}
+
+func (t *Network) close(s *socket) {
+	if s.closed {
+		return
+	}
+
+	if s.listening {
+		t.unlisten(s)
+	}
+
+	s.closed = true
+	s.buf = nil
+	s.dgrams = nil
+	if r := s.remote; r != nil {
+		r.eof = true
+		r.notify()
+	}
+
+	t.release(s)
+	s.notify()
+}
+
+func (t *Network) resolve(network, host string) ([]net.IP, error) {
+	var (
+		ips []net.IP
+	)
+
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		ips = t.hosts[hostname(host)]
+	}
+
+	filtered := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		switch network {
+		case "ip4":
+			if ip.To4() == nil {
+				continue
+			}
+		case "ip6":
+			if ip.To4() != nil {
+				continue
+			}
+		}
+
+		filtered = append(filtered, ip)
+	}
+
+	if len(filtered) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return filtered, nil
+}
+
+func (t sockets) Open(ctx context.Context, af, socktype, protocol int) (fd int, err error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.open(af, socktype)
+	if err != nil {
+		return -1, err
+	}
+
+	return t.n.insert(s), nil
+}
+
+func (t sockets) Bind(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.n.bind(s, sa)
+}
+
+func (t sockets) Connect(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.n.connect(s, sa)
+}
+
+func (t sockets) Listen(ctx context.Context, fd, backlog int) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.n.listen(s)
+}
+
+func (t sockets) Accept(ctx context.Context, fd int) (nfd int, sa unix.Sockaddr, err error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	c, err := t.n.accept(s)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	return t.n.insert(c), c.peer, nil
+}
+
+func (t sockets) LocalAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.local == nil {
+		return sockaddr(s.family, bindkey{addr: unspecified(s.family)}), nil
+	}
+
+	return s.local, nil
+}
+
+func (t sockets) PeerAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.peer == nil {
+		return nil, syscall.ENOTCONN
+	}
+
+	return s.peer, nil
+}
+
+func (t sockets) SetSocketOption(ctx context.Context, fd int, level, name int, value []byte) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return err
+	}
+
+	v, err := sockopt(level, name, value)
+	if err != nil {
+		return err
+	}
+
+	// options are recorded for the guest to read back, only the receive buffer
+	// affects the network. timeouts have no effect, virtual sockets never block.
+	s.options[[2]int{level, name}] = v
+	return nil
+}
+
+// sockopt decodes the option's value, level and name use the abi's numbering.
+func sockopt(level, name int, value []byte) (any, error) {
+	switch [2]int{level, name} {
+	case [2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_LINGER}:
+		return wasip1syscall.DecodeSockopt[wasip1syscall.Linger](value)
+	case [2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVTIMEO}, [2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_SNDTIMEO}:
+		return wasip1syscall.DecodeSockopt[wasip1syscall.Timeval](value)
+	case [2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_BINDTODEVICE}:
+		return strings.TrimRight(string(value), "\x00"), nil
+	case [2]int{wasip1syscall.IPPROTO_IP, wasip1syscall.IP_ADD_MEMBERSHIP},
+		[2]int{wasip1syscall.IPPROTO_IP, wasip1syscall.IP_DROP_MEMBERSHIP},
+		[2]int{wasip1syscall.IPPROTO_IP, wasip1syscall.IP_MULTICAST_IF}:
+		return wasip1syscall.DecodeSockopt[wasip1syscall.IPMreqn](value)
+	case [2]int{wasip1syscall.IPPROTO_IPV6, wasip1syscall.IPV6_JOIN_GROUP}, [2]int{wasip1syscall.IPPROTO_IPV6, wasip1syscall.IPV6_LEAVE_GROUP}:
+		return wasip1syscall.DecodeSockopt[wasip1syscall.IPv6Mreq](value)
+	default:
+		v, err := wasip1syscall.DecodeSockopt[uint32](value)
+		return int(v), err
+	}
+}
+
+func (t sockets) GetSocketOption(ctx context.Context, fd int, level, name int, value []byte) (any, error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	switch [2]int{level, name} {
+	case [2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_ERROR}:
+		return 0, nil
+	case [2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_TYPE}:
+		return s.sotype, nil
+	}
+
+	if v, ok := s.options[[2]int{level, name}]; ok {
+		return v, nil
+	}
+
+	return 0, nil
+}
+
+func (t sockets) Shutdown(ctx context.Context, fd, how int) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.n.shutdown(s, how)
+}
+
+func (t sockets) Close(ctx context.Context, fd int) error {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return err
+	}
+
+	delete(t.n.sockets, fd)
+	t.n.close(s)
+	return nil
+}
+
+func (t sockets) AddrIP(ctx context.Context, network string, address string) ([]net.IP, error) {
+	return t.n.resolve(network, address)
+}
+
+// LookupIP resolves the address within the network, the network doesn't assign ttls.
+func (t sockets) LookupIP(ctx context.Context, network string, address string) ([]wasip1syscall.IPRecord, error) {
+	ips, err := t.n.resolve(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]wasip1syscall.IPRecord, 0, len(ips))
+	for _, ip := range ips {
+		records = append(records, wasip1syscall.IPRecord{IP: ip})
+	}
+
+	return records, nil
+}
+
+func (t sockets) TrustBundle(ctx context.Context) ([]byte, error) {
+	return t.n.trust, nil
+}
+
+// LookupRecords the network only knows the addresses of its hosts, CNAME lookups
+// return the host's name and PTR lookups the names of the address.
+func (t sockets) LookupRecords(ctx context.Context, rtype int, name string) (res wasip1syscall.DNSRecords, err error) {
+	switch rtype {
+	case wasip1syscall.DNS_CNAME:
+		if _, err = t.n.resolve("ip", name); err != nil {
+			return res, err
+		}
+
+		return wasip1syscall.DNSRecords{CNAME: hostname(name) + "."}, nil
+	case wasip1syscall.DNS_PTR:
+		if ip := net.ParseIP(name); ip != nil {
+			for host, ips := range t.n.hosts {
+				if slices.ContainsFunc(ips, ip.Equal) {
+					res.Records = append(res.Records, wasip1syscall.DNSRecord{Target: host + "."})
+				}
+			}
+		}
+	}
+
+	if len(res.Records) == 0 {
+		return res, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return res, nil
+}
+
+// Interfaces every address of the network is local, so guests see a single loopback interface.
+func (t sockets) Interfaces(ctx context.Context) ([]wasip1syscall.Interface, error) {
+	return []wasip1syscall.Interface{{
+		Index: 1,
+		MTU:   65536,
+		Name:  "lo",
+		Flags: net.FlagUp | net.FlagLoopback | net.FlagMulticast | net.FlagRunning,
+		Addrs: []netip.Prefix{
+			netip.MustParsePrefix("127.0.0.1/8"),
+			netip.PrefixFrom(netip.IPv6Loopback(), 128),
+		},
+	}}, nil
+}
+
+func (t sockets) AddrPort(ctx context.Context, network string, service string) (int, error) {
+	return net.LookupPort(network, service)
+}
+
+func (t sockets) RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return s.recv(vecs)
+}
+
+func (t sockets) SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]byte, oob []byte, flags int) (int, error) {
+	t.n.m.Lock()
+	defer t.n.m.Unlock()
+
+	s, err := t.n.socket(fd)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.n.send(s, sa, vecs)
+}
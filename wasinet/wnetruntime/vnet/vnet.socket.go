@@ -0,0 +1,162 @@
+//go:build !wasip1 && !windows
+
+package vnet
+
+import (
+	"net/netip"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"golang.org/x/sys/unix"
+)
+
+// bindkey identifies a bound address, inet addresses use addr and port while
+// unix sockets use path.
+type bindkey struct {
+	sotype int
+	addr   netip.Addr
+	port   int
+	path   string
+}
+
+func (t bindkey) unspecified() bool {
+	return t.addr.IsValid() && t.addr.IsUnspecified()
+}
+
+type datagram struct {
+	from unix.Sockaddr
+	data []byte
+}
+
+// socket is the state of a single virtual socket, guarded by the network lock.
+type socket struct {
+	family    int
+	sotype    int
+	local     unix.Sockaddr // nil until bound.
+	peer      unix.Sockaddr // nil until connected.
+	bound     *bindkey      // nil when the socket has no registered address.
+	listening bool
+	pending   []*socket // connections waiting to be accepted by a listener.
+	remote    *socket   // the other end of a stream connection.
+	buf       []byte    // stream data waiting to be read.
+	dgrams    []datagram
+	rdshut    bool // reads have been shutdown locally.
+	wrshut    bool // writes have been shutdown locally.
+	eof       bool // the remote will not send any more data.
+	closed    bool
+	options   map[[2]int]any // values decoded from the abi, keyed by level and name.
+	changed   chan struct{}
+}
+
+// defaultbuffer the receive buffer size when SO_RCVBUF isn't set.
+const defaultbuffer = 128 * 1024
+
+func newsocket(family, sotype int) *socket {
+	return &socket{
+		family:  family,
+		sotype:  sotype,
+		options: make(map[[2]int]any),
+		changed: make(chan struct{}),
+	}
+}
+
+// notify anything waiting on the socket that its state has changed.
+func (t *socket) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+func (t *socket) connected() bool {
+	return t.peer != nil
+}
+
+// available space in the socket's receive buffer.
+func (t *socket) available() int {
+	size, ok := t.options[[2]int{wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVBUF}].(int)
+	if !ok || size <= 0 {
+		size = defaultbuffer
+	}
+
+	used := len(t.buf)
+	for _, d := range t.dgrams {
+		used += len(d.data)
+	}
+
+	return max(size-used, 0)
+}
+
+func (t *socket) recv(vecs [][]byte) (n int, flags int, from unix.Sockaddr, err error) {
+	if t.rdshut {
+		return 0, 0, t.peer, nil
+	}
+
+	switch t.sotype {
+	case syscall.SOCK_STREAM:
+		if t.listening || !t.connected() {
+			return 0, 0, nil, syscall.ENOTCONN
+		}
+
+		if len(t.buf) == 0 {
+			if t.eof {
+				return 0, 0, t.peer, nil
+			}
+
+			return 0, 0, nil, syscall.EAGAIN
+		}
+
+		for _, v := range vecs {
+			c := copy(v, t.buf)
+			t.buf = t.buf[c:]
+			n += c
+		}
+
+		if len(t.buf) == 0 {
+			t.buf = nil
+		}
+
+		// writers waiting for buffer space can proceed.
+		if t.remote != nil {
+			t.remote.notify()
+		}
+
+		return n, 0, t.peer, nil
+	default:
+		if len(t.dgrams) == 0 {
+			return 0, 0, nil, syscall.EAGAIN
+		}
+
+		d := t.dgrams[0]
+		t.dgrams[0] = datagram{}
+		t.dgrams = t.dgrams[1:]
+
+		remaining := d.data
+		for _, v := range vecs {
+			c := copy(v, remaining)
+			remaining = remaining[c:]
+			n += c
+		}
+
+		if len(remaining) > 0 {
+			flags |= syscall.MSG_TRUNC
+		}
+
+		return n, flags, d.from, nil
+	}
+}
+
+func vecsize(vecs [][]byte) (n int) {
+	for _, v := range vecs {
+		n += len(v)
+	}
+
+	return n
+}
+
+func vecjoin(vecs [][]byte) []byte {
+	buf := make([]byte, 0, vecsize(vecs))
+	for _, v := range vecs {
+		buf = append(buf, v...)
+	}
+
+	return buf
+}
@@ -0,0 +1,358 @@
+//go:build !wasip1 && !windows
+
+package vnet_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime/vnet"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func echo(t testing.TB, li net.Listener) {
+	t.Cleanup(func() {
+		li.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := li.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+}
+
+func checkEcho(t testing.TB, conn net.Conn, msg string) {
+	_, err := conn.Write([]byte(msg))
+	require.NoError(t, err)
+
+	buf := make([]byte, len(msg))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, msg, string(buf))
+}
+
+func TestStreamEcho(t *testing.T) {
+	n := vnet.New()
+
+	li, err := n.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	echo(t, li)
+
+	conn, err := n.Dial("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "10.0.0.1:80", conn.RemoteAddr().String())
+	checkEcho(t, conn, "hello world")
+}
+
+func TestStreamUnspecifiedListener(t *testing.T) {
+	n := vnet.New(vnet.OptionHosts(map[string][]net.IP{
+		"example.com": {net.IPv4(192, 168, 1, 1)},
+	}))
+
+	li, err := n.Listen("tcp", ":8080")
+	require.NoError(t, err)
+	echo(t, li)
+
+	conn, err := n.Dial("tcp", "example.com:8080")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "192.168.1.1:8080", conn.RemoteAddr().String())
+	checkEcho(t, conn, "hello world")
+}
+
+func TestStreamRefused(t *testing.T) {
+	n := vnet.New()
+
+	_, err := n.Dial("tcp", "10.0.0.1:80")
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	_, err = n.Dial("tcp", "unknown.example.com:80")
+	require.Error(t, err)
+}
+
+func TestAddressInUse(t *testing.T) {
+	n := vnet.New()
+
+	li, err := n.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+
+	_, err = n.Listen("tcp", "10.0.0.1:80")
+	require.ErrorIs(t, err, syscall.EADDRINUSE)
+	_, err = n.Listen("tcp", ":80")
+	require.ErrorIs(t, err, syscall.EADDRINUSE)
+
+	// different protocols do not conflict.
+	pc, err := n.ListenPacket("udp", "10.0.0.1:80")
+	require.NoError(t, err)
+	require.NoError(t, pc.Close())
+
+	require.NoError(t, li.Close())
+	li, err = n.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	require.NoError(t, li.Close())
+}
+
+func TestStreamEOF(t *testing.T) {
+	n := vnet.New()
+
+	li, err := n.Listen("unix", "/virtual/socket")
+	require.NoError(t, err)
+	defer li.Close()
+
+	client, err := n.Dial("unix", "/virtual/socket")
+	require.NoError(t, err)
+	defer client.Close()
+
+	server, err := li.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	_, err = client.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, client.(interface{ CloseWrite() error }).CloseWrite())
+
+	data, err := io.ReadAll(server)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	require.NoError(t, server.Close())
+	_, err = client.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadDeadline(t *testing.T) {
+	n := vnet.New()
+
+	li, err := n.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	echo(t, li)
+
+	conn, err := n.Dial("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	require.NoError(t, conn.SetReadDeadline(time.Time{}))
+	checkEcho(t, conn, "hello world")
+}
+
+func TestDatagram(t *testing.T) {
+	n := vnet.New()
+
+	pc, err := n.ListenPacket("udp", "10.0.0.1:53")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	conn, err := n.Dial("udp", "10.0.0.1:53")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// datagrams to addresses without a bound socket are dropped.
+	dropped, err := n.Dial("udp", "10.0.0.1:54")
+	require.NoError(t, err)
+	_, err = dropped.Write([]byte("dropped"))
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 16)
+	nread, from, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:nread]))
+	require.Equal(t, conn.LocalAddr().(*net.UDPAddr).Port, from.(*net.UDPAddr).Port)
+
+	_, err = pc.WriteTo([]byte("pong"), from)
+	require.NoError(t, err)
+
+	nread, err = conn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(buf[:nread]))
+}
+
+func TestSocketNonblocking(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := vnet.New()
+	s := n.Socket()
+
+	lfd, err := s.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Bind(ctx, lfd, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 80}))
+	require.NoError(t, s.Listen(ctx, lfd, 10))
+
+	_, _, err = s.Accept(ctx, lfd)
+	require.ErrorIs(t, err, syscall.EAGAIN)
+
+	cfd, err := s.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.ErrorIs(t, s.Connect(ctx, cfd, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 81}), syscall.ECONNREFUSED)
+	require.NoError(t, s.Connect(ctx, cfd, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 80}))
+
+	nfd, peer, err := s.Accept(ctx, lfd)
+	require.NoError(t, err)
+	local, err := s.LocalAddr(ctx, cfd)
+	require.NoError(t, err)
+	require.Equal(t, local, peer)
+
+	buf := make([]byte, 16)
+	_, _, _, err = s.RecvFrom(ctx, nfd, [][]byte{buf}, nil, 0)
+	require.ErrorIs(t, err, syscall.EAGAIN)
+
+	_, err = s.SendTo(ctx, cfd, nil, [][]byte{[]byte("hello")}, nil, 0)
+	require.NoError(t, err)
+
+	nread, _, _, err := s.RecvFrom(ctx, nfd, [][]byte{buf}, nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf[:nread]))
+
+	// interoperates with host side connections.
+	conn, err := n.DialContext(context.Background(), "tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	hfd, _, err := s.Accept(ctx, lfd)
+	require.NoError(t, err)
+	_, err = s.SendTo(ctx, hfd, nil, [][]byte{[]byte("world")}, nil, 0)
+	require.NoError(t, err)
+	nread, err = conn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(buf[:nread]))
+
+	require.NoError(t, s.Close(ctx, hfd))
+	_, err = conn.Read(buf)
+	require.ErrorIs(t, err, io.EOF)
+
+	_, err = s.LocalAddr(ctx, hfd)
+	require.ErrorIs(t, err, syscall.EBADF)
+}
+
+func TestStreamBufferBounded(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := vnet.New()
+	s := n.Socket()
+
+	lfd, err := s.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Bind(ctx, lfd, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 80}))
+	require.NoError(t, s.Listen(ctx, lfd, 10))
+
+	cfd, err := s.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Connect(ctx, cfd, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 80}))
+
+	nfd, _, err := s.Accept(ctx, lfd)
+	require.NoError(t, err)
+
+	size, err := wasip1syscall.SockoptBytes(16)
+	require.NoError(t, err)
+	require.NoError(t, s.SetSocketOption(ctx, nfd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVBUF, size))
+
+	// writes are truncated to the space left in the peer's receive buffer.
+	nwrite, err := s.SendTo(ctx, cfd, nil, [][]byte{make([]byte, 10), make([]byte, 10)}, nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, 16, nwrite)
+
+	_, err = s.SendTo(ctx, cfd, nil, [][]byte{[]byte("full")}, nil, 0)
+	require.ErrorIs(t, err, syscall.EAGAIN)
+
+	nread, _, _, err := s.RecvFrom(ctx, nfd, [][]byte{make([]byte, 8)}, nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, 8, nread)
+
+	nwrite, err = s.SendTo(ctx, cfd, nil, [][]byte{[]byte("ok")}, nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, nwrite)
+}
+
+func TestHostWriteBlocksOnFullBuffer(t *testing.T) {
+	n := vnet.New()
+
+	li, err := n.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer li.Close()
+
+	conn, err := n.Dial("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	peer, err := li.Accept()
+	require.NoError(t, err)
+	defer peer.Close()
+
+	msg := make([]byte, 256*1024)
+	require.NoError(t, conn.SetWriteDeadline(time.Now().Add(50*time.Millisecond)))
+	nwrite, err := conn.Write(msg)
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	require.Equal(t, 128*1024, nwrite)
+
+	// once the peer reads the remainder is delivered.
+	require.NoError(t, conn.SetWriteDeadline(time.Time{}))
+	go func() {
+		_, _ = conn.Write(msg[nwrite:])
+	}()
+
+	nread, err := io.ReadFull(peer, make([]byte, len(msg)))
+	require.NoError(t, err)
+	require.Equal(t, len(msg), nread)
+}
+
+func TestSocketOptionsByLevel(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := vnet.New()
+	s := n.Socket()
+
+	fd, err := s.Open(ctx, syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+
+	sotype, err := s.GetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_TYPE, nil)
+	require.NoError(t, err)
+	require.Equal(t, syscall.SOCK_DGRAM, sotype)
+
+	// struct options are decoded and returned as set.
+	mreq := wasip1syscall.IPMreqn{Multiaddr: [4]byte{224, 0, 0, 1}, Ifindex: 1}
+	encoded, err := wasip1syscall.SockoptBytes(mreq)
+	require.NoError(t, err)
+	require.NoError(t, s.SetSocketOption(ctx, fd, wasip1syscall.IPPROTO_IP, wasip1syscall.IP_ADD_MEMBERSHIP, encoded))
+	v, err := s.GetSocketOption(ctx, fd, wasip1syscall.IPPROTO_IP, wasip1syscall.IP_ADD_MEMBERSHIP, nil)
+	require.NoError(t, err)
+	require.Equal(t, mreq, v)
+
+	// the same name at a different level is a different option.
+	enabled, err := wasip1syscall.SockoptBytes(1)
+	require.NoError(t, err)
+	require.NoError(t, s.SetSocketOption(ctx, fd, wasip1syscall.IPPROTO_TCP, wasip1syscall.IP_ADD_MEMBERSHIP, enabled))
+	v, err = s.GetSocketOption(ctx, fd, wasip1syscall.IPPROTO_TCP, wasip1syscall.IP_ADD_MEMBERSHIP, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	require.ErrorIs(t, s.SetSocketOption(ctx, fd, wasip1syscall.IPPROTO_IP, wasip1syscall.IP_ADD_MEMBERSHIP, []byte{1}), syscall.EINVAL)
+}
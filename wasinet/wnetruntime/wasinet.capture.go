@@ -0,0 +1,388 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	pcapngSectionHeader    = 0x0A0D0D0A
+	pcapngInterface        = 0x00000001
+	pcapngEnhancedPacket   = 0x00000006
+	pcapngByteOrder        = 0x1A2B3C4D
+	pcapngLinkTypeEthernet = 1
+	pcapngOptionFlags      = 2
+	pcapngInbound          = 1
+	pcapngOutbound         = 2
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86DD
+	ethernetLen   = 14
+	ipv4Len       = 20
+	ipv6Len       = 40
+	tcpLen        = 20
+	udpLen        = 8
+	// largest payload that fits within a single ip packet with any of the synthesized headers.
+	maxsegment = 0xFFFF - ipv4Len - tcpLen
+)
+
+var (
+	// locally administered mac addresses for the guest and remote ends of the capture.
+	guestmac  = [6]byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	remotemac = [6]byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+)
+
+// Capture records every payload passing through SendTo and RecvFrom into the
+// writer as a pcapng stream. ethernet, ip, tcp and udp headers are synthesized
+// from the local and peer addresses of the file descriptor so the capture can
+// be opened by wireshark. only inet sockets are captured, tcp sequence numbers
+// are relative to the start of the capture. failures writing the capture are
+// logged and do not affect the socket.
+func Capture(s Socket, w io.Writer) (Socket, error) {
+	c := &capture{
+		Socket: s,
+		w:      w,
+		flows:  make(map[int]*flow),
+	}
+
+	if err := c.header(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// flow is the captured state of a single file descriptor.
+type flow struct {
+	sotype int
+	local  netip.AddrPort
+	peer   netip.AddrPort
+	sent   uint32 // next tcp sequence number sent by the guest.
+	recv   uint32 // next tcp sequence number sent by the peer.
+}
+
+type capture struct {
+	Socket
+	m     sync.Mutex
+	w     io.Writer
+	flows map[int]*flow
+}
+
+func (t *capture) Open(ctx context.Context, af, socktype, protocol int) (fd int, err error) {
+	if fd, err = t.Socket.Open(ctx, af, socktype, protocol); err != nil {
+		return fd, err
+	}
+
+	if af == syscall.AF_INET || af == syscall.AF_INET6 {
+		t.track(fd, socktype)
+	}
+
+	return fd, nil
+}
+
+func (t *capture) Accept(ctx context.Context, fd int) (nfd int, sa unix.Sockaddr, err error) {
+	if nfd, sa, err = t.Socket.Accept(ctx, fd); err != nil {
+		return nfd, sa, err
+	}
+
+	t.m.Lock()
+	f, ok := t.flows[fd]
+	t.m.Unlock()
+
+	if ok {
+		t.track(nfd, f.sotype)
+	}
+
+	return nfd, sa, nil
+}
+
+func (t *capture) SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]byte, oob []byte, flags int) (int, error) {
+	n, err := t.Socket.SendTo(ctx, fd, sa, vecs, oob, flags)
+	if err != nil {
+		return n, err
+	}
+
+	t.observe(ctx, fd, true, sa, truncatedcopy(vecs, n))
+	return n, nil
+}
+
+func (t *capture) RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error) {
+	n, oflags, sa, err := t.Socket.RecvFrom(ctx, fd, vecs, oob, flags)
+	if err != nil {
+		return n, oflags, sa, err
+	}
+
+	t.observe(ctx, fd, false, sa, truncatedcopy(vecs, n))
+	return n, oflags, sa, nil
+}
+
+func (t *capture) Close(ctx context.Context, fd int) error {
+	t.m.Lock()
+	delete(t.flows, fd)
+	t.m.Unlock()
+
+	return t.Socket.Close(ctx, fd)
+}
+
+func (t *capture) track(fd int, sotype int) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.flows[fd] = &flow{sotype: sotype, sent: 1, recv: 1}
+}
+
+// observe records the payload transferred by the file descriptor, sa is the
+// remote address provided to or returned by the socket.
+func (t *capture) observe(ctx context.Context, fd int, outbound bool, sa unix.Sockaddr, payload []byte) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	f, ok := t.flows[fd]
+	if !ok {
+		return
+	}
+
+	// an empty stream read is the end of the stream, not a packet.
+	if f.sotype == syscall.SOCK_STREAM && len(payload) == 0 {
+		return
+	}
+
+	// addresses are looked up until the socket is bound and connected,
+	// datagram sockets may never have a peer.
+	if f.local.Port() == 0 {
+		if local, err := t.Socket.LocalAddr(ctx, fd); err == nil {
+			f.local, _ = addrport(local)
+		}
+	}
+
+	if !f.peer.IsValid() {
+		if peer, err := t.Socket.PeerAddr(ctx, fd); err == nil {
+			f.peer, _ = addrport(peer)
+		}
+	}
+
+	remote := f.peer
+	if ap, ok := addrport(sa); ok && f.sotype != syscall.SOCK_STREAM {
+		remote = ap
+	}
+
+	src, dst := remote, f.local
+	if outbound {
+		src, dst = f.local, remote
+	}
+
+	ts := time.Now()
+	for {
+		segment := payload[:min(len(payload), maxsegment)]
+		payload = payload[len(segment):]
+
+		var pkt []byte
+		if f.sotype == syscall.SOCK_STREAM {
+			seq, ack := &f.recv, f.sent
+			if outbound {
+				seq, ack = &f.sent, f.recv
+			}
+			pkt = synthesize(syscall.IPPROTO_TCP, outbound, src, dst, *seq, ack, segment)
+			*seq += uint32(len(segment))
+		} else {
+			pkt = synthesize(syscall.IPPROTO_UDP, outbound, src, dst, 0, 0, segment)
+		}
+
+		if err := t.packet(ts, outbound, pkt); err != nil {
+			log.Println("unable to write packet capture", err)
+			return
+		}
+
+		// datagrams are never split.
+		if len(payload) == 0 || f.sotype != syscall.SOCK_STREAM {
+			return
+		}
+	}
+}
+
+func (t *capture) block(btype uint32, body []byte) error {
+	body = pad(body)
+	total := uint32(len(body) + 12)
+
+	buf := make([]byte, 0, total)
+	buf = binary.LittleEndian.AppendUint32(buf, btype)
+	buf = binary.LittleEndian.AppendUint32(buf, total)
+	buf = append(buf, body...)
+	buf = binary.LittleEndian.AppendUint32(buf, total)
+
+	_, err := t.w.Write(buf)
+	return err
+}
+
+// header writes the section header and the single ethernet interface every packet is recorded on.
+func (t *capture) header() error {
+	shb := make([]byte, 0, 16)
+	shb = binary.LittleEndian.AppendUint32(shb, pcapngByteOrder)
+	shb = binary.LittleEndian.AppendUint16(shb, 1) // major version.
+	shb = binary.LittleEndian.AppendUint16(shb, 0) // minor version.
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+	if err := t.block(pcapngSectionHeader, shb); err != nil {
+		return err
+	}
+
+	idb := make([]byte, 0, 8)
+	idb = binary.LittleEndian.AppendUint16(idb, pcapngLinkTypeEthernet)
+	idb = binary.LittleEndian.AppendUint16(idb, 0) // reserved.
+	idb = binary.LittleEndian.AppendUint32(idb, 0) // no snapshot length limit.
+	return t.block(pcapngInterface, idb)
+}
+
+func (t *capture) packet(ts time.Time, outbound bool, pkt []byte) error {
+	us := uint64(ts.UnixMicro())
+	direction := uint32(pcapngInbound)
+	if outbound {
+		direction = pcapngOutbound
+	}
+
+	epb := make([]byte, 0, 20+len(pkt)+16)
+	epb = binary.LittleEndian.AppendUint32(epb, 0) // interface id.
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(us>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(us))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(pkt)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(pkt)))
+	epb = pad(append(epb, pkt...))
+	epb = binary.LittleEndian.AppendUint16(epb, pcapngOptionFlags)
+	epb = binary.LittleEndian.AppendUint16(epb, 4)
+	epb = binary.LittleEndian.AppendUint32(epb, direction)
+	epb = binary.LittleEndian.AppendUint32(epb, 0) // end of options.
+
+	return t.block(pcapngEnhancedPacket, epb)
+}
+
+// pad the buffer to a 32 bit boundary.
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+
+	return b
+}
+
+func addrport(sa unix.Sockaddr) (netip.AddrPort, bool) {
+	switch actual := sa.(type) {
+	case *unix.SockaddrInet4:
+		return netip.AddrPortFrom(netip.AddrFrom4(actual.Addr), uint16(actual.Port)), true
+	case *unix.SockaddrInet6:
+		return netip.AddrPortFrom(netip.AddrFrom16(actual.Addr).Unmap(), uint16(actual.Port)), true
+	default:
+		return netip.AddrPort{}, false
+	}
+}
+
+// synthesize an ethernet frame carrying the payload from src to dst.
+func synthesize(proto int, outbound bool, src, dst netip.AddrPort, seq, ack uint32, payload []byte) []byte {
+	var transport []byte
+	switch proto {
+	case syscall.IPPROTO_TCP:
+		transport = make([]byte, 0, tcpLen+len(payload))
+		transport = binary.BigEndian.AppendUint16(transport, src.Port())
+		transport = binary.BigEndian.AppendUint16(transport, dst.Port())
+		transport = binary.BigEndian.AppendUint32(transport, seq)
+		transport = binary.BigEndian.AppendUint32(transport, ack)
+		transport = append(transport, (tcpLen/4)<<4, 0x18) // data offset, PSH|ACK.
+		transport = binary.BigEndian.AppendUint16(transport, 0xFFFF)
+		transport = binary.BigEndian.AppendUint32(transport, 0) // checksum, urgent pointer.
+	default:
+		transport = make([]byte, 0, udpLen+len(payload))
+		transport = binary.BigEndian.AppendUint16(transport, src.Port())
+		transport = binary.BigEndian.AppendUint16(transport, dst.Port())
+		transport = binary.BigEndian.AppendUint16(transport, uint16(udpLen+len(payload)))
+		transport = binary.BigEndian.AppendUint16(transport, 0) // checksum.
+	}
+	transport = append(transport, payload...)
+
+	srcmac, dstmac := remotemac, guestmac
+	if outbound {
+		srcmac, dstmac = guestmac, remotemac
+	}
+
+	frame := make([]byte, 0, ethernetLen+ipv6Len+len(transport))
+	frame = append(frame, dstmac[:]...)
+	frame = append(frame, srcmac[:]...)
+
+	saddr, daddr := src.Addr().Unmap(), dst.Addr().Unmap()
+	var pseudo []byte
+	if saddr.Is4() && daddr.Is4() {
+		s4, d4 := saddr.As4(), daddr.As4()
+		frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv4)
+
+		ip := make([]byte, 0, ipv4Len)
+		ip = append(ip, 0x45, 0) // version and header length, tos.
+		ip = binary.BigEndian.AppendUint16(ip, uint16(ipv4Len+len(transport)))
+		ip = binary.BigEndian.AppendUint32(ip, 0x00004000) // id, don't fragment.
+		ip = append(ip, 64, byte(proto))
+		ip = binary.BigEndian.AppendUint16(ip, 0) // checksum.
+		ip = append(ip, s4[:]...)
+		ip = append(ip, d4[:]...)
+		binary.BigEndian.PutUint16(ip[10:], checksum(sum(0, ip)))
+		frame = append(frame, ip...)
+
+		pseudo = append(append(s4[:], d4[:]...), 0, byte(proto))
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(transport)))
+	} else {
+		s16, d16 := saddr.As16(), daddr.As16()
+		frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv6)
+
+		ip := make([]byte, 0, ipv6Len)
+		ip = binary.BigEndian.AppendUint32(ip, 0x60000000)
+		ip = binary.BigEndian.AppendUint16(ip, uint16(len(transport)))
+		ip = append(ip, byte(proto), 64)
+		ip = append(ip, s16[:]...)
+		ip = append(ip, d16[:]...)
+		frame = append(frame, ip...)
+
+		pseudo = append(s16[:], d16[:]...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(transport)))
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(proto))
+	}
+
+	offset := 16 // tcp checksum.
+	if proto != syscall.IPPROTO_TCP {
+		offset = 6 // udp checksum.
+	}
+	cksum := checksum(sum(sum(0, pseudo), transport))
+	if cksum == 0 && proto != syscall.IPPROTO_TCP {
+		cksum = 0xFFFF // a zero udp checksum means no checksum.
+	}
+	binary.BigEndian.PutUint16(transport[offset:], cksum)
+
+	return append(frame, transport...)
+}
+
+// sum accumulates the 16 bit words of b for the internet checksum (RFC 1071).
+func sum(acc uint32, b []byte) uint32 {
+	for ; len(b) >= 2; b = b[2:] {
+		acc += uint32(b[0])<<8 | uint32(b[1])
+	}
+
+	if len(b) == 1 {
+		acc += uint32(b[0]) << 8
+	}
+
+	return acc
+}
+
+// checksum folds the accumulated sum into the internet checksum.
+func checksum(acc uint32) uint16 {
+	for acc > 0xFFFF {
+		acc = (acc >> 16) + (acc & 0xFFFF)
+	}
+
+	return ^uint16(acc)
+}
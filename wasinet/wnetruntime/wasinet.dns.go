@@ -0,0 +1,186 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"context"
+	"net"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/internal/langx"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+// dns controls how guest name lookups are resolved by the host.
+type dns struct {
+	resolver *net.Resolver
+	hosts    map[string][]net.IP
+	allow    []string
+	deny     []string
+	lifetime time.Duration
+}
+
+// OptionResolver resolves guest lookups using the provided resolver instead of net.DefaultResolver.
+func OptionResolver(r *net.Resolver) Option {
+	return func(n *network) {
+		n.dns.resolver = r
+	}
+}
+
+// OptionHosts static table of names that takes precedence over the resolver.
+func OptionHosts(hosts map[string][]net.IP) Option {
+	return func(n *network) {
+		n.dns.hosts = make(map[string][]net.IP, len(hosts))
+		for name, ips := range hosts {
+			n.dns.hosts[canonicalname(name)] = ips
+		}
+	}
+}
+
+// OptionDNSAllow restricts guest lookups to names matching one of the glob patterns,
+// e.g. *.svc.cluster.local. patterns use path.Match syntax.
+func OptionDNSAllow(patterns ...string) Option {
+	return func(n *network) {
+		n.dns.allow = append(n.dns.allow, patterns...)
+	}
+}
+
+// OptionDNSDeny rejects guest lookups of names matching one of the glob patterns,
+// denials take precedence over OptionDNSAllow. patterns use path.Match syntax.
+func OptionDNSDeny(patterns ...string) Option {
+	return func(n *network) {
+		n.dns.deny = append(n.dns.deny, patterns...)
+	}
+}
+
+// OptionDNSCacheLifetime how long guests may cache resolved addresses. the host's
+// resolver doesn't expose the ttl of records, the lifetime is imposed by the host
+// and reported for every address regardless of the record's actual ttl. by default
+// guests are told not to cache.
+func OptionDNSCacheLifetime(d time.Duration) Option {
+	return func(n *network) {
+		n.dns.lifetime = d
+	}
+}
+
+func canonicalname(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func matchname(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(canonicalname(p), name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func notfound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (t dns) permitted(name string) bool {
+	name = canonicalname(name)
+	if matchname(t.deny, name) {
+		return false
+	}
+
+	return len(t.allow) == 0 || matchname(t.allow, name)
+}
+
+func (t dns) lookupip(ctx context.Context, network string, address string) ([]net.IP, error) {
+	if !t.permitted(address) {
+		return nil, notfound(address)
+	}
+
+	if ips, ok := t.hosts[canonicalname(address)]; ok {
+		filtered := make([]net.IP, 0, len(ips))
+		for _, ip := range ips {
+			switch {
+			case network == "ip4" && ip.To4() == nil:
+			case network == "ip6" && ip.To4() != nil:
+			default:
+				filtered = append(filtered, ip)
+			}
+		}
+
+		if len(filtered) == 0 {
+			return nil, notfound(address)
+		}
+
+		return filtered, nil
+	}
+
+	return langx.DefaultIfZero(net.DefaultResolver, t.resolver).LookupIP(ctx, network, address)
+}
+
+func (t dns) lookupiprecords(ctx context.Context, network string, address string) ([]wasip1syscall.IPRecord, error) {
+	ips, err := t.lookupip(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]wasip1syscall.IPRecord, 0, len(ips))
+	for _, ip := range ips {
+		records = append(records, wasip1syscall.IPRecord{IP: ip, TTL: t.lifetime})
+	}
+
+	return records, nil
+}
+
+func (t dns) lookupport(ctx context.Context, network string, service string) (int, error) {
+	return langx.DefaultIfZero(net.DefaultResolver, t.resolver).LookupPort(ctx, network, service)
+}
+
+// lookup resolves records of the given type, names hidden by the policy are not found.
+// static hosts answer CNAME and PTR lookups, other record types are resolved by the resolver.
+func (t dns) lookup(ctx context.Context, rtype int, name string) (res wasip1syscall.DNSRecords, err error) {
+	if rtype == wasip1syscall.DNS_PTR {
+		return t.lookupaddr(ctx, name)
+	}
+
+	if !t.permitted(name) {
+		return res, notfound(name)
+	}
+
+	if _, ok := t.hosts[canonicalname(name)]; ok && rtype == wasip1syscall.DNS_CNAME {
+		return wasip1syscall.DNSRecords{CNAME: canonicalname(name) + "."}, nil
+	}
+
+	return wasip1syscall.NativeLookupRecords(ctx, langx.DefaultIfZero(net.DefaultResolver, t.resolver), rtype, name)
+}
+
+// lookupaddr reverse resolves the address, only names permitted by the policy are returned.
+func (t dns) lookupaddr(ctx context.Context, addr string) (res wasip1syscall.DNSRecords, err error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return res, &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+
+	for name, ips := range t.hosts {
+		if slices.ContainsFunc(ips, ip.Equal) {
+			res.Records = append(res.Records, wasip1syscall.DNSRecord{Target: name + "."})
+		}
+	}
+
+	if len(res.Records) == 0 {
+		if res, err = wasip1syscall.NativeLookupRecords(ctx, langx.DefaultIfZero(net.DefaultResolver, t.resolver), wasip1syscall.DNS_PTR, addr); err != nil {
+			return res, err
+		}
+	}
+
+	res.Records = slices.DeleteFunc(res.Records, func(r wasip1syscall.DNSRecord) bool {
+		return !t.permitted(r.Target)
+	})
+
+	if len(res.Records) == 0 {
+		return res, notfound(addr)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,336 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/internal/langx"
+	"golang.org/x/sys/unix"
+)
+
+type faults struct {
+	seed       uint64
+	latencymin time.Duration
+	latencymax time.Duration
+	reset      float64
+	timeout    float64
+	drop       float64
+	duplicate  float64
+	shortwrite float64
+}
+
+type FaultOption func(*faults)
+
+// OptionFaultSeed seeds the source of faults, identical seeds and
+// identical sequences of operations produce identical faults. defaults to 0.
+func OptionFaultSeed(seed uint64) FaultOption {
+	return func(f *faults) {
+		f.seed = seed
+	}
+}
+
+// OptionFaultLatency delays Connect and Accept by a uniformly distributed duration within [lo, hi].
+func OptionFaultLatency(lo, hi time.Duration) FaultOption {
+	return func(f *faults) {
+		f.latencymin, f.latencymax = lo, max(lo, hi)
+	}
+}
+
+// OptionFaultReset probability of a stream connection being reset (ECONNRESET)
+// on Connect, SendTo or RecvFrom. once reset, every subsequent operation on
+// the connection fails.
+func OptionFaultReset(p float64) FaultOption {
+	return func(f *faults) {
+		f.reset = p
+	}
+}
+
+// OptionFaultTimeout probability of Connect failing with ETIMEDOUT.
+func OptionFaultTimeout(p float64) FaultOption {
+	return func(f *faults) {
+		f.timeout = p
+	}
+}
+
+// OptionFaultDrop probability of a datagram being dropped by SendTo or RecvFrom.
+func OptionFaultDrop(p float64) FaultOption {
+	return func(f *faults) {
+		f.drop = p
+	}
+}
+
+// OptionFaultDuplicate probability of a datagram being delivered twice by SendTo or RecvFrom.
+func OptionFaultDuplicate(p float64) FaultOption {
+	return func(f *faults) {
+		f.duplicate = p
+	}
+}
+
+// OptionFaultShortWrite probability of a stream SendTo writing only part of the provided data.
+func OptionFaultShortWrite(p float64) FaultOption {
+	return func(f *faults) {
+		f.shortwrite = p
+	}
+}
+
+// Faulty decorates the socket with faults, used to reproduce unreliable
+// networks against guests. faults are only injected for the operations
+// described by the provided options.
+func Faulty(s Socket, opts ...FaultOption) Socket {
+	f := langx.Clone(faults{}, opts...)
+	return &faulty{
+		Socket:  s,
+		faults:  f,
+		rng:     rand.New(rand.NewPCG(f.seed, f.seed)),
+		sotypes: make(map[int]int),
+		resets:  make(map[int]struct{}),
+		dups:    make(map[int]duplicated),
+	}
+}
+
+type duplicated struct {
+	data  []byte
+	flags int
+	sa    unix.Sockaddr
+}
+
+type faulty struct {
+	Socket
+	faults
+	m       sync.Mutex
+	rng     *rand.Rand
+	sotypes map[int]int
+	resets  map[int]struct{}
+	dups    map[int]duplicated
+}
+
+// roll reports if a fault with probability p occurs.
+func (t *faulty) roll(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.rng.Float64() < p
+}
+
+func (t *faulty) delay(ctx context.Context) error {
+	if t.latencymax <= 0 {
+		return nil
+	}
+
+	t.m.Lock()
+	d := t.latencymin + time.Duration(t.rng.Int64N(int64(t.latencymax-t.latencymin)+1))
+	t.m.Unlock()
+
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return syscall.ECANCELED
+	}
+}
+
+func (t *faulty) sotype(fd int) int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if sotype, ok := t.sotypes[fd]; ok {
+		return sotype
+	}
+
+	return syscall.SOCK_STREAM
+}
+
+func (t *faulty) isreset(fd int) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+	_, ok := t.resets[fd]
+	return ok
+}
+
+// maybereset marks the connection as reset with the configured probability.
+func (t *faulty) maybereset(fd int) bool {
+	if t.isreset(fd) {
+		return true
+	}
+
+	if !t.roll(t.reset) {
+		return false
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.resets[fd] = struct{}{}
+	return true
+}
+
+func (t *faulty) Open(ctx context.Context, af, socktype, protocol int) (fd int, err error) {
+	if fd, err = t.Socket.Open(ctx, af, socktype, protocol); err != nil {
+		return fd, err
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.sotypes[fd] = socktype
+	return fd, nil
+}
+
+func (t *faulty) Connect(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	if err := t.delay(ctx); err != nil {
+		return err
+	}
+
+	if t.sotype(fd) == syscall.SOCK_STREAM {
+		if t.maybereset(fd) {
+			return syscall.ECONNRESET
+		}
+
+		if t.roll(t.timeout) {
+			return syscall.ETIMEDOUT
+		}
+	}
+
+	return t.Socket.Connect(ctx, fd, sa)
+}
+
+func (t *faulty) Accept(ctx context.Context, fd int) (nfd int, sa unix.Sockaddr, err error) {
+	if nfd, sa, err = t.Socket.Accept(ctx, fd); err != nil {
+		return nfd, sa, err
+	}
+
+	t.m.Lock()
+	t.sotypes[nfd] = t.sotypes[fd]
+	t.m.Unlock()
+
+	if err = t.delay(ctx); err != nil {
+		t.Close(ctx, nfd)
+		return -1, nil, err
+	}
+
+	return nfd, sa, nil
+}
+
+func (t *faulty) SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]byte, oob []byte, flags int) (int, error) {
+	if t.sotype(fd) == syscall.SOCK_DGRAM {
+		if t.roll(t.drop) {
+			return vecsize(vecs), nil
+		}
+
+		if t.roll(t.duplicate) {
+			if _, err := t.Socket.SendTo(ctx, fd, sa, vecs, oob, flags); err != nil {
+				return 0, err
+			}
+		}
+
+		return t.Socket.SendTo(ctx, fd, sa, vecs, oob, flags)
+	}
+
+	if t.maybereset(fd) {
+		return 0, syscall.ECONNRESET
+	}
+
+	if size := vecsize(vecs); size > 1 && t.roll(t.shortwrite) {
+		t.m.Lock()
+		n := 1 + t.rng.IntN(size-1)
+		t.m.Unlock()
+		vecs = truncate(vecs, n)
+	}
+
+	return t.Socket.SendTo(ctx, fd, sa, vecs, oob, flags)
+}
+
+func (t *faulty) RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error) {
+	if t.sotype(fd) == syscall.SOCK_STREAM {
+		if t.maybereset(fd) {
+			return 0, 0, nil, syscall.ECONNRESET
+		}
+
+		return t.Socket.RecvFrom(ctx, fd, vecs, oob, flags)
+	}
+
+	t.m.Lock()
+	d, ok := t.dups[fd]
+	delete(t.dups, fd)
+	t.m.Unlock()
+
+	if ok {
+		return copyvecs(vecs, d.data), d.flags, d.sa, nil
+	}
+
+	n, oflags, sa, err := t.Socket.RecvFrom(ctx, fd, vecs, oob, flags)
+	if err != nil {
+		return n, oflags, sa, err
+	}
+
+	if t.roll(t.drop) {
+		// the guest retries reads that would block.
+		return 0, 0, nil, syscall.EAGAIN
+	}
+
+	if t.roll(t.duplicate) {
+		t.m.Lock()
+		t.dups[fd] = duplicated{data: truncatedcopy(vecs, n), flags: oflags, sa: sa}
+		t.m.Unlock()
+	}
+
+	return n, oflags, sa, nil
+}
+
+func (t *faulty) Close(ctx context.Context, fd int) error {
+	t.m.Lock()
+	delete(t.sotypes, fd)
+	delete(t.resets, fd)
+	delete(t.dups, fd)
+	t.m.Unlock()
+
+	return t.Socket.Close(ctx, fd)
+}
+
+func vecsize(vecs [][]byte) (n int) {
+	for _, v := range vecs {
+		n += len(v)
+	}
+
+	return n
+}
+
+// truncate the vectors to n bytes.
+func truncate(vecs [][]byte, n int) [][]byte {
+	truncated := make([][]byte, 0, len(vecs))
+	for _, v := range vecs {
+		if n <= 0 {
+			break
+		}
+
+		v = v[:min(len(v), n)]
+		truncated = append(truncated, v)
+		n -= len(v)
+	}
+
+	return truncated
+}
+
+// truncatedcopy copies the first n bytes of the vectors.
+func truncatedcopy(vecs [][]byte, n int) []byte {
+	buf := make([]byte, 0, n)
+	for _, v := range truncate(vecs, n) {
+		buf = append(buf, v...)
+	}
+
+	return buf
+}
+
+func copyvecs(vecs [][]byte, data []byte) (n int) {
+	for _, v := range vecs {
+		c := copy(v, data[n:])
+		n += c
+	}
+
+	return n
+}
@@ -7,9 +7,13 @@ import (
 	"net"
 	"net/netip"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/egdaemon/wasinet/wasinet/internal/langx"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 	"golang.org/x/sys/unix"
 )
 
@@ -34,10 +38,15 @@ type Socket interface {
 	SetSocketOption(ctx context.Context, fd int, level, name int, value []byte) error
 	GetSocketOption(ctx context.Context, fd int, level, name int, value []byte) (any, error)
 	Shutdown(ctx context.Context, fd, how int) error
+	Close(ctx context.Context, fd int) error
 	AddrIP(ctx context.Context, network string, address string) ([]net.IP, error)
+	LookupIP(ctx context.Context, network string, address string) ([]wasip1syscall.IPRecord, error)
 	AddrPort(ctx context.Context, network string, service string) (int, error)
 	RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error)
 	SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]byte, oob []byte, flags int) (int, error)
+	TrustBundle(ctx context.Context) ([]byte, error)
+	Interfaces(ctx context.Context) ([]wasip1syscall.Interface, error)
+	LookupRecords(ctx context.Context, rtype int, name string) (wasip1syscall.DNSRecords, error)
 }
 
 type IP interface {
@@ -51,24 +60,54 @@ type FSPrefix struct {
 
 type fsremap []FSPrefix
 
-func (t fsremap) Remap(s string) (r string) {
+// Remap the guest path to the host path of the longest guest prefix containing it.
+func (t fsremap) Remap(s string) (r string, ok bool) {
 	var (
 		best FSPrefix
+		rel  string
 	)
 
 	for _, m := range t {
-		if !strings.HasPrefix(s, m.Guest) {
+		suffix, contained := fsrel(m.Guest, s)
+		if !contained {
 			continue
 		}
 
-		if len(m.Guest) < len(best.Guest) {
+		if ok && len(m.Guest) < len(best.Guest) {
 			continue
 		}
 
-		best = m
+		best, rel, ok = m, suffix, true
 	}
 
-	return filepath.Join(best.Host, strings.TrimPrefix(s, best.Guest))
+	if !ok {
+		return "", false
+	}
+
+	return filepath.Join(best.Host, rel), true
+}
+
+// Contains reports if the guest path falls within one of the prefixes.
+func (t fsremap) Contains(s string) bool {
+	_, ok := t.Remap(s)
+	return ok
+}
+
+// fsrel returns the path relative to the prefix, paths are cleaned before they're
+// compared so .. elements cannot escape the prefix and the prefix only matches
+// whole path elements, /tmp/foo does not contain /tmp/foobar.
+func fsrel(prefix, s string) (string, bool) {
+	prefix, s = filepath.Clean(prefix), filepath.Clean(s)
+	switch {
+	case s == prefix:
+		return "", true
+	case prefix == string(filepath.Separator):
+		return strings.TrimPrefix(s, prefix), filepath.IsAbs(s)
+	case strings.HasPrefix(s, prefix+string(filepath.Separator)):
+		return s[len(prefix)+1:], true
+	default:
+		return "", false
+	}
 }
 
 type Option func(*network)
@@ -85,46 +124,179 @@ func OptionFSPrefixes(prefixes ...FSPrefix) Option {
 	}
 }
 
-// unrestricted network defaults.
+// unrestricted network defaults, every address and unix socket path is allowed.
 func Unrestricted(opts ...Option) Socket {
 	return langx.Autoptr(
 		langx.Clone(
-			network{},
+			network{
+				sotypes: new(sync.Map),
+				allow: []netip.Prefix{
+					netip.PrefixFrom(netip.IPv4Unspecified(), 0),
+					netip.PrefixFrom(netip.IPv6Unspecified(), 0),
+				},
+				unixany: true,
+			},
 			opts...,
 		),
 	)
 }
 
 // the network by default disallows all network activity. use unrestricted
-// or manually configure using options.
+// or manually configure using options. ip addresses must fall within a prefix
+// provided by OptionAllow and unix socket paths within a prefix provided by OptionFSPrefixes.
 func New(opts ...Option) Socket {
-	return langx.Autoptr(langx.Clone(network{}, opts...))
+	return langx.Autoptr(langx.Clone(network{sotypes: new(sync.Map)}, opts...))
 }
 
 type network struct {
-	allow []netip.Prefix
-	fsmap []FSPrefix
+	sotypes *sync.Map // socket type of each file descriptor checked by the policy.
+	allow   []netip.Prefix
+	fsmap   []FSPrefix
+	unixany bool
+	policy  *Policy
+	proxy   *proxy
+	dns     dns
+	trust   []byte
 }
 
-func (t network) Bind(ctx context.Context, fd int, sa unix.Sockaddr) error {
+// ensure the operation is permitted, the policy takes precedence over the allow list.
+func (t network) permitted(dir Direction, fd int, sa unix.Sockaddr) error {
+	if t.policy == nil {
+		return t.allowed(sa)
+	}
+
+	sotype, err := t.sotype(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.policy.Check(dir, sotype, sa)
+}
+
+// the socket type of the file descriptor, cached until the descriptor is closed
+// to avoid a syscall on every send.
+func (t network) sotype(fd int) (int, error) {
+	if v, ok := t.sotypes.Load(fd); ok {
+		return v.(int), nil
+	}
+
+	sotype, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TYPE)
+	if err != nil {
+		return 0, err
+	}
+
+	t.sotypes.Store(fd, sotype)
+	return sotype, nil
+}
+
+// the host's network interfaces, interfaces hidden by the policy are omitted.
+func (t network) Interfaces(ctx context.Context) ([]wasip1syscall.Interface, error) {
+	ifaces, err := wasip1syscall.NativeInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	if t.policy == nil {
+		return ifaces, nil
+	}
+
+	return slices.DeleteFunc(ifaces, func(iface wasip1syscall.Interface) bool {
+		return t.policy.CheckInterface(iface.Name) != nil
+	}), nil
+}
+
+// ensure socket options don't bind the socket to an interface hidden by the policy.
+func (t network) permittedsockopt(level, name int, value []byte) error {
+	if t.policy == nil || level != wasip1syscall.SOL_SOCKET || name != wasip1syscall.SO_BINDTODEVICE {
+		return nil
+	}
+
+	// an empty name removes the binding.
+	if device := strings.TrimRight(string(value), "\x00"); device != "" {
+		return t.policy.CheckInterface(device)
+	}
+
+	return nil
+}
+
+// ensure the ip address is permitted by the allow list.
+// ipv4 mapped ipv6 addresses are checked as their ipv4 equivalent.
+func (t network) allowedip(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, p := range t.allow {
+		if p.Contains(addr) {
+			return nil
+		}
+	}
+
+	return syscall.EACCES
+}
+
+// ensure the address is permitted by the network.
+func (t network) allowed(sa unix.Sockaddr) error {
+	switch actual := sa.(type) {
+	case *unix.SockaddrInet4:
+		return t.allowedip(netip.AddrFrom4(actual.Addr))
+	case *unix.SockaddrInet6:
+		return t.allowedip(netip.AddrFrom16(actual.Addr))
+	case *unix.SockaddrUnix:
+		// the path was checked when it was remapped.
+		return nil
+	default:
+		return syscall.EACCES
+	}
+}
+
+// remap the guest's unix socket path to the host path it refers to, paths outside
+// of the prefixes are rejected unless any path is permitted or a policy governs them.
+func (t network) remap(sa unix.Sockaddr) (unix.Sockaddr, error) {
+	actual, ok := sa.(*unix.SockaddrUnix)
+	if !ok {
+		return sa, nil
+	}
+
+	if remapped, ok := fsremap(t.fsmap).Remap(actual.Name); ok {
+		return &unix.SockaddrUnix{Name: remapped}, nil
+	}
+
+	if t.unixany || t.policy != nil {
+		return sa, nil
+	}
+
+	return nil, syscall.EACCES
+}
+
+func (t network) Bind(ctx context.Context, fd int, sa unix.Sockaddr) (err error) {
 	// slog.Log(ctx, slog.LevelDebug, "sock_bind", slog.Int("fd", fd), slog.String("addr", fmt.Sprintf("%v", sa)))
+	if sa, err = t.remap(sa); err != nil {
+		return err
+	}
+
+	if err := t.permitted(DirectionBind, fd, sa); err != nil {
+		return err
+	}
+
 	return unix.Bind(fd, sa)
 }
 
 func (t network) Connect(ctx context.Context, fd int, sa unix.Sockaddr) (err error) {
-	switch actual := sa.(type) {
-	case *unix.SockaddrUnix:
-		remapped := fsremap(t.fsmap).Remap(actual.Name)
-		if p, err := unix.Getsockname(fd); err != nil {
-			return err
-		} else {
-			*actual = *p.(*unix.SockaddrUnix)
-			actual.Name = remapped
-		}
+	if sa, err = t.remap(sa); err != nil {
+		return err
+	}
 
-		return unix.Connect(fd, actual)
+	if err := t.permitted(DirectionDial, fd, sa); err != nil {
+		return err
+	}
+
+	switch sa.(type) {
+	case *unix.SockaddrUnix:
+		return unix.Connect(fd, sa)
 	default:
 		// slog.Log(ctx, slog.LevelDebug, "sock_connect", slog.Int("fd", fd), slog.String("addr", fmt.Sprintf("%v", sa)))
+		if t.proxy.proxied(fd, sa) {
+			return t.proxy.connect(ctx, t.dns, fd, sa)
+		}
+
 		return unix.Connect(fd, sa)
 	}
 }
@@ -136,7 +308,24 @@ func (t network) Listen(ctx context.Context, fd, backlog int) error {
 
 func (t network) Accept(ctx context.Context, fd int) (nfd int, sa unix.Sockaddr, err error) {
 	// slog.Log(ctx, slog.LevelDebug, "sock_accept", slog.Int("fd", fd))
-	return unix.Accept(fd)
+	if nfd, sa, err = unix.Accept(fd); err != nil {
+		return nfd, sa, err
+	}
+
+	switch sa.(type) {
+	case *unix.SockaddrUnix:
+		// peers of unix sockets are generally unnamed, the bind check covers them.
+		return nfd, sa, nil
+	}
+
+	// drop connections from peers outside of the allow list, the guest
+	// treats the aborted connection as transient and continues accepting.
+	if err = t.permitted(DirectionAccept, nfd, sa); err != nil {
+		t.Close(ctx, nfd)
+		return -1, nil, syscall.ECONNABORTED
+	}
+
+	return nfd, sa, nil
 }
 
 func (t network) LocalAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
@@ -146,6 +335,10 @@ func (t network) LocalAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
 
 func (t network) PeerAddr(ctx context.Context, fd int) (_ unix.Sockaddr, err error) {
 	// slog.Log(ctx, slog.LevelDebug, "sock_peeraddr", slog.Int("fd", fd))
+	if sa, ok, err := t.proxy.peer(fd); ok {
+		return sa, err
+	}
+
 	return unix.Getpeername(fd)
 }
 
@@ -154,14 +347,28 @@ func (t network) Shutdown(ctx context.Context, fd, how int) error {
 	return unix.Shutdown(fd, how)
 }
 
+func (t network) Close(ctx context.Context, fd int) error {
+	t.proxy.release(fd)
+	t.sotypes.Delete(fd)
+	return unix.Close(fd)
+}
+
 func (t network) AddrIP(ctx context.Context, network string, address string) ([]net.IP, error) {
 	// slog.Log(ctx, slog.LevelDebug, "sock_getaddrip", slog.String("network", network), slog.String("address", address))
-	return net.DefaultResolver.LookupIP(ctx, network, address)
+	return t.dns.lookupip(ctx, network, address)
+}
+
+func (t network) LookupIP(ctx context.Context, network string, address string) ([]wasip1syscall.IPRecord, error) {
+	return t.dns.lookupiprecords(ctx, network, address)
 }
 
 func (t network) AddrPort(ctx context.Context, network string, service string) (int, error) {
 	// slog.Log(ctx, slog.LevelDebug, "sock_getaddrport", slog.String("network", network), slog.String("service", service))
-	return net.DefaultResolver.LookupPort(ctx, network, service)
+	return t.dns.lookupport(ctx, network, service)
+}
+
+func (t network) LookupRecords(ctx context.Context, rtype int, name string) (wasip1syscall.DNSRecords, error) {
+	return t.dns.lookup(ctx, rtype, name)
 }
 
 func (t network) RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error) {
@@ -173,12 +380,25 @@ func (t network) SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]
 	// dispatch-run/wasi-go has linux special cased here.
 	// did not faithfully follow it because it might be caused by other complexity.
 	// https://github.com/dispatchrun/wasi-go/blob/038d5104aacbb966c25af43797473f03c5da3e4f/systems/unix/system.go#L640
+	sa, err := t.remap(sa)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = t.permitted(DirectionSendTo, fd, sa); err != nil {
+		return 0, err
+	}
+
 	switch sa.(type) {
 	case *unix.SockaddrUnix:
 		// apparently its fine to send the sock address to a tcp stream
-		// but for unix sockets it'll return syscall.EISCONN
-		return unix.SendmsgBuffers(int(fd), vecs, oob, nil, int(flags))
-	default:
-		return unix.SendmsgBuffers(int(fd), vecs, oob, sa, int(flags))
+		// but for connection oriented unix sockets it'll return syscall.EISCONN
+		if sotype, err := t.sotype(fd); err != nil {
+			return 0, err
+		} else if sotype != unix.SOCK_DGRAM {
+			return unix.SendmsgBuffers(int(fd), vecs, oob, nil, int(flags))
+		}
 	}
+
+	return unix.SendmsgBuffers(int(fd), vecs, oob, sa, int(flags))
 }
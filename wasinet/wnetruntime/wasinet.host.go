@@ -11,7 +11,6 @@ import (
 
 	"github.com/egdaemon/wasinet/wasinet/ffi"
 	"github.com/egdaemon/wasinet/wasinet/ffierrors"
-	"github.com/egdaemon/wasinet/wasinet/ffiguest"
 	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
 )
 
@@ -139,7 +138,11 @@ func SocketSendTo(fn SendToFn) SendToHostFn {
 		flags int32,
 		nwritten uintptr,
 	) syscall.Errno {
-		oob := ffiguest.BytesRead(unsafe.Pointer(oobptr), ooblen)
+		oob, err := ffi.BytesRead(m, unsafe.Pointer(oobptr), ooblen)
+		if err != nil {
+			return TranslateErrno(err)
+		}
+
 		vecs, err := vectorread[byte](m, iovs, iovslen)
 		if err != nil {
 			return TranslateErrno(err)
@@ -170,7 +173,7 @@ type RecvFromHostFn func(
 	fd int32,
 	iovs uintptr, iovslen uint32,
 	oobptr uintptr, ooblen uint32,
-	addrptr uintptr, _addrlen uint32,
+	addrptr uintptr, addrlen uint32,
 	iflags int32,
 	nread uintptr,
 	oflags uintptr,
@@ -183,12 +186,16 @@ func SocketRecvFrom(fn RecvFromFn) RecvFromHostFn {
 		fd int32,
 		iovsptr uintptr, iovslen uint32,
 		oobptr uintptr, ooblen uint32,
-		addrptr uintptr, _addrlen uint32,
+		addrptr uintptr, addrlen uint32,
 		iflags int32,
 		nread uintptr,
 		oflags uintptr,
 	) syscall.Errno {
-		oob := ffiguest.BytesRead(unsafe.Pointer(oobptr), ooblen)
+		oob, err := ffi.BytesRead(m, unsafe.Pointer(oobptr), ooblen)
+		if err != nil {
+			return TranslateErrno(err)
+		}
+
 		vecs, err := vectorread[byte](m, iovsptr, iovslen)
 		if err != nil {
 			return TranslateErrno(err)
@@ -204,7 +211,7 @@ func SocketRecvFrom(fn RecvFromFn) RecvFromHostFn {
 				return TranslateErrno(err)
 			}
 
-			if err = ffi.RawWrite(m, &addr, unsafe.Pointer(addrptr), uint32(unsafe.Sizeof(addr))); err != nil {
+			if err = ffi.RawWrite(m, addr, unsafe.Pointer(addrptr), addrlen); err != nil {
 				return TranslateErrno(err)
 			}
 		}
@@ -264,13 +271,19 @@ func SocketGetOpt(fn GetOptFn) GetOptHostFn {
 		if err != nil {
 			return TranslateErrno(err)
 		}
-		switch av := rv.(type) {
-		case int:
-			return TranslateErrno(ffi.Uint32Write(m, unsafe.Pointer(valueptr), uint32(av)))
-		default:
+		if v, ok := rv.(int); ok && level == wasip1syscall.SOL_SOCKET && name == wasip1syscall.SO_ERROR {
+			// pending errors are host errno values, the guest expects wasi's numbering.
+			rv = int(wasip1syscall.ErrnoTranslate(syscall.Errno(v)))
+		}
+		encoded, err := wasip1syscall.SockoptBytes(rv)
+		if err != nil {
 			log.Printf("unsupported socket option type: %T\n", rv)
-			return syscall.ENOTSUP
+			return TranslateErrno(err)
 		}
+		if uint32(len(encoded)) > valuelen {
+			return TranslateErrno(syscall.EINVAL)
+		}
+		return TranslateErrno(ffi.BytesWrite(m, encoded, unsafe.Pointer(valueptr), valuelen))
 	}
 }
 
@@ -366,6 +379,17 @@ func SocketShutdown(fn ShutdownFn) ShutdownHostFn {
 	}
 }
 
+type CloseFn func(ctx context.Context, fd int) error
+type CloseHostFn func(ctx context.Context, m ffi.Memory, fd int32) syscall.Errno
+
+func SocketClose(fn CloseFn) CloseHostFn {
+	return func(
+		ctx context.Context, m ffi.Memory, fd int32,
+	) syscall.Errno {
+		return TranslateErrno(fn(ctx, int(fd)))
+	}
+}
+
 type AddrPortFn func(ctx context.Context, network string, service string) (int, error)
 type AddrPortHostFn func(ctx context.Context,
 	m ffi.Memory,
@@ -397,7 +421,7 @@ func SocketAddrPort(portfn AddrPortFn) AddrPortHostFn {
 		}
 
 		if port, err = portfn(ctx, network, service); err != nil {
-			return TranslateErrno(err)
+			return TranslateErrno(wasip1syscall.LookupErrno(err))
 		}
 
 		return TranslateErrno(ffi.Uint32Write(m, unsafe.Pointer(portptr), uint32(port)))
@@ -414,6 +438,8 @@ type AddrIPHostFn func(
 	ipreslen uintptr,
 ) syscall.Errno
 
+// SocketAddrIP writes as many addresses as fit into the guest buffer.
+// superseded by SocketLookupIP, retained for guests built against earlier releases.
 func SocketAddrIP(fn AddrIPFn) AddrIPHostFn {
 	return func(
 		ctx context.Context,
@@ -440,7 +466,7 @@ func SocketAddrIP(fn AddrIPFn) AddrIPHostFn {
 
 		if ip, err = fn(ctx, network, address); err != nil {
 			log.Println("socket ip lookup failed", err)
-			return syscall.EINVAL
+			return TranslateErrno(wasip1syscall.LookupErrno(err))
 		}
 
 		reslength := len(ip)
@@ -460,3 +486,176 @@ func SocketAddrIP(fn AddrIPFn) AddrIPHostFn {
 		return TranslateErrno(ffi.Uint32Write(m, unsafe.Pointer(ipreslen), uint32(len(buf))))
 	}
 }
+
+type LookupIPFn func(ctx context.Context, network string, address string) ([]wasip1syscall.IPRecord, error)
+type LookupIPHostFn func(
+	ctx context.Context,
+	m ffi.Memory,
+	deadline int64,
+	networkptr uintptr, networklen uint32,
+	addressptr uintptr, addresslen uint32,
+	bufptr uintptr, buflen uint32,
+	rescount uintptr,
+) syscall.Errno
+
+// SocketLookupIP writes the abi encoded addresses into the guest buffer. the
+// number of addresses is always written to rescount, when the buffer is too
+// small nothing is written and the guest retries with a larger buffer. the
+// lookup is abandoned once the guest's deadline passes.
+func SocketLookupIP(fn LookupIPFn) LookupIPHostFn {
+	return func(
+		ctx context.Context,
+		m ffi.Memory,
+		deadline int64,
+		networkptr uintptr, networklen uint32,
+		addressptr uintptr, addresslen uint32,
+		bufptr uintptr, buflen uint32,
+		rescount uintptr,
+	) syscall.Errno {
+		ctx, done := ffi.ContextDeadline(ctx, deadline)
+		defer done()
+
+		network, err := ffi.StringRead(m, unsafe.Pointer(networkptr), networklen)
+		if err != nil {
+			return TranslateErrno(err)
+		}
+		address, err := ffi.StringRead(m, unsafe.Pointer(addressptr), addresslen)
+		if err != nil {
+			return TranslateErrno(err)
+		}
+
+		records, err := fn(ctx, network, address)
+		if err != nil {
+			log.Println("socket ip lookup failed", err)
+			return TranslateErrno(wasip1syscall.LookupErrno(err))
+		}
+
+		encoded := wasip1syscall.EncodeIPRecords(records...)
+		if len(encoded) <= int(buflen) {
+			if err = ffi.BytesWrite(m, encoded, unsafe.Pointer(bufptr), buflen); err != nil {
+				return TranslateErrno(err)
+			}
+		}
+
+		return TranslateErrno(ffi.Uint32Write(m, unsafe.Pointer(rescount), uint32(len(records))))
+	}
+}
+
+type TrustBundleFn func(ctx context.Context) ([]byte, error)
+type TrustBundleHostFn func(
+	ctx context.Context,
+	m ffi.Memory,
+	bufptr uintptr, buflen uint32,
+	reslen uintptr,
+) syscall.Errno
+
+// SocketTrustBundle writes the PEM encoded trust bundle into the guest buffer.
+// the length of the bundle is always written to reslen, when the buffer is
+// too small nothing is written and the guest retries with a larger buffer.
+func SocketTrustBundle(fn TrustBundleFn) TrustBundleHostFn {
+	return func(
+		ctx context.Context,
+		m ffi.Memory,
+		bufptr uintptr, buflen uint32,
+		reslen uintptr,
+	) syscall.Errno {
+		pem, err := fn(ctx)
+		if err != nil {
+			// guests only need to know the bundle is unavailable, not why.
+			log.Println("trust bundle unavailable", err)
+			return TranslateErrno(syscall.ENOENT)
+		}
+
+		if len(pem) <= int(buflen) {
+			if err = ffi.BytesWrite(m, pem, unsafe.Pointer(bufptr), buflen); err != nil {
+				return TranslateErrno(err)
+			}
+		}
+
+		return TranslateErrno(ffi.Uint32Write(m, unsafe.Pointer(reslen), uint32(len(pem))))
+	}
+}
+
+type InterfacesFn func(ctx context.Context) ([]wasip1syscall.Interface, error)
+type InterfacesHostFn func(
+	ctx context.Context,
+	m ffi.Memory,
+	bufptr uintptr, buflen uint32,
+	reslen uintptr,
+) syscall.Errno
+
+// SocketInterfaces writes the abi encoded network interfaces into the guest buffer.
+// like SocketTrustBundle the length is always written to reslen and the guest
+// retries with a larger buffer when it was too small.
+func SocketInterfaces(fn InterfacesFn) InterfacesHostFn {
+	return func(
+		ctx context.Context,
+		m ffi.Memory,
+		bufptr uintptr, buflen uint32,
+		reslen uintptr,
+	) syscall.Errno {
+		ifaces, err := fn(ctx)
+		if err != nil {
+			return TranslateErrno(err)
+		}
+
+		encoded := wasip1syscall.EncodeInterfaces(ifaces...)
+		if len(encoded) <= int(buflen) {
+			if err = ffi.BytesWrite(m, encoded, unsafe.Pointer(bufptr), buflen); err != nil {
+				return TranslateErrno(err)
+			}
+		}
+
+		return TranslateErrno(ffi.Uint32Write(m, unsafe.Pointer(reslen), uint32(len(encoded))))
+	}
+}
+
+type LookupRecordsFn func(ctx context.Context, rtype int, name string) (wasip1syscall.DNSRecords, error)
+type LookupRecordsHostFn func(
+	ctx context.Context,
+	m ffi.Memory,
+	deadline int64,
+	rtype uint32,
+	nameptr uintptr, namelen uint32,
+	bufptr uintptr, buflen uint32,
+	reslen uintptr,
+) syscall.Errno
+
+// SocketLookupRecords writes the abi encoded records into the guest buffer.
+// like SocketTrustBundle the length is always written to reslen and the guest
+// retries with a larger buffer when it was too small. the lookup is abandoned
+// once the guest's deadline passes.
+func SocketLookupRecords(fn LookupRecordsFn) LookupRecordsHostFn {
+	return func(
+		ctx context.Context,
+		m ffi.Memory,
+		deadline int64,
+		rtype uint32,
+		nameptr uintptr, namelen uint32,
+		bufptr uintptr, buflen uint32,
+		reslen uintptr,
+	) syscall.Errno {
+		ctx, done := ffi.ContextDeadline(ctx, deadline)
+		defer done()
+
+		name, err := ffi.StringRead(m, unsafe.Pointer(nameptr), namelen)
+		if err != nil {
+			return TranslateErrno(err)
+		}
+
+		res, err := fn(ctx, int(rtype), name)
+		if err != nil {
+			log.Println("socket record lookup failed", err)
+			return TranslateErrno(wasip1syscall.LookupErrno(err))
+		}
+
+		encoded := wasip1syscall.EncodeDNSRecords(res)
+		if len(encoded) <= int(buflen) {
+			if err = ffi.BytesWrite(m, encoded, unsafe.Pointer(bufptr), buflen); err != nil {
+				return TranslateErrno(err)
+			}
+		}
+
+		return TranslateErrno(ffi.Uint32Write(m, unsafe.Pointer(reslen), uint32(len(encoded))))
+	}
+}
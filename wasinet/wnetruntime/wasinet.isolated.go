@@ -0,0 +1,226 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"golang.org/x/sys/unix"
+)
+
+// guest handles start well above the descriptors wasi runtimes allocate for
+// files and preopens, this prevents the guest from confusing the two.
+const fdbase = 1 << 16
+
+// Isolate the socket's file descriptors from the guest. the guest is only given
+// handles allocated from a descriptor table that belongs to the returned socket,
+// any handle the table did not allocate is rejected with EBADF. a separate
+// isolated socket should be used for each guest module instance.
+func Isolate(s Socket) *Isolated {
+	return &Isolated{
+		s:    s,
+		next: fdbase,
+		fds:  make(map[int]int),
+	}
+}
+
+// Isolated maps guest handles to the file descriptors of the underlying socket.
+type Isolated struct {
+	s    Socket
+	m    sync.RWMutex
+	next int
+	fds  map[int]int // guest handle -> host fd.
+}
+
+var _ Socket = (*Isolated)(nil)
+
+func (t *Isolated) insert(fd int) int {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	handle := t.next
+	t.next++
+	t.fds[handle] = fd
+
+	return handle
+}
+
+func (t *Isolated) host(handle int) (int, error) {
+	t.m.RLock()
+	defer t.m.RUnlock()
+
+	if fd, ok := t.fds[handle]; ok {
+		return fd, nil
+	}
+
+	return -1, syscall.EBADF
+}
+
+func (t *Isolated) Open(ctx context.Context, af, socktype, protocol int) (fd int, err error) {
+	if fd, err = t.s.Open(ctx, af, socktype, protocol); err != nil {
+		return fd, err
+	}
+
+	return t.insert(fd), nil
+}
+
+func (t *Isolated) Bind(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.s.Bind(ctx, hfd, sa)
+}
+
+func (t *Isolated) Connect(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.s.Connect(ctx, hfd, sa)
+}
+
+func (t *Isolated) Listen(ctx context.Context, fd, backlog int) error {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.s.Listen(ctx, hfd, backlog)
+}
+
+func (t *Isolated) Accept(ctx context.Context, fd int) (nfd int, sa unix.Sockaddr, err error) {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	if nfd, sa, err = t.s.Accept(ctx, hfd); err != nil {
+		return nfd, sa, err
+	}
+
+	return t.insert(nfd), sa, nil
+}
+
+func (t *Isolated) LocalAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.s.LocalAddr(ctx, hfd)
+}
+
+func (t *Isolated) PeerAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.s.PeerAddr(ctx, hfd)
+}
+
+func (t *Isolated) SetSocketOption(ctx context.Context, fd int, level, name int, value []byte) error {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.s.SetSocketOption(ctx, hfd, level, name, value)
+}
+
+func (t *Isolated) GetSocketOption(ctx context.Context, fd int, level, name int, value []byte) (any, error) {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.s.GetSocketOption(ctx, hfd, level, name, value)
+}
+
+func (t *Isolated) Shutdown(ctx context.Context, fd, how int) error {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return err
+	}
+
+	return t.s.Shutdown(ctx, hfd, how)
+}
+
+// Close the handle and its underlying file descriptor.
+func (t *Isolated) Close(ctx context.Context, fd int) error {
+	t.m.Lock()
+	hfd, ok := t.fds[fd]
+	delete(t.fds, fd)
+	t.m.Unlock()
+
+	if !ok {
+		return syscall.EBADF
+	}
+
+	return t.s.Close(ctx, hfd)
+}
+
+// CloseAll closes every file descriptor allocated by the table,
+// used to release the host resources of a guest once it has exited.
+func (t *Isolated) CloseAll(ctx context.Context) (err error) {
+	t.m.Lock()
+	fds := t.fds
+	t.fds = make(map[int]int)
+	t.m.Unlock()
+
+	for _, hfd := range fds {
+		err = errors.Join(err, t.s.Close(ctx, hfd))
+	}
+
+	return err
+}
+
+func (t *Isolated) AddrIP(ctx context.Context, network string, address string) ([]net.IP, error) {
+	return t.s.AddrIP(ctx, network, address)
+}
+
+func (t *Isolated) LookupIP(ctx context.Context, network string, address string) ([]wasip1syscall.IPRecord, error) {
+	return t.s.LookupIP(ctx, network, address)
+}
+
+func (t *Isolated) AddrPort(ctx context.Context, network string, service string) (int, error) {
+	return t.s.AddrPort(ctx, network, service)
+}
+
+func (t *Isolated) TrustBundle(ctx context.Context) ([]byte, error) {
+	return t.s.TrustBundle(ctx)
+}
+
+func (t *Isolated) LookupRecords(ctx context.Context, rtype int, name string) (wasip1syscall.DNSRecords, error) {
+	return t.s.LookupRecords(ctx, rtype, name)
+}
+
+func (t *Isolated) Interfaces(ctx context.Context) ([]wasip1syscall.Interface, error) {
+	return t.s.Interfaces(ctx)
+}
+
+func (t *Isolated) RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error) {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return t.s.RecvFrom(ctx, hfd, vecs, oob, flags)
+}
+
+func (t *Isolated) SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]byte, oob []byte, flags int) (int, error) {
+	hfd, err := t.host(fd)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.s.SendTo(ctx, hfd, sa, vecs, oob, flags)
+}
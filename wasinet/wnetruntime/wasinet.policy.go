@@ -0,0 +1,476 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v3"
+)
+
+// Action taken when a policy rule matches.
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	ActionDeny  Action = "deny"
+)
+
+// Direction of the socket operation being checked by the policy.
+type Direction string
+
+const (
+	DirectionDial   Direction = "dial"
+	DirectionBind   Direction = "bind"
+	DirectionAccept Direction = "accept"
+	DirectionSendTo Direction = "sendto"
+)
+
+// PolicyRule matches socket operations, empty fields match everything.
+//
+//   - families: ip4, ip6, unix
+//   - types: stream, dgram
+//   - directions: dial, bind (or listen), accept, sendto
+//   - cidrs: address prefixes, e.g. 10.0.0.0/8
+//   - ports: individual ports or inclusive ranges, e.g. 443 or 8000-8999
+//   - paths: unix socket paths or the directories containing them, e.g. /run/app
+type PolicyRule struct {
+	Action     Action   `json:"action" yaml:"action"`
+	Families   []string `json:"families,omitempty" yaml:"families,omitempty"`
+	Types      []string `json:"types,omitempty" yaml:"types,omitempty"`
+	Directions []string `json:"directions,omitempty" yaml:"directions,omitempty"`
+	CIDRs      []string `json:"cidrs,omitempty" yaml:"cidrs,omitempty"`
+	Ports      []string `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Paths      []string `json:"paths,omitempty" yaml:"paths,omitempty"`
+}
+
+// InterfaceRule matches network interfaces by name, empty names match every interface.
+// names are shell patterns, e.g. docker* or eth0.
+type InterfaceRule struct {
+	Action Action   `json:"action" yaml:"action"`
+	Names  []string `json:"names,omitempty" yaml:"names,omitempty"`
+}
+
+// PolicyDocument is the declarative form of a network policy.
+// rules are evaluated in order and the first matching rule wins,
+// when no rule matches the default action is taken. the default action
+// is deny when unspecified. interface rules determine which of the host's
+// network interfaces are visible to guests, they're evaluated the same way
+// against their own default which is allow when unspecified.
+type PolicyDocument struct {
+	Default          Action          `json:"default" yaml:"default"`
+	Rules            []PolicyRule    `json:"rules" yaml:"rules"`
+	InterfaceDefault Action          `json:"interface_default,omitempty" yaml:"interface_default,omitempty"`
+	Interfaces       []InterfaceRule `json:"interfaces,omitempty" yaml:"interfaces,omitempty"`
+}
+
+// Policy is a compiled, hot reloadable, PolicyDocument.
+type Policy struct {
+	current atomic.Pointer[compiledpolicy]
+}
+
+// LoadPolicy from a json or yaml document.
+func LoadPolicy(r io.Reader) (_ *Policy, err error) {
+	p := &Policy{}
+	if err = p.Reload(r); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewPolicy compiles the document into a policy.
+func NewPolicy(doc PolicyDocument) (_ *Policy, err error) {
+	p := &Policy{}
+	if err = p.Store(doc); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Reload the policy from a json or yaml document, modules using the policy
+// observe the new rules on their next socket operation. on error the
+// previous rules remain in effect.
+func (t *Policy) Reload(r io.Reader) (err error) {
+	var (
+		doc PolicyDocument
+	)
+
+	encoded, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	// yaml is a superset of json, so a single decoder handles both.
+	if err = yaml.Unmarshal(encoded, &doc); err != nil {
+		return fmt.Errorf("unable to decode policy: %w", err)
+	}
+
+	return t.Store(doc)
+}
+
+// Store atomically replaces the policy rules with the provided document.
+func (t *Policy) Store(doc PolicyDocument) error {
+	c, err := compilepolicy(doc)
+	if err != nil {
+		return err
+	}
+
+	t.current.Store(c)
+	return nil
+}
+
+// Check if the operation is permitted by the policy, returns syscall.EACCES when denied.
+func (t *Policy) Check(dir Direction, sotype int, sa unix.Sockaddr) error {
+	c := t.current.Load()
+	if c == nil {
+		return syscall.EACCES
+	}
+
+	for _, r := range c.rules {
+		if r.match(dir, sotype, sa) {
+			return r.action.errno()
+		}
+	}
+
+	return c.fallback.errno()
+}
+
+// CheckInterface if the network interface is visible to guests, returns syscall.EACCES when hidden.
+func (t *Policy) CheckInterface(name string) error {
+	c := t.current.Load()
+	if c == nil {
+		return syscall.EACCES
+	}
+
+	for _, r := range c.interfaces {
+		if r.match(name) {
+			return r.action.errno()
+		}
+	}
+
+	return c.ifallback.errno()
+}
+
+// OptionPolicy configures the network to enforce the policy. when provided
+// the policy takes precedence over the allow list from OptionAllow.
+func OptionPolicy(p *Policy) Option {
+	return func(n *network) {
+		n.policy = p
+	}
+}
+
+func (t Action) errno() error {
+	if t == ActionAllow {
+		return nil
+	}
+
+	return syscall.EACCES
+}
+
+type portrange struct {
+	lo, hi int
+}
+
+type compiledpolicy struct {
+	fallback   Action
+	rules      []policyrule
+	ifallback  Action
+	interfaces []interfacerule
+}
+
+type interfacerule struct {
+	action Action
+	names  []string
+}
+
+func (t interfacerule) match(name string) bool {
+	if len(t.names) == 0 {
+		return true
+	}
+
+	for _, pattern := range t.names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+type policyrule struct {
+	action     Action
+	families   []int
+	sotypes    []int
+	directions []Direction
+	prefixes   []netip.Prefix
+	ports      []portrange
+	paths      []string
+}
+
+func (t policyrule) match(dir Direction, sotype int, sa unix.Sockaddr) bool {
+	var (
+		family int
+		addr   netip.Addr
+		port   int
+		path   string
+	)
+
+	switch actual := sa.(type) {
+	case *unix.SockaddrInet4:
+		family, addr, port = syscall.AF_INET, netip.AddrFrom4(actual.Addr), actual.Port
+	case *unix.SockaddrInet6:
+		// ipv4 mapped addresses are matched as the ipv4 address they refer to.
+		family, addr, port = syscall.AF_INET6, netip.AddrFrom16(actual.Addr).Unmap(), actual.Port
+		if addr.Is4() {
+			family = syscall.AF_INET
+		}
+	case *unix.SockaddrUnix:
+		family, path = syscall.AF_UNIX, actual.Name
+	default:
+		return false
+	}
+
+	if len(t.directions) > 0 && !contains(t.directions, dir) {
+		return false
+	}
+
+	if len(t.families) > 0 && !contains(t.families, family) {
+		return false
+	}
+
+	if len(t.sotypes) > 0 && !contains(t.sotypes, sotype) {
+		return false
+	}
+
+	if len(t.prefixes) > 0 && !t.matchprefix(family, addr) {
+		return false
+	}
+
+	if len(t.ports) > 0 && !t.matchport(family, port) {
+		return false
+	}
+
+	if len(t.paths) > 0 && !t.matchpath(family, path) {
+		return false
+	}
+
+	return true
+}
+
+func (t policyrule) matchprefix(family int, addr netip.Addr) bool {
+	if family == syscall.AF_UNIX {
+		return false
+	}
+
+	for _, p := range t.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t policyrule) matchport(family int, port int) bool {
+	if family == syscall.AF_UNIX {
+		return false
+	}
+
+	for _, r := range t.ports {
+		if r.lo <= port && port <= r.hi {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t policyrule) matchpath(family int, path string) bool {
+	if family != syscall.AF_UNIX {
+		return false
+	}
+
+	for _, p := range t.paths {
+		if _, ok := fsrel(p, path); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains[T comparable](set []T, v T) bool {
+	for _, s := range set {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func compileaction(a Action, fallback Action) (Action, error) {
+	switch strings.ToLower(string(a)) {
+	case "":
+		return fallback, nil
+	case string(ActionAllow):
+		return ActionAllow, nil
+	case string(ActionDeny):
+		return ActionDeny, nil
+	default:
+		return "", fmt.Errorf("unknown policy action: %s", a)
+	}
+}
+
+func compilepolicy(doc PolicyDocument) (_ *compiledpolicy, err error) {
+	c := &compiledpolicy{
+		rules: make([]policyrule, 0, len(doc.Rules)),
+	}
+
+	if c.fallback, err = compileaction(doc.Default, ActionDeny); err != nil {
+		return nil, err
+	}
+
+	for idx, r := range doc.Rules {
+		compiled, err := compilerule(r)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %d: %w", idx, err)
+		}
+		c.rules = append(c.rules, compiled)
+	}
+
+	if c.ifallback, err = compileaction(doc.InterfaceDefault, ActionAllow); err != nil {
+		return nil, err
+	}
+
+	for idx, r := range doc.Interfaces {
+		compiled, err := compileinterfacerule(r)
+		if err != nil {
+			return nil, fmt.Errorf("interface rule %d: %w", idx, err)
+		}
+		c.interfaces = append(c.interfaces, compiled)
+	}
+
+	return c, nil
+}
+
+func compileinterfacerule(r InterfaceRule) (c interfacerule, err error) {
+	if r.Action == "" {
+		return c, fmt.Errorf("missing action")
+	}
+
+	if c.action, err = compileaction(r.Action, ""); err != nil {
+		return c, err
+	}
+
+	for _, name := range r.Names {
+		if _, err = path.Match(name, ""); err != nil {
+			return c, fmt.Errorf("invalid interface pattern: %s", name)
+		}
+	}
+
+	c.names = r.Names
+
+	return c, nil
+}
+
+func compilerule(r PolicyRule) (c policyrule, err error) {
+	if r.Action == "" {
+		return c, fmt.Errorf("missing action")
+	}
+
+	if c.action, err = compileaction(r.Action, ""); err != nil {
+		return c, err
+	}
+
+	for _, f := range r.Families {
+		switch strings.ToLower(f) {
+		case "ip4", "inet", "inet4":
+			c.families = append(c.families, syscall.AF_INET)
+		case "ip6", "inet6":
+			c.families = append(c.families, syscall.AF_INET6)
+		case "ip":
+			c.families = append(c.families, syscall.AF_INET, syscall.AF_INET6)
+		case "unix":
+			c.families = append(c.families, syscall.AF_UNIX)
+		default:
+			return c, fmt.Errorf("unknown family: %s", f)
+		}
+	}
+
+	for _, s := range r.Types {
+		switch strings.ToLower(s) {
+		case "stream":
+			c.sotypes = append(c.sotypes, syscall.SOCK_STREAM)
+		case "dgram":
+			c.sotypes = append(c.sotypes, syscall.SOCK_DGRAM)
+		default:
+			return c, fmt.Errorf("unknown socket type: %s", s)
+		}
+	}
+
+	for _, d := range r.Directions {
+		switch dir := Direction(strings.ToLower(d)); dir {
+		case DirectionDial, DirectionBind, DirectionAccept, DirectionSendTo:
+			c.directions = append(c.directions, dir)
+		case "listen":
+			c.directions = append(c.directions, DirectionBind)
+		default:
+			return c, fmt.Errorf("unknown direction: %s", d)
+		}
+	}
+
+	for _, cidr := range r.CIDRs {
+		var p netip.Prefix
+		if strings.Contains(cidr, "/") {
+			if p, err = netip.ParsePrefix(cidr); err != nil {
+				return c, err
+			}
+		} else {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return c, err
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		c.prefixes = append(c.prefixes, p.Masked())
+	}
+
+	for _, p := range r.Ports {
+		var (
+			pr portrange
+		)
+
+		lo, hi, found := strings.Cut(p, "-")
+		if pr.lo, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+			return c, fmt.Errorf("invalid port: %s", p)
+		}
+
+		pr.hi = pr.lo
+		if found {
+			if pr.hi, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return c, fmt.Errorf("invalid port range: %s", p)
+			}
+		}
+
+		if pr.lo < 0 || pr.hi > 65535 || pr.lo > pr.hi {
+			return c, fmt.Errorf("invalid port range: %s", p)
+		}
+
+		c.ports = append(c.ports, pr)
+	}
+
+	c.paths = r.Paths
+
+	return c, nil
+}
@@ -0,0 +1,501 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/ffierrors"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"golang.org/x/sys/unix"
+)
+
+// proxy tunnels tcp connections through an upstream proxy.
+type proxy struct {
+	upstream *url.URL
+	tunnels  sync.Map // fd -> *tunnel to the address the guest connected to.
+}
+
+// tunnel tracks the handshake establishing a connection through the proxy.
+type tunnel struct {
+	sa     unix.Sockaddr
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error // the outcome of the handshake, valid once done is closed.
+}
+
+// the handshake's outcome, EINPROGRESS while it is underway.
+func (t *tunnel) status() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return syscall.EINPROGRESS
+	}
+}
+
+// upper bound on the handshake for guests that never give up on the connection.
+const proxyhandshake = time.Minute
+
+// OptionProxy routes every tcp connection made by the guest through the upstream
+// proxy. supported schemes are socks5 and http (CONNECT), credentials are taken
+// from the url's user info. the guest observes a socket connected to its
+// original destination. udp and unix sockets are not proxied. the upstream's
+// name is resolved using the network's static hosts and resolver.
+//
+// the guest resolves names before it connects, so the proxy is always given an
+// address. socks5h, which has the proxy resolve names, is rejected with EOPNOTSUPP.
+func OptionProxy(upstream *url.URL) Option {
+	return func(n *network) {
+		n.proxy = &proxy{upstream: upstream}
+	}
+}
+
+// proxied reports if the connection to the address should be tunneled.
+func (t *proxy) proxied(fd int, sa unix.Sockaddr) bool {
+	if t == nil {
+		return false
+	}
+
+	switch sa.(type) {
+	case *unix.SockaddrInet4, *unix.SockaddrInet6:
+	default:
+		return false
+	}
+
+	sotype, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TYPE)
+	return err == nil && sotype == unix.SOCK_STREAM
+}
+
+func (t *proxy) tunnel(fd int) (*tunnel, bool) {
+	if t == nil {
+		return nil, false
+	}
+
+	tun, ok := t.tunnels.Load(fd)
+	if !ok {
+		return nil, false
+	}
+
+	return tun.(*tunnel), true
+}
+
+// the guest's destination once the tunnel is established.
+func (t *proxy) peer(fd int) (_ unix.Sockaddr, ok bool, err error) {
+	tun, ok := t.tunnel(fd)
+	if !ok {
+		return nil, false, nil
+	}
+
+	if err := tun.status(); err != nil {
+		return nil, true, syscall.ENOTCONN
+	}
+
+	return tun.sa, true, nil
+}
+
+// soerror reports the handshake as the socket's pending error while it is underway
+// or after it failed, the guest polls SO_ERROR to learn when its connect completes.
+func (t *proxy) soerror(fd, level, name int) (int, bool) {
+	if level != wasip1syscall.SOL_SOCKET || name != wasip1syscall.SO_ERROR {
+		return 0, false
+	}
+
+	tun, ok := t.tunnel(fd)
+	if !ok {
+		return 0, false
+	}
+
+	if err := tun.status(); err != nil {
+		return int(ffierrors.Errno(err)), true
+	}
+
+	return 0, false
+}
+
+// release the tunnel, the handshake is abandoned and must stop using the
+// file descriptor before it is closed.
+func (t *proxy) release(fd int) {
+	tun, ok := t.tunnel(fd)
+	if !ok {
+		return
+	}
+
+	tun.cancel()
+	<-tun.done
+	t.tunnels.Delete(fd)
+}
+
+// connect the file descriptor to the upstream proxy and establish a tunnel to the destination.
+// the handshake happens in the background, the guest observes a nonblocking connect in progress.
+func (t *proxy) connect(ctx context.Context, resolver dns, fd int, sa unix.Sockaddr) error {
+	switch t.upstream.Scheme {
+	case "socks5", "http":
+	default:
+		return syscall.EOPNOTSUPP
+	}
+
+	if tun, ok := t.tunnel(fd); ok {
+		switch err := tun.status(); err {
+		case nil:
+			return syscall.EISCONN
+		case syscall.EINPROGRESS:
+			return syscall.EALREADY
+		}
+	}
+
+	hctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), proxyhandshake)
+	tun := &tunnel{sa: sa, cancel: cancel, done: make(chan struct{})}
+	t.tunnels.Store(fd, tun)
+
+	go func() {
+		defer cancel()
+		defer close(tun.done)
+		tun.err = t.handshake(hctx, resolver, fd, sa)
+	}()
+
+	return syscall.EINPROGRESS
+}
+
+func (t *proxy) handshake(ctx context.Context, resolver dns, fd int, sa unix.Sockaddr) (err error) {
+	var (
+		dst  netip.AddrPort
+		conn = fdconn{ctx: ctx, fd: fd}
+	)
+
+	local, err := unix.Getsockname(fd)
+	if err != nil {
+		return err
+	}
+
+	upstream, err := t.resolve(ctx, resolver, local)
+	if err != nil {
+		return err
+	}
+
+	if err = conn.connect(upstream); err != nil {
+		return err
+	}
+
+	switch actual := sa.(type) {
+	case *unix.SockaddrInet4:
+		dst = netip.AddrPortFrom(netip.AddrFrom4(actual.Addr), uint16(actual.Port))
+	case *unix.SockaddrInet6:
+		dst = netip.AddrPortFrom(netip.AddrFrom16(actual.Addr).Unmap(), uint16(actual.Port))
+	}
+
+	switch t.upstream.Scheme {
+	case "socks5":
+		err = socks5connect(conn, t.upstream.User, dst)
+	case "http":
+		err = httpconnect(conn, t.upstream.User, dst)
+	default:
+		err = syscall.EOPNOTSUPP
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// the proxy hung up during the handshake.
+		return syscall.ECONNRESET
+	}
+
+	return err
+}
+
+// resolve the upstream proxy address to one the socket's family can connect to.
+// the proxy is configured by the host, the guest's name restrictions don't apply to it.
+func (t *proxy) resolve(ctx context.Context, resolver dns, local unix.Sockaddr) (unix.Sockaddr, error) {
+	resolver = dns{resolver: resolver.resolver, hosts: resolver.hosts}
+
+	port := 1080
+	if t.upstream.Scheme == "http" {
+		port = 80
+	}
+
+	if p := t.upstream.Port(); p != "" {
+		var err error
+		if port, err = resolver.lookupport(ctx, "tcp", p); err != nil {
+			return nil, syscall.EINVAL
+		}
+	}
+
+	addrs, err := resolver.lookupip(ctx, "ip", t.upstream.Hostname())
+	if err != nil {
+		return nil, syscall.EHOSTUNREACH
+	}
+
+	for _, ip := range addrs {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+
+		switch local.(type) {
+		case *unix.SockaddrInet4:
+			if addr.Unmap().Is4() {
+				return &unix.SockaddrInet4{Addr: addr.Unmap().As4(), Port: port}, nil
+			}
+		case *unix.SockaddrInet6:
+			return &unix.SockaddrInet6{Addr: addr.As16(), Port: port}, nil
+		}
+	}
+
+	return nil, syscall.EHOSTUNREACH
+}
+
+// fdconn performs blocking io against a nonblocking file descriptor, honoring the context.
+type fdconn struct {
+	ctx context.Context
+	fd  int
+}
+
+// wait for the events to occur on the file descriptor.
+func (t fdconn) wait(events int16) error {
+	for {
+		if err := t.ctx.Err(); err != nil {
+			return err
+		}
+
+		timeout := 100 * time.Millisecond
+		if deadline, ok := t.ctx.Deadline(); ok {
+			timeout = min(timeout, time.Until(deadline))
+		}
+
+		fds := []unix.PollFd{{Fd: int32(t.fd), Events: events}}
+		n, err := unix.Poll(fds, max(int(timeout.Milliseconds()), 1))
+		if errors.Is(err, syscall.EINTR) || n == 0 {
+			continue
+		}
+
+		return err
+	}
+}
+
+func (t fdconn) connect(sa unix.Sockaddr) error {
+	err := unix.Connect(t.fd, sa)
+	if !errors.Is(err, syscall.EINPROGRESS) {
+		return err
+	}
+
+	if err = t.wait(unix.POLLOUT); err != nil {
+		return err
+	}
+
+	if errno, err := unix.GetsockoptInt(t.fd, unix.SOL_SOCKET, unix.SO_ERROR); err != nil {
+		return err
+	} else if errno != 0 {
+		return syscall.Errno(errno)
+	}
+
+	return nil
+}
+
+func (t fdconn) Read(b []byte) (int, error) {
+	for {
+		n, err := unix.Read(t.fd, b)
+		switch {
+		case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR):
+			if err = t.wait(unix.POLLIN); err != nil {
+				return 0, err
+			}
+		case err != nil:
+			return 0, err
+		case n == 0:
+			return 0, io.EOF
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (t fdconn) Write(b []byte) (written int, err error) {
+	for written < len(b) {
+		n, err := unix.Write(t.fd, b[written:])
+		switch {
+		case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR):
+			if err = t.wait(unix.POLLOUT); err != nil {
+				return written, err
+			}
+		case err != nil:
+			return written, err
+		default:
+			written += n
+		}
+	}
+
+	return written, nil
+}
+
+// socks5connect performs the socks5 handshake (RFC 1928) with optional
+// username/password authentication (RFC 1929).
+func socks5connect(conn io.ReadWriter, user *url.Userinfo, dst netip.AddrPort) error {
+	const (
+		version     = 0x05
+		noauth      = 0x00
+		userpass    = 0x02
+		unsupported = 0xFF
+		connect     = 0x01
+		ipv4        = 0x01
+		domain      = 0x03
+		ipv6        = 0x04
+	)
+
+	methods := []byte{noauth}
+	if user != nil {
+		methods = []byte{userpass}
+	}
+
+	if _, err := conn.Write(append([]byte{version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	if reply[0] != version || reply[1] == unsupported {
+		return syscall.EACCES
+	}
+
+	if reply[1] == userpass {
+		if user == nil {
+			return syscall.EACCES
+		}
+
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return syscall.EINVAL
+		}
+
+		auth := []byte{0x01, byte(len(user.Username()))}
+		auth = append(auth, user.Username()...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+
+		if reply[1] != 0x00 {
+			return syscall.EACCES
+		}
+	}
+
+	req := []byte{version, connect, 0x00}
+	if dst.Addr().Is4() {
+		a := dst.Addr().As4()
+		req = append(append(req, ipv4), a[:]...)
+	} else {
+		a := dst.Addr().As16()
+		req = append(append(req, ipv6), a[:]...)
+	}
+	req = append(req, byte(dst.Port()>>8), byte(dst.Port()))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+
+	if header[0] != version {
+		return syscall.EPROTO
+	}
+
+	switch header[1] {
+	case 0x00:
+	case 0x02:
+		return syscall.EACCES
+	case 0x03:
+		return syscall.ENETUNREACH
+	case 0x04:
+		return syscall.EHOSTUNREACH
+	case 0x06:
+		return syscall.ETIMEDOUT
+	case 0x07, 0x08:
+		return syscall.EOPNOTSUPP
+	default:
+		return syscall.ECONNREFUSED
+	}
+
+	// discard the bound address.
+	var remaining int
+	switch header[3] {
+	case ipv4:
+		remaining = 4 + 2
+	case ipv6:
+		remaining = 16 + 2
+	case domain:
+		if _, err := io.ReadFull(conn, header[:1]); err != nil {
+			return err
+		}
+		remaining = int(header[0]) + 2
+	default:
+		return syscall.EPROTO
+	}
+
+	_, err := io.ReadFull(conn, make([]byte, remaining))
+	return err
+}
+
+// httpconnect establishes a tunnel using an http CONNECT request.
+func httpconnect(conn io.ReadWriter, user *url.Userinfo, dst netip.AddrPort) error {
+	req := bytes.NewBuffer(nil)
+	fmt.Fprintf(req, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", dst, dst)
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		fmt.Fprintf(req, "Proxy-Authorization: Basic %s\r\n", credentials)
+	}
+	req.WriteString("\r\n")
+
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return err
+	}
+
+	// read the response headers a byte at a time, anything after them belongs to the guest.
+	header := make([]byte, 0, 512)
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(header, []byte("\r\n\r\n")) {
+		if len(header) >= 64*1024 {
+			return syscall.EPROTO
+		}
+
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return err
+		}
+		header = append(header, b[0])
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(header)), nil)
+	if err != nil {
+		return syscall.EPROTO
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusForbidden, resp.StatusCode == http.StatusProxyAuthRequired:
+		return syscall.EACCES
+	case resp.StatusCode == http.StatusGatewayTimeout:
+		return syscall.ETIMEDOUT
+	default:
+		return syscall.ECONNREFUSED
+	}
+}
@@ -0,0 +1,503 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"golang.org/x/sys/unix"
+)
+
+// ErrReplayDiverged is returned by a replayed socket when the guest makes a
+// call that does not match the recording.
+var ErrReplayDiverged = errors.New("replay diverged from the recording")
+
+const (
+	opOpen      = "open"
+	opBind      = "bind"
+	opConnect   = "connect"
+	opListen    = "listen"
+	opAccept    = "accept"
+	opLocalAddr = "localaddr"
+	opPeerAddr  = "peeraddr"
+	opSetOpt    = "setsockopt"
+	opGetOpt    = "getsockopt"
+	opShutdown  = "shutdown"
+	opClose     = "close"
+	opAddrIP    = "addrip"
+	opLookupIP  = "lookupip"
+	opAddrPort  = "addrport"
+	opRecvFrom  = "recvfrom"
+	opSendTo    = "sendto"
+	opTrust     = "trustbundle"
+	opIfaces    = "interfaces"
+	opRecords   = "records"
+)
+
+// recordedaddr is the serialized form of a unix.Sockaddr.
+type recordedaddr struct {
+	Family int        `json:"family"`
+	IP     netip.Addr `json:"ip"`
+	Port   int        `json:"port,omitempty"`
+	Zone   uint32     `json:"zone,omitempty"`
+	Path   string     `json:"path,omitempty"`
+}
+
+// recorded is a single host call, its arguments and its results.
+type recorded struct {
+	Op      string        `json:"op"`
+	FD      int           `json:"fd"`
+	Args    []int         `json:"args,omitempty"`
+	Network string        `json:"network,omitempty"`
+	Name    string        `json:"name,omitempty"`
+	Address *recordedaddr `json:"address,omitempty"`
+	Data    []byte        `json:"data,omitempty"`
+	OOB     []byte        `json:"oob,omitempty"`
+	Result  int           `json:"result,omitempty"`
+	Recv    []byte        `json:"recv,omitempty"`
+	RecvOOB []byte        `json:"recvoob,omitempty"`
+	Flags   int           `json:"flags,omitempty"`
+	Peer    *recordedaddr `json:"peer,omitempty"`
+	IPs     [][]byte      `json:"ips,omitempty"` // raw bytes preserve the length of each ip.
+	Value   any           `json:"value,omitempty"`
+	Errno   syscall.Errno `json:"errno,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func encodeaddr(sa unix.Sockaddr) *recordedaddr {
+	switch actual := sa.(type) {
+	case *unix.SockaddrInet4:
+		return &recordedaddr{Family: syscall.AF_INET, IP: netip.AddrFrom4(actual.Addr), Port: actual.Port}
+	case *unix.SockaddrInet6:
+		return &recordedaddr{Family: syscall.AF_INET6, IP: netip.AddrFrom16(actual.Addr), Port: actual.Port, Zone: actual.ZoneId}
+	case *unix.SockaddrUnix:
+		return &recordedaddr{Family: syscall.AF_UNIX, Path: actual.Name}
+	default:
+		return nil
+	}
+}
+
+func decodeaddr(a *recordedaddr) unix.Sockaddr {
+	if a == nil {
+		return nil
+	}
+
+	switch a.Family {
+	case syscall.AF_INET:
+		return &unix.SockaddrInet4{Addr: a.IP.As4(), Port: a.Port}
+	case syscall.AF_INET6:
+		return &unix.SockaddrInet6{Addr: a.IP.As16(), Port: a.Port, ZoneId: a.Zone}
+	case syscall.AF_UNIX:
+		return &unix.SockaddrUnix{Name: a.Path}
+	default:
+		return nil
+	}
+}
+
+// encodeerr records the error as the errno the guest observes, errors
+// without an errno are recorded by their message.
+func encodeerr(r *recorded, err error) {
+	var (
+		dnserr *net.DNSError
+	)
+
+	if err == nil {
+		return
+	}
+
+	switch {
+	case errors.As(err, &r.Errno):
+	case errors.As(err, &dnserr):
+		r.Errno = wasip1syscall.LookupErrno(err)
+	case errors.Is(err, context.Canceled):
+		r.Errno = syscall.ECANCELED
+	case errors.Is(err, context.DeadlineExceeded):
+		r.Errno = syscall.ETIMEDOUT
+	default:
+		r.Error = err.Error()
+	}
+}
+
+func (t recorded) err() error {
+	if t.Errno != 0 {
+		return t.Errno
+	}
+
+	if t.Error != "" {
+		return errors.New(t.Error)
+	}
+
+	return nil
+}
+
+// Record every call made to the socket to the writer as a stream of json
+// documents, one per line. the recording captures the arguments, returned
+// bytes, addresses and errors of each call and can be served back by Replay.
+// a recording should only contain the calls of a single guest module instance,
+// wasm guests make calls sequentially which keeps the recording ordered.
+func Record(s Socket, w io.Writer) Socket {
+	return &recorder{
+		Socket: s,
+		enc:    json.NewEncoder(w),
+	}
+}
+
+type recorder struct {
+	Socket
+	m   sync.Mutex
+	enc *json.Encoder
+}
+
+func (t *recorder) record(r recorded, err error) {
+	encodeerr(&r, err)
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	if err := t.enc.Encode(r); err != nil {
+		log.Println("unable to write network recording", err)
+	}
+}
+
+func (t *recorder) Open(ctx context.Context, af, socktype, protocol int) (fd int, err error) {
+	fd, err = t.Socket.Open(ctx, af, socktype, protocol)
+	t.record(recorded{Op: opOpen, Args: []int{af, socktype, protocol}, Result: fd}, err)
+	return fd, err
+}
+
+func (t *recorder) Bind(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	err := t.Socket.Bind(ctx, fd, sa)
+	t.record(recorded{Op: opBind, FD: fd, Address: encodeaddr(sa)}, err)
+	return err
+}
+
+func (t *recorder) Connect(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	err := t.Socket.Connect(ctx, fd, sa)
+	t.record(recorded{Op: opConnect, FD: fd, Address: encodeaddr(sa)}, err)
+	return err
+}
+
+func (t *recorder) Listen(ctx context.Context, fd, backlog int) error {
+	err := t.Socket.Listen(ctx, fd, backlog)
+	t.record(recorded{Op: opListen, FD: fd, Args: []int{backlog}}, err)
+	return err
+}
+
+func (t *recorder) Accept(ctx context.Context, fd int) (nfd int, sa unix.Sockaddr, err error) {
+	nfd, sa, err = t.Socket.Accept(ctx, fd)
+	t.record(recorded{Op: opAccept, FD: fd, Result: nfd, Peer: encodeaddr(sa)}, err)
+	return nfd, sa, err
+}
+
+func (t *recorder) LocalAddr(ctx context.Context, fd int) (sa unix.Sockaddr, err error) {
+	sa, err = t.Socket.LocalAddr(ctx, fd)
+	t.record(recorded{Op: opLocalAddr, FD: fd, Peer: encodeaddr(sa)}, err)
+	return sa, err
+}
+
+func (t *recorder) PeerAddr(ctx context.Context, fd int) (sa unix.Sockaddr, err error) {
+	sa, err = t.Socket.PeerAddr(ctx, fd)
+	t.record(recorded{Op: opPeerAddr, FD: fd, Peer: encodeaddr(sa)}, err)
+	return sa, err
+}
+
+func (t *recorder) SetSocketOption(ctx context.Context, fd int, level, name int, value []byte) error {
+	err := t.Socket.SetSocketOption(ctx, fd, level, name, value)
+	t.record(recorded{Op: opSetOpt, FD: fd, Args: []int{level, name}, Data: value}, err)
+	return err
+}
+
+func (t *recorder) GetSocketOption(ctx context.Context, fd int, level, name int, value []byte) (v any, err error) {
+	v, err = t.Socket.GetSocketOption(ctx, fd, level, name, value)
+	r := recorded{Op: opGetOpt, FD: fd, Args: []int{level, name}}
+	switch actual := v.(type) {
+	case nil, int:
+		r.Value = actual
+	default:
+		// typed options are recorded by their abi encoding.
+		r.Recv, _ = wasip1syscall.SockoptBytes(actual)
+	}
+	t.record(r, err)
+	return v, err
+}
+
+func (t *recorder) Shutdown(ctx context.Context, fd, how int) error {
+	err := t.Socket.Shutdown(ctx, fd, how)
+	t.record(recorded{Op: opShutdown, FD: fd, Args: []int{how}}, err)
+	return err
+}
+
+func (t *recorder) Close(ctx context.Context, fd int) error {
+	err := t.Socket.Close(ctx, fd)
+	t.record(recorded{Op: opClose, FD: fd}, err)
+	return err
+}
+
+func (t *recorder) AddrIP(ctx context.Context, network string, address string) (ips []net.IP, err error) {
+	ips, err = t.Socket.AddrIP(ctx, network, address)
+	r := recorded{Op: opAddrIP, Network: network, Name: address}
+	for _, ip := range ips {
+		r.IPs = append(r.IPs, ip)
+	}
+	t.record(r, err)
+	return ips, err
+}
+
+func (t *recorder) LookupIP(ctx context.Context, network string, address string) (records []wasip1syscall.IPRecord, err error) {
+	records, err = t.Socket.LookupIP(ctx, network, address)
+	t.record(recorded{Op: opLookupIP, Network: network, Name: address, Recv: wasip1syscall.EncodeIPRecords(records...)}, err)
+	return records, err
+}
+
+func (t *recorder) AddrPort(ctx context.Context, network string, service string) (port int, err error) {
+	port, err = t.Socket.AddrPort(ctx, network, service)
+	t.record(recorded{Op: opAddrPort, Network: network, Name: service, Result: port}, err)
+	return port, err
+}
+
+func (t *recorder) RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error) {
+	n, oflags, sa, err := t.Socket.RecvFrom(ctx, fd, vecs, oob, flags)
+	r := recorded{Op: opRecvFrom, FD: fd, Args: []int{vecsize(vecs), len(oob), flags}, Result: n, Flags: oflags, Peer: encodeaddr(sa)}
+	if err == nil {
+		r.Recv, r.RecvOOB = truncatedcopy(vecs, n), slices.Clone(oob)
+	}
+	t.record(r, err)
+	return n, oflags, sa, err
+}
+
+func (t *recorder) SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]byte, oob []byte, flags int) (int, error) {
+	n, err := t.Socket.SendTo(ctx, fd, sa, vecs, oob, flags)
+	t.record(recorded{Op: opSendTo, FD: fd, Args: []int{flags}, Address: encodeaddr(sa), Data: truncatedcopy(vecs, vecsize(vecs)), OOB: oob, Result: n}, err)
+	return n, err
+}
+
+func (t *recorder) TrustBundle(ctx context.Context) (pem []byte, err error) {
+	pem, err = t.Socket.TrustBundle(ctx)
+	t.record(recorded{Op: opTrust, Recv: pem}, err)
+	return pem, err
+}
+
+func (t *recorder) Interfaces(ctx context.Context) (ifaces []wasip1syscall.Interface, err error) {
+	ifaces, err = t.Socket.Interfaces(ctx)
+	t.record(recorded{Op: opIfaces, Recv: wasip1syscall.EncodeInterfaces(ifaces...)}, err)
+	return ifaces, err
+}
+
+func (t *recorder) LookupRecords(ctx context.Context, rtype int, name string) (res wasip1syscall.DNSRecords, err error) {
+	res, err = t.Socket.LookupRecords(ctx, rtype, name)
+	t.record(recorded{Op: opRecords, Args: []int{rtype}, Name: name, Recv: wasip1syscall.EncodeDNSRecords(res)}, err)
+	return res, err
+}
+
+// Replay serves a recording created by Record without touching the network.
+// each call must match the next call in the recording, otherwise the call fails
+// with ErrReplayDiverged. combined with deterministic clocks and randomness
+// within the guest runtime this reproduces a recorded session exactly.
+func Replay(r io.Reader) (*Replayed, error) {
+	var (
+		calls []recorded
+		dec   = json.NewDecoder(r)
+	)
+
+	for {
+		var c recorded
+		if err := dec.Decode(&c); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		calls = append(calls, c)
+	}
+
+	return &Replayed{calls: calls}, nil
+}
+
+// Replayed is a socket that serves recorded calls.
+type Replayed struct {
+	m     sync.Mutex
+	calls []recorded
+	next  int
+}
+
+var _ Socket = (*Replayed)(nil)
+
+// Remaining number of recorded calls that have not been replayed.
+func (t *Replayed) Remaining() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return len(t.calls) - t.next
+}
+
+// replay the next call after verifying it matches the expected call.
+func (t *Replayed) replay(expected recorded) (recorded, error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.next >= len(t.calls) {
+		log.Println("network replay exhausted", expected.Op, expected.FD)
+		return recorded{}, fmt.Errorf("%w: recording exhausted at %s", ErrReplayDiverged, expected.Op)
+	}
+
+	actual := t.calls[t.next]
+	if actual.Op != expected.Op ||
+		actual.FD != expected.FD ||
+		actual.Network != expected.Network ||
+		actual.Name != expected.Name ||
+		!slices.Equal(actual.Args, expected.Args) ||
+		!bytes.Equal(actual.Data, expected.Data) ||
+		!bytes.Equal(actual.OOB, expected.OOB) ||
+		!sameaddr(actual.Address, expected.Address) {
+		log.Println("network replay diverged", t.next, "expected", actual.Op, actual.FD, "received", expected.Op, expected.FD)
+		return recorded{}, fmt.Errorf("%w: call %d expected %s(%d) received %s(%d)", ErrReplayDiverged, t.next, actual.Op, actual.FD, expected.Op, expected.FD)
+	}
+
+	t.next++
+	return actual, actual.err()
+}
+
+func sameaddr(a, b *recordedaddr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func (t *Replayed) Open(ctx context.Context, af, socktype, protocol int) (fd int, err error) {
+	r, err := t.replay(recorded{Op: opOpen, Args: []int{af, socktype, protocol}})
+	return r.Result, err
+}
+
+func (t *Replayed) Bind(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	_, err := t.replay(recorded{Op: opBind, FD: fd, Address: encodeaddr(sa)})
+	return err
+}
+
+func (t *Replayed) Connect(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	_, err := t.replay(recorded{Op: opConnect, FD: fd, Address: encodeaddr(sa)})
+	return err
+}
+
+func (t *Replayed) Listen(ctx context.Context, fd, backlog int) error {
+	_, err := t.replay(recorded{Op: opListen, FD: fd, Args: []int{backlog}})
+	return err
+}
+
+func (t *Replayed) Accept(ctx context.Context, fd int) (nfd int, sa unix.Sockaddr, err error) {
+	r, err := t.replay(recorded{Op: opAccept, FD: fd})
+	return r.Result, decodeaddr(r.Peer), err
+}
+
+func (t *Replayed) LocalAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
+	r, err := t.replay(recorded{Op: opLocalAddr, FD: fd})
+	return decodeaddr(r.Peer), err
+}
+
+func (t *Replayed) PeerAddr(ctx context.Context, fd int) (unix.Sockaddr, error) {
+	r, err := t.replay(recorded{Op: opPeerAddr, FD: fd})
+	return decodeaddr(r.Peer), err
+}
+
+func (t *Replayed) SetSocketOption(ctx context.Context, fd int, level, name int, value []byte) error {
+	_, err := t.replay(recorded{Op: opSetOpt, FD: fd, Args: []int{level, name}, Data: value})
+	return err
+}
+
+func (t *Replayed) GetSocketOption(ctx context.Context, fd int, level, name int, value []byte) (any, error) {
+	r, err := t.replay(recorded{Op: opGetOpt, FD: fd, Args: []int{level, name}})
+	if r.Recv != nil {
+		return r.Recv, err
+	}
+
+	// json decodes every number as a float64, option values are integers.
+	if v, ok := r.Value.(float64); ok {
+		return int(v), err
+	}
+
+	// zero values are omitted from the recording.
+	return 0, err
+}
+
+func (t *Replayed) Shutdown(ctx context.Context, fd, how int) error {
+	_, err := t.replay(recorded{Op: opShutdown, FD: fd, Args: []int{how}})
+	return err
+}
+
+func (t *Replayed) Close(ctx context.Context, fd int) error {
+	_, err := t.replay(recorded{Op: opClose, FD: fd})
+	return err
+}
+
+func (t *Replayed) AddrIP(ctx context.Context, network string, address string) ([]net.IP, error) {
+	r, err := t.replay(recorded{Op: opAddrIP, Network: network, Name: address})
+	var ips []net.IP
+	for _, ip := range r.IPs {
+		ips = append(ips, ip)
+	}
+	return ips, err
+}
+
+func (t *Replayed) LookupIP(ctx context.Context, network string, address string) ([]wasip1syscall.IPRecord, error) {
+	r, err := t.replay(recorded{Op: opLookupIP, Network: network, Name: address})
+	if err != nil {
+		return nil, err
+	}
+
+	return wasip1syscall.DecodeIPRecords(r.Recv)
+}
+
+func (t *Replayed) AddrPort(ctx context.Context, network string, service string) (int, error) {
+	r, err := t.replay(recorded{Op: opAddrPort, Network: network, Name: service})
+	return r.Result, err
+}
+
+func (t *Replayed) RecvFrom(ctx context.Context, fd int, vecs [][]byte, oob []byte, flags int) (int, int, unix.Sockaddr, error) {
+	r, err := t.replay(recorded{Op: opRecvFrom, FD: fd, Args: []int{vecsize(vecs), len(oob), flags}})
+	if err != nil {
+		return r.Result, r.Flags, decodeaddr(r.Peer), err
+	}
+
+	copyvecs(vecs, r.Recv)
+	copy(oob, r.RecvOOB)
+	return r.Result, r.Flags, decodeaddr(r.Peer), nil
+}
+
+func (t *Replayed) SendTo(ctx context.Context, fd int, sa unix.Sockaddr, vecs [][]byte, oob []byte, flags int) (int, error) {
+	r, err := t.replay(recorded{Op: opSendTo, FD: fd, Args: []int{flags}, Address: encodeaddr(sa), Data: truncatedcopy(vecs, vecsize(vecs)), OOB: oob})
+	return r.Result, err
+}
+
+func (t *Replayed) TrustBundle(ctx context.Context) ([]byte, error) {
+	r, err := t.replay(recorded{Op: opTrust})
+	return r.Recv, err
+}
+
+func (t *Replayed) Interfaces(ctx context.Context) ([]wasip1syscall.Interface, error) {
+	r, err := t.replay(recorded{Op: opIfaces})
+	if err != nil {
+		return nil, err
+	}
+
+	return wasip1syscall.DecodeInterfaces(r.Recv)
+}
+
+func (t *Replayed) LookupRecords(ctx context.Context, rtype int, name string) (wasip1syscall.DNSRecords, error) {
+	r, err := t.replay(recorded{Op: opRecords, Args: []int{rtype}, Name: name})
+	if err != nil {
+		return wasip1syscall.DNSRecords{}, err
+	}
+
+	return wasip1syscall.DecodeDNSRecords(r.Recv)
+}
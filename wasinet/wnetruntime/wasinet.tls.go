@@ -0,0 +1,86 @@
+//go:build !wasip1 && !windows
+
+package wnetruntime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// locations of the system's trusted certificates, the first bundle found is used.
+var certfiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian/Ubuntu/Gentoo etc.
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora/RHEL 6
+	"/etc/ssl/ca-bundle.pem",                            // OpenSUSE
+	"/etc/pki/tls/cacert.pem",                           // OpenELEC
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS/RHEL 7
+	"/etc/ssl/cert.pem",                                 // Alpine Linux, macOS
+	"/usr/local/etc/ssl/cert.pem",                       // FreeBSD
+}
+
+// OptionTrustBundle provides the PEM encoded certificates guests trust instead of the host's system bundle.
+func OptionTrustBundle(pem []byte) Option {
+	return func(n *network) {
+		n.trust = pem
+	}
+}
+
+// SystemTrustBundle reads the host's PEM encoded trusted certificates.
+// like crypto/x509 the SSL_CERT_FILE and SSL_CERT_DIR environment variables
+// override the default locations.
+func SystemTrustBundle() ([]byte, error) {
+	files := certfiles
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		files = []string{f}
+	}
+
+	for _, f := range files {
+		if pem, err := os.ReadFile(f); err == nil {
+			return pem, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, dir := range filepath.SplitList(os.Getenv("SSL_CERT_DIR")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range entries {
+			if e.IsDir() || !(strings.HasSuffix(e.Name(), ".pem") || strings.HasSuffix(e.Name(), ".crt")) {
+				continue
+			}
+
+			pem, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				continue
+			}
+
+			buf.Write(pem)
+			buf.WriteString("\n")
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil, syscall.ENOENT
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (t network) TrustBundle(ctx context.Context) ([]byte, error) {
+	if t.trust != nil {
+		return t.trust, nil
+	}
+
+	return SystemTrustBundle()
+}
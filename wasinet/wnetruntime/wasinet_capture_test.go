@@ -0,0 +1,198 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime/vnet"
+	"github.com/stretchr/testify/require"
+)
+
+type captured struct {
+	outbound bool
+	src, dst netip.AddrPort
+	proto    byte
+	seq      uint32
+	payload  []byte
+}
+
+// onescomplement sum of the 16 bit words, valid checksummed data sums to 0xFFFF.
+func onescomplement(b ...[]byte) uint16 {
+	var sum uint32
+	for _, v := range b {
+		for ; len(v) >= 2; v = v[2:] {
+			sum += uint32(v[0])<<8 | uint32(v[1])
+		}
+		if len(v) == 1 {
+			sum += uint32(v[0]) << 8
+		}
+	}
+
+	for sum > 0xFFFF {
+		sum = (sum >> 16) + (sum & 0xFFFF)
+	}
+
+	return uint16(sum)
+}
+
+func readcapture(t testing.TB, buf []byte) (packets []captured) {
+	blocks := 0
+	for len(buf) > 0 {
+		btype := binary.LittleEndian.Uint32(buf)
+		total := binary.LittleEndian.Uint32(buf[4:])
+		require.Zero(t, total%4)
+		require.Equal(t, total, binary.LittleEndian.Uint32(buf[total-4:]))
+		body := buf[8 : total-4]
+		buf = buf[total:]
+		blocks++
+
+		switch blocks {
+		case 1:
+			require.EqualValues(t, 0x0A0D0D0A, btype)
+			require.EqualValues(t, 0x1A2B3C4D, binary.LittleEndian.Uint32(body))
+			continue
+		case 2:
+			require.EqualValues(t, 1, btype)
+			require.EqualValues(t, 1, binary.LittleEndian.Uint16(body)) // ethernet.
+			continue
+		}
+
+		require.EqualValues(t, 6, btype)
+		caplen := binary.LittleEndian.Uint32(body[12:])
+		frame := body[20 : 20+caplen]
+		options := body[20+(caplen+3)/4*4:]
+		require.EqualValues(t, 2, binary.LittleEndian.Uint16(options))
+
+		pkt := captured{outbound: binary.LittleEndian.Uint32(options[4:]) == 2}
+		require.EqualValues(t, 0x0800, binary.BigEndian.Uint16(frame[12:]))
+		ip := frame[14:]
+		require.EqualValues(t, 0xFFFF, onescomplement(ip[:20]))
+		pkt.proto = ip[9]
+		transport := ip[20:binary.BigEndian.Uint16(ip[2:])]
+		pseudo := append(append([]byte{}, ip[12:20]...), 0, ip[9], byte(len(transport)>>8), byte(len(transport)))
+		require.EqualValues(t, 0xFFFF, onescomplement(pseudo, transport))
+
+		pkt.src = netip.AddrPortFrom(netip.AddrFrom4([4]byte(ip[12:16])), binary.BigEndian.Uint16(transport))
+		pkt.dst = netip.AddrPortFrom(netip.AddrFrom4([4]byte(ip[16:20])), binary.BigEndian.Uint16(transport[2:]))
+		switch pkt.proto {
+		case syscall.IPPROTO_TCP:
+			pkt.seq = binary.BigEndian.Uint32(transport[4:])
+			pkt.payload = transport[20:]
+		default:
+			pkt.payload = transport[8:]
+		}
+
+		packets = append(packets, pkt)
+	}
+
+	return packets
+}
+
+func TestCaptureStream(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	vn := vnet.New()
+	li, err := vn.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer li.Close()
+
+	go func() {
+		conn, err := li.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	buf := bytes.NewBuffer(nil)
+	n, err := wnetruntime.Capture(vn.Socket(), buf)
+	require.NoError(t, err)
+
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, n.Connect(ctx, fd, vaddr))
+
+	for _, msg := range []string{"hello", "world"} {
+		_, err = n.SendTo(ctx, fd, nil, [][]byte{[]byte(msg)}, nil, 0)
+		require.NoError(t, err)
+
+		rbuf := make([]byte, 16)
+		for {
+			nread, _, _, err := n.RecvFrom(ctx, fd, [][]byte{rbuf}, nil, 0)
+			if err == syscall.EAGAIN {
+				continue
+			}
+			require.NoError(t, err)
+			require.Equal(t, msg, string(rbuf[:nread]))
+			break
+		}
+	}
+	require.NoError(t, n.Close(ctx, fd))
+
+	packets := readcapture(t, buf.Bytes())
+	require.Len(t, packets, 4)
+
+	remote := netip.MustParseAddrPort("10.0.0.1:80")
+	guest := packets[0].src
+	require.NotZero(t, guest.Port())
+	for i, expected := range []struct {
+		outbound bool
+		payload  string
+		seq      uint32
+	}{
+		{true, "hello", 1},
+		{false, "hello", 1},
+		{true, "world", 6},
+		{false, "world", 6},
+	} {
+		pkt := packets[i]
+		require.Equal(t, byte(syscall.IPPROTO_TCP), pkt.proto)
+		require.Equal(t, expected.outbound, pkt.outbound)
+		require.Equal(t, expected.payload, string(pkt.payload))
+		require.Equal(t, expected.seq, pkt.seq)
+		if expected.outbound {
+			require.Equal(t, guest, pkt.src)
+			require.Equal(t, remote, pkt.dst)
+		} else {
+			require.Equal(t, remote, pkt.src)
+			require.Equal(t, guest, pkt.dst)
+		}
+	}
+}
+
+func TestCaptureDatagram(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	vn := vnet.New()
+	pc, err := vn.ListenPacket("udp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	buf := bytes.NewBuffer(nil)
+	n, err := wnetruntime.Capture(vn.Socket(), buf)
+	require.NoError(t, err)
+
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+	_, err = n.SendTo(ctx, fd, vaddr, [][]byte{[]byte("odd")}, nil, 0)
+	require.NoError(t, err)
+
+	packets := readcapture(t, buf.Bytes())
+	require.Len(t, packets, 1)
+	require.Equal(t, byte(syscall.IPPROTO_UDP), packets[0].proto)
+	require.True(t, packets[0].outbound)
+	require.Equal(t, netip.MustParseAddrPort("10.0.0.1:80"), packets[0].dst)
+	require.NotZero(t, packets[0].src.Port())
+	require.Equal(t, "odd", string(packets[0].payload))
+}
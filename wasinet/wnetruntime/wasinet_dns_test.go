@@ -0,0 +1,360 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+)
+
+func requireNotFound(t testing.TB, err error) {
+	var dnserr *net.DNSError
+	require.ErrorAs(t, err, &dnserr)
+	require.True(t, dnserr.IsNotFound)
+	require.Equal(t, syscall.ENOENT, wasip1syscall.LookupErrno(err))
+}
+
+func TestDNSHosts(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.New(wnetruntime.OptionHosts(map[string][]net.IP{
+		"Service.Internal.": {net.IPv4(10, 0, 0, 1), net.IPv6loopback},
+		"v4.internal":       {net.IPv4(10, 0, 0, 2)},
+	}))
+
+	ips, err := n.AddrIP(ctx, "ip", "service.internal")
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.IPv4(10, 0, 0, 1), net.IPv6loopback}, ips)
+
+	ips, err = n.AddrIP(ctx, "ip4", "service.internal")
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.IPv4(10, 0, 0, 1)}, ips)
+
+	ips, err = n.AddrIP(ctx, "ip6", "service.internal")
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.IPv6loopback}, ips)
+
+	_, err = n.AddrIP(ctx, "ip6", "v4.internal")
+	requireNotFound(t, err)
+}
+
+func TestDNSPolicy(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	hosts := map[string][]net.IP{
+		"a.svc.internal": {net.IPv4(10, 0, 0, 1)},
+		"b.svc.internal": {net.IPv4(10, 0, 0, 2)},
+		"example.com":    {net.IPv4(10, 0, 0, 3)},
+	}
+
+	n := wnetruntime.New(
+		wnetruntime.OptionHosts(hosts),
+		wnetruntime.OptionDNSAllow("*.svc.internal"),
+		wnetruntime.OptionDNSDeny("b.svc.internal"),
+	)
+
+	_, err := n.AddrIP(ctx, "ip", "a.svc.internal")
+	require.NoError(t, err)
+
+	_, err = n.AddrIP(ctx, "ip", "B.svc.internal.")
+	requireNotFound(t, err)
+
+	_, err = n.AddrIP(ctx, "ip", "example.com")
+	requireNotFound(t, err)
+}
+
+func TestDNSResolver(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	unreachable := errors.New("nameserver unreachable")
+	dialed := atomic.Int32{}
+	n := wnetruntime.New(
+		wnetruntime.OptionHosts(map[string][]net.IP{"pinned.internal": {net.IPv4(10, 0, 0, 1)}}),
+		wnetruntime.OptionResolver(&net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialed.Add(1)
+				return nil, unreachable
+			},
+		}),
+	)
+
+	_, err := n.AddrIP(ctx, "ip", "pinned.internal")
+	require.NoError(t, err)
+	require.Zero(t, dialed.Load(), "static hosts take precedence over the resolver")
+
+	_, err = n.AddrIP(ctx, "ip", "unknown.invalid")
+	require.Error(t, err)
+	require.Positive(t, dialed.Load())
+	require.Equal(t, syscall.EIO, wasip1syscall.LookupErrno(err))
+}
+
+func TestDNSErrors(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	resolve := func(dial func() error) error {
+		n := wnetruntime.New(wnetruntime.OptionResolver(&net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return nil, dial()
+			},
+		}))
+		_, err := n.LookupIP(ctx, "ip", "unknown.invalid")
+		return err
+	}
+
+	nxdomain := wnetruntime.New(wnetruntime.OptionResolver(testx.Nameserver(nil)))
+	_, notfound := nxdomain.LookupIP(ctx, "ip", "unknown.invalid")
+
+	cases := []struct {
+		name      string
+		err       error
+		errno     syscall.Errno
+		notfound  bool
+		timeout   bool
+		temporary bool
+	}{
+		{name: "nxdomain", err: notfound, errno: syscall.ENOENT, notfound: true},
+		{name: "timeout", err: resolve(func() error { return &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded} }), errno: syscall.ETIMEDOUT, timeout: true, temporary: true},
+		{name: "servfail", err: &net.DNSError{Err: "server misbehaving", Name: "unknown.invalid", IsTemporary: true}, errno: syscall.EAGAIN, temporary: true},
+		{name: "deadline", err: context.DeadlineExceeded, errno: syscall.ETIMEDOUT, timeout: true, temporary: true},
+		{name: "cancelled", err: context.Canceled, errno: syscall.ECANCELED},
+		{name: "unsupported", err: syscall.ENOTSUP, errno: syscall.ENOTSUP},
+		{name: "unclassified", err: errors.New("resolver exploded"), errno: syscall.EIO},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var dnserr *net.DNSError
+
+			errno := wasip1syscall.LookupErrno(c.err)
+			require.Equal(t, c.errno, errno)
+
+			err := wasip1syscall.DNSError(errno, "unknown.invalid")
+			require.ErrorAs(t, err, &dnserr)
+			require.ErrorIs(t, err, c.errno)
+			require.Equal(t, "unknown.invalid", dnserr.Name)
+			require.Equal(t, c.notfound, dnserr.IsNotFound)
+			require.Equal(t, c.timeout, dnserr.Timeout())
+			require.Equal(t, c.temporary, dnserr.Temporary())
+		})
+	}
+
+	require.NoError(t, wasip1syscall.DNSError(0, "unknown.invalid"))
+}
+
+func TestDNSLookupRecords(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.New(
+		wnetruntime.OptionHosts(map[string][]net.IP{"pinned.internal": {net.IPv4(10, 0, 0, 1)}}),
+		wnetruntime.OptionResolver(testx.Nameserver(map[string][]testx.DNSRecord{
+			"example.internal.": {
+				testx.DNSMX(10, "mail.example.internal."),
+				testx.DNSTXT("v=spf1 -all"),
+				testx.DNSName(testx.DNSTypeNS, "ns.example.internal."),
+			},
+			"_http._tcp.example.internal.": {testx.DNSSRV(1, 5, 8080, "www.example.internal.")},
+			"2.0.0.10.in-addr.arpa.":       {testx.DNSName(testx.DNSTypePTR, "www.example.internal."), testx.DNSName(testx.DNSTypePTR, "www.denied.internal.")},
+			"example.denied.internal.":     {testx.DNSTXT("hidden")},
+		})),
+		wnetruntime.OptionDNSDeny("*.denied.internal"),
+	)
+
+	res, err := n.LookupRecords(ctx, wasip1syscall.DNS_MX, "example.internal")
+	require.NoError(t, err)
+	require.Equal(t, []wasip1syscall.DNSRecord{{Target: "mail.example.internal.", Pref: 10}}, res.Records)
+
+	res, err = n.LookupRecords(ctx, wasip1syscall.DNS_TXT, "example.internal")
+	require.NoError(t, err)
+	require.Equal(t, []wasip1syscall.DNSRecord{{Target: "v=spf1 -all"}}, res.Records)
+
+	res, err = n.LookupRecords(ctx, wasip1syscall.DNS_NS, "example.internal")
+	require.NoError(t, err)
+	require.Equal(t, []wasip1syscall.DNSRecord{{Target: "ns.example.internal."}}, res.Records)
+
+	res, err = n.LookupRecords(ctx, wasip1syscall.DNS_SRV, "_http._tcp.example.internal")
+	require.NoError(t, err)
+	require.Equal(t, "_http._tcp.example.internal.", res.CNAME)
+	require.Equal(t, []wasip1syscall.DNSRecord{{Target: "www.example.internal.", Priority: 1, Weight: 5, Port: 8080}}, res.Records)
+
+	// reverse lookups omit names hidden by the policy.
+	res, err = n.LookupRecords(ctx, wasip1syscall.DNS_PTR, "10.0.0.2")
+	require.NoError(t, err)
+	require.Equal(t, []wasip1syscall.DNSRecord{{Target: "www.example.internal."}}, res.Records)
+
+	// static hosts answer canonical name and reverse lookups.
+	res, err = n.LookupRecords(ctx, wasip1syscall.DNS_CNAME, "Pinned.Internal")
+	require.NoError(t, err)
+	require.Equal(t, "pinned.internal.", res.CNAME)
+
+	res, err = n.LookupRecords(ctx, wasip1syscall.DNS_PTR, "10.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, []wasip1syscall.DNSRecord{{Target: "pinned.internal."}}, res.Records)
+
+	_, err = n.LookupRecords(ctx, wasip1syscall.DNS_TXT, "example.denied.internal")
+	requireNotFound(t, err)
+
+	_, err = n.LookupRecords(ctx, wasip1syscall.DNS_MX, "missing.internal")
+	requireNotFound(t, err)
+}
+
+func TestDNSRecordsEncoding(t *testing.T) {
+	res := wasip1syscall.DNSRecords{
+		CNAME: "example.internal.",
+		Records: []wasip1syscall.DNSRecord{
+			{Target: "www.example.internal.", Priority: 1, Weight: 5, Port: 8080},
+			{Target: "mail.example.internal.", Pref: 10},
+		},
+	}
+
+	decoded, err := wasip1syscall.DecodeDNSRecords(wasip1syscall.EncodeDNSRecords(res))
+	require.NoError(t, err)
+	require.Equal(t, res, decoded)
+
+	encoded := wasip1syscall.EncodeDNSRecords(res)
+	_, err = wasip1syscall.DecodeDNSRecords(encoded[:len(encoded)-1])
+	require.ErrorIs(t, err, syscall.EINVAL)
+}
+
+func TestDNSLookupIP(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	ips := make([]net.IP, 0, 40)
+	for i := range 40 {
+		ips = append(ips, net.IPv4(10, 0, 1, byte(i)))
+	}
+	ips = append(ips, net.IPv6loopback)
+
+	n := wnetruntime.New(
+		wnetruntime.OptionHosts(map[string][]net.IP{"headless.internal": ips}),
+		wnetruntime.OptionDNSCacheLifetime(30*time.Second),
+	)
+
+	records, err := n.LookupIP(ctx, "ip", "headless.internal")
+	require.NoError(t, err)
+	require.Len(t, records, len(ips))
+	for idx, r := range records {
+		require.True(t, ips[idx].Equal(r.IP))
+		require.Equal(t, 30*time.Second, r.TTL)
+	}
+
+	decoded, err := wasip1syscall.DecodeIPRecords(wasip1syscall.EncodeIPRecords(records...))
+	require.NoError(t, err)
+	require.Len(t, decoded, len(records))
+	require.Equal(t, net.IPv4(10, 0, 1, 0).To4(), decoded[0].IP)
+	require.Equal(t, net.IPv6loopback, decoded[len(decoded)-1].IP)
+	require.Equal(t, 30*time.Second, decoded[0].TTL)
+
+	// the family is encoded as 4 or 6 regardless of the host's AF numbering.
+	encoded := wasip1syscall.EncodeIPRecords(records[0], records[len(records)-1])
+	size := len(encoded) / 2
+	require.Equal(t, []byte{4, 0}, encoded[net.IPv6len:net.IPv6len+2])
+	require.Equal(t, []byte{6, 0}, encoded[size+net.IPv6len:size+net.IPv6len+2])
+
+	encoded[net.IPv6len] = 2
+	_, err = wasip1syscall.DecodeIPRecords(encoded)
+	require.ErrorIs(t, err, syscall.EINVAL)
+
+	// malformed addresses are skipped rather than encoded.
+	decoded, err = wasip1syscall.DecodeIPRecords(wasip1syscall.EncodeIPRecords(wasip1syscall.IPRecord{IP: net.IP{1, 2, 3}}, records[0], wasip1syscall.IPRecord{}))
+	require.NoError(t, err)
+	require.Len(t, decoded, 1)
+	require.Equal(t, net.IPv4(10, 0, 1, 0).To4(), decoded[0].IP)
+
+	_, err = n.LookupIP(ctx, "ip", "missing.internal")
+	require.Error(t, err)
+}
+
+func TestDNSCache(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	resolved := map[string]int{}
+	resolver := func(ttl time.Duration, err error) func(ctx context.Context, network, name string) ([]wasip1syscall.IPRecord, error) {
+		return func(ctx context.Context, network, name string) ([]wasip1syscall.IPRecord, error) {
+			resolved[network+"/"+name]++
+			if err != nil {
+				return nil, err
+			}
+
+			return []wasip1syscall.IPRecord{{IP: net.IPv4(10, 0, 0, 1).To4(), TTL: ttl}, {IP: net.IPv6loopback, TTL: 2 * ttl}}, nil
+		}
+	}
+
+	c := wasip1syscall.NewDNSCache(2, time.Hour)
+
+	for range 3 {
+		records, err := c.LookupIP(ctx, "ip", "cached.internal", resolver(time.Hour, nil))
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		records[0].IP[0] = 0
+	}
+	require.Equal(t, 1, resolved["ip/cached.internal"])
+
+	records, err := c.LookupIP(ctx, "ip", "cached.internal", resolver(time.Hour, nil))
+	require.NoError(t, err)
+	require.Equal(t, net.IPv4(10, 0, 0, 1).To4(), records[0].IP, "callers must not be able to modify the cache")
+
+	_, err = c.LookupIP(ctx, "ip4", "cached.internal", resolver(time.Hour, nil))
+	require.NoError(t, err)
+	require.Equal(t, 1, resolved["ip4/cached.internal"], "entries are keyed by network")
+
+	for range 2 {
+		_, err = c.LookupIP(ctx, "ip", "uncached.internal", resolver(0, nil))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, resolved["ip/uncached.internal"], "a zero ttl must not be cached")
+
+	for range 2 {
+		_, err = c.LookupIP(ctx, "ip", "expiring.internal", resolver(time.Millisecond, nil))
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.Equal(t, 2, resolved["ip/expiring.internal"], "expired entries must be resolved again")
+
+	for range 2 {
+		_, err = c.LookupIP(ctx, "ip", "missing.internal", resolver(0, wasip1syscall.DNSError(syscall.ENOENT, "missing.internal")))
+		requireNotFound(t, err)
+	}
+	require.Equal(t, 1, resolved["ip/missing.internal"], "names that do not exist are cached")
+
+	for range 2 {
+		_, err = c.LookupIP(ctx, "ip", "slow.internal", resolver(0, wasip1syscall.DNSError(syscall.ETIMEDOUT, "slow.internal")))
+		require.Error(t, err)
+	}
+	require.Equal(t, 2, resolved["ip/slow.internal"], "transient failures must not be cached")
+
+	c.Flush()
+	require.Zero(t, c.Len())
+	_, err = c.LookupIP(ctx, "ip", "missing.internal", resolver(0, wasip1syscall.DNSError(syscall.ENOENT, "missing.internal")))
+	requireNotFound(t, err)
+	require.Equal(t, 2, resolved["ip/missing.internal"], "flushed entries must be resolved again")
+
+	c = wasip1syscall.NewDNSCache(2, time.Hour)
+	for _, name := range []string{"a.internal", "b.internal", "a.internal", "c.internal", "a.internal", "b.internal"} {
+		_, err = c.LookupIP(ctx, "ip", name, resolver(time.Hour, nil))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, 1, resolved["ip/a.internal"])
+	require.Equal(t, 2, resolved["ip/b.internal"], "least recently used entry should have been evicted")
+	require.Equal(t, 1, resolved["ip/c.internal"])
+}
@@ -0,0 +1,130 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime/vnet"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+var vaddr = &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 80}
+
+func faultyConnect(ctx context.Context, t testing.TB, opts ...wnetruntime.FaultOption) (wnetruntime.Socket, int, error) {
+	vn := vnet.New()
+	li, err := vn.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	t.Cleanup(func() { li.Close() })
+
+	n := wnetruntime.Faulty(vn.Socket(), opts...)
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+
+	return n, fd, n.Connect(ctx, fd, vaddr)
+}
+
+func shortwrites(ctx context.Context, t testing.TB, seed uint64) (written []int) {
+	n, fd, err := faultyConnect(ctx, t, wnetruntime.OptionFaultSeed(seed), wnetruntime.OptionFaultShortWrite(0.5))
+	require.NoError(t, err)
+
+	buf := make([]byte, 128)
+	for i := 0; i < 32; i++ {
+		w, err := n.SendTo(ctx, fd, nil, [][]byte{buf}, nil, 0)
+		require.NoError(t, err)
+		require.Positive(t, w)
+		written = append(written, w)
+	}
+
+	return written
+}
+
+func TestFaultShortWriteDeterministic(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	a := shortwrites(ctx, t, 1)
+	require.Equal(t, a, shortwrites(ctx, t, 1))
+	require.NotEqual(t, a, shortwrites(ctx, t, 2))
+	require.Contains(t, a, 128)
+	require.Less(t, slices.Min(a), 128)
+}
+
+func TestFaultReset(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n, fd, err := faultyConnect(ctx, t, wnetruntime.OptionFaultReset(1))
+	require.ErrorIs(t, err, syscall.ECONNRESET)
+
+	// resets are persistent for the connection.
+	_, err = n.SendTo(ctx, fd, nil, [][]byte{[]byte("hello")}, nil, 0)
+	require.ErrorIs(t, err, syscall.ECONNRESET)
+	_, _, _, err = n.RecvFrom(ctx, fd, [][]byte{make([]byte, 16)}, nil, 0)
+	require.ErrorIs(t, err, syscall.ECONNRESET)
+}
+
+func TestFaultTimeout(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	_, _, err := faultyConnect(ctx, t, wnetruntime.OptionFaultTimeout(1))
+	require.ErrorIs(t, err, syscall.ETIMEDOUT)
+}
+
+func TestFaultLatency(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	ts := time.Now()
+	_, _, err := faultyConnect(ctx, t, wnetruntime.OptionFaultLatency(20*time.Millisecond, 30*time.Millisecond))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(ts), 20*time.Millisecond)
+}
+
+func TestFaultDatagrams(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	vn := vnet.New()
+	pc, err := vn.ListenPacket("udp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	send := func(opts ...wnetruntime.FaultOption) {
+		n := wnetruntime.Faulty(vn.Socket(), opts...)
+		fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+		require.NoError(t, err)
+		_, err = n.SendTo(ctx, fd, vaddr, [][]byte{[]byte("hello")}, nil, 0)
+		require.NoError(t, err)
+	}
+
+	received := func() (count int) {
+		buf := make([]byte, 16)
+		for {
+			require.NoError(t, pc.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+			if _, _, err := pc.ReadFrom(buf); err != nil {
+				require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+				return count
+			}
+			count++
+		}
+	}
+
+	send(wnetruntime.OptionFaultDrop(1))
+	require.Equal(t, 0, received())
+
+	send(wnetruntime.OptionFaultDuplicate(1))
+	require.Equal(t, 2, received())
+
+	send()
+	require.Equal(t, 1, received())
+}
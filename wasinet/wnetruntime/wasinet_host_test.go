@@ -0,0 +1,84 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/egdaemon/wasinet/wasinet/ffi"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// faultmemory fails reads of the given offset, standing in for guest memory
+// the host can't see directly.
+type faultmemory struct {
+	ffi.Native
+	fault unsafe.Pointer
+}
+
+func (t faultmemory) Read(offset unsafe.Pointer, byteCount uint32) ([]byte, bool) {
+	if offset == t.fault {
+		return nil, false
+	}
+
+	return t.Native.Read(offset, byteCount)
+}
+
+func TestSocketSendToReadsOOBFromMemory(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	var (
+		oob      = []byte("oob")
+		nwritten uint32
+		received []byte
+	)
+
+	send := wnetruntime.SocketSendTo(func(ctx context.Context, fd int, sa wasip1syscall.NativeSocket, vecs [][]byte, oob []byte, flags int) (int, error) {
+		received = oob
+		return 0, nil
+	})
+
+	addr, err := wasip1syscall.Sockaddr(&unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: 80})
+	require.NoError(t, err)
+	addrptr, addrlen := ffi.Pointer(addr)
+
+	oobptr := unsafe.Pointer(unsafe.SliceData(oob))
+	errno := send(ctx, faultmemory{fault: oobptr}, 0, 0, 0, uintptr(oobptr), uint32(len(oob)), uintptr(addrptr), addrlen, 0, uintptr(unsafe.Pointer(&nwritten)))
+	require.Equal(t, wasip1syscall.EFAULT, errno)
+
+	errno = send(ctx, ffi.Native{}, 0, 0, 0, uintptr(oobptr), uint32(len(oob)), uintptr(addrptr), addrlen, 0, uintptr(unsafe.Pointer(&nwritten)))
+	require.Equal(t, syscall.Errno(0), errno)
+	require.Equal(t, oob, received)
+}
+
+func TestSocketRecvFromHonorsAddrlen(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	var (
+		addr   wasip1syscall.RawSocketAddress
+		nread  uint32
+		oflags uint32
+	)
+
+	recv := wnetruntime.SocketRecvFrom(func(ctx context.Context, fd int, buf [][]byte, oob []byte, flags int) (int, int, wasip1syscall.NativeSocket, error) {
+		return 0, 0, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: 80}, nil
+	})
+
+	addrptr, addrlen := ffi.Pointer(&addr)
+	errno := recv(ctx, ffi.Native{}, 0, 0, 0, 0, 0, uintptr(addrptr), 4, 0, uintptr(unsafe.Pointer(&nread)), uintptr(unsafe.Pointer(&oflags)))
+	require.Equal(t, wasip1syscall.EINVAL, errno)
+	require.Equal(t, wasip1syscall.RawSocketAddress{}, addr)
+
+	errno = recv(ctx, ffi.Native{}, 0, 0, 0, 0, 0, uintptr(addrptr), addrlen, 0, uintptr(unsafe.Pointer(&nread)), uintptr(unsafe.Pointer(&oflags)))
+	require.Equal(t, syscall.Errno(0), errno)
+	require.NotEqual(t, wasip1syscall.RawSocketAddress{}, addr)
+}
@@ -0,0 +1,90 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestIsolateRejectsForeignHandles(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.Unrestricted()
+
+	// raw host descriptors are not valid handles.
+	hostfd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	defer unix.Close(hostfd)
+
+	a := wnetruntime.Isolate(n)
+	_, err = a.LocalAddr(ctx, hostfd)
+	require.ErrorIs(t, err, syscall.EBADF)
+
+	fd, err := a.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NotEqual(t, hostfd, fd)
+	require.NoError(t, a.Bind(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}))
+
+	// handles from another module are not valid either.
+	b := wnetruntime.Isolate(n)
+	require.ErrorIs(t, b.Bind(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}), syscall.EBADF)
+	require.ErrorIs(t, b.Connect(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}), syscall.EBADF)
+}
+
+func TestIsolateAccept(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.Isolate(wnetruntime.Unrestricted())
+
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, n.Bind(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}))
+	require.NoError(t, n.Listen(ctx, fd, 1))
+
+	lsa, err := n.LocalAddr(ctx, fd)
+	require.NoError(t, err)
+
+	cfd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	if err = n.Connect(ctx, cfd, lsa); err != syscall.EINPROGRESS {
+		require.NoError(t, err)
+	}
+
+	var nfd int
+	for nfd, _, err = n.Accept(ctx, fd); err == syscall.EAGAIN; nfd, _, err = n.Accept(ctx, fd) {
+		require.NoError(t, ctx.Err())
+	}
+	require.NoError(t, err)
+	require.NotEqual(t, fd, nfd)
+	require.NotEqual(t, cfd, nfd)
+
+	_, err = n.PeerAddr(ctx, nfd)
+	require.NoError(t, err)
+}
+
+func TestIsolateCloseAll(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.Isolate(wnetruntime.Unrestricted())
+
+	a, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	b, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, n.Close(ctx, a))
+	require.ErrorIs(t, n.Close(ctx, a), syscall.EBADF)
+
+	require.NoError(t, n.CloseAll(ctx))
+	_, err = n.LocalAddr(ctx, b)
+	require.ErrorIs(t, err, syscall.EBADF)
+}
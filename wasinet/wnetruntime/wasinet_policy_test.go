@@ -0,0 +1,151 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+const examplepolicy = `
+default: deny
+rules:
+  - action: deny
+    directions: [dial]
+    cidrs: [10.0.0.1]
+  - action: allow
+    families: [ip4]
+    types: [stream]
+    directions: [dial]
+    cidrs: [10.0.0.0/8, 127.0.0.0/8]
+    ports: [443, 8000-8999]
+  - action: allow
+    directions: [listen]
+    families: [unix]
+    paths: [/tmp/]
+`
+
+func TestPolicyFirstMatchWins(t *testing.T) {
+	p, err := wnetruntime.LoadPolicy(strings.NewReader(examplepolicy))
+	require.NoError(t, err)
+
+	require.ErrorIs(t, p.Check(wnetruntime.DirectionDial, syscall.SOCK_STREAM, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 443}), syscall.EACCES)
+	require.NoError(t, p.Check(wnetruntime.DirectionDial, syscall.SOCK_STREAM, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 2}, Port: 443}))
+	require.NoError(t, p.Check(wnetruntime.DirectionDial, syscall.SOCK_STREAM, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 2}, Port: 8080}))
+	require.ErrorIs(t, p.Check(wnetruntime.DirectionDial, syscall.SOCK_STREAM, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 2}, Port: 80}), syscall.EACCES)
+	require.ErrorIs(t, p.Check(wnetruntime.DirectionDial, syscall.SOCK_DGRAM, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 2}, Port: 443}), syscall.EACCES)
+	require.ErrorIs(t, p.Check(wnetruntime.DirectionBind, syscall.SOCK_STREAM, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 2}, Port: 443}), syscall.EACCES)
+	require.NoError(t, p.Check(wnetruntime.DirectionBind, syscall.SOCK_STREAM, &unix.SockaddrUnix{Name: "/tmp/example.socket"}))
+	require.ErrorIs(t, p.Check(wnetruntime.DirectionBind, syscall.SOCK_STREAM, &unix.SockaddrUnix{Name: "/var/run/example.socket"}), syscall.EACCES)
+}
+
+func TestPolicyJSON(t *testing.T) {
+	p, err := wnetruntime.LoadPolicy(strings.NewReader(`{"default": "allow", "rules": [{"action": "deny", "families": ["ip6"]}]}`))
+	require.NoError(t, err)
+
+	require.NoError(t, p.Check(wnetruntime.DirectionSendTo, syscall.SOCK_DGRAM, &unix.SockaddrInet4{Addr: [4]byte{1, 1, 1, 1}, Port: 53}))
+	require.ErrorIs(t, p.Check(wnetruntime.DirectionSendTo, syscall.SOCK_DGRAM, &unix.SockaddrInet6{Port: 53}), syscall.EACCES)
+}
+
+func TestPolicyMappedAddresses(t *testing.T) {
+	p, err := wnetruntime.LoadPolicy(strings.NewReader(`{"default": "allow", "rules": [{"action": "deny", "families": ["ip4"]}]}`))
+	require.NoError(t, err)
+
+	mapped := &unix.SockaddrInet6{Addr: [16]byte(netip.MustParseAddr("::ffff:1.1.1.1").As16()), Port: 53}
+	require.ErrorIs(t, p.Check(wnetruntime.DirectionSendTo, syscall.SOCK_DGRAM, mapped), syscall.EACCES)
+	require.NoError(t, p.Check(wnetruntime.DirectionSendTo, syscall.SOCK_DGRAM, &unix.SockaddrInet6{Addr: [16]byte(netip.MustParseAddr("2001:db8::1").As16()), Port: 53}))
+}
+
+func TestPolicyPaths(t *testing.T) {
+	p, err := wnetruntime.LoadPolicy(strings.NewReader(`{"default": "deny", "rules": [{"action": "allow", "paths": ["/tmp/app"]}]}`))
+	require.NoError(t, err)
+
+	check := func(path string) error {
+		return p.Check(wnetruntime.DirectionBind, syscall.SOCK_STREAM, &unix.SockaddrUnix{Name: path})
+	}
+
+	require.NoError(t, check("/tmp/app"))
+	require.NoError(t, check("/tmp/app/example.socket"))
+	require.ErrorIs(t, check("/tmp/app/../../etc/example.socket"), syscall.EACCES)
+	require.ErrorIs(t, check("/tmp/application.socket"), syscall.EACCES)
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	_, err := wnetruntime.LoadPolicy(strings.NewReader(`{"rules": [{"action": "maybe"}]}`))
+	require.Error(t, err)
+
+	_, err = wnetruntime.LoadPolicy(strings.NewReader(`{"rules": [{"action": "allow", "ports": ["90-80"]}]}`))
+	require.Error(t, err)
+}
+
+func TestPolicyReload(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	p, err := wnetruntime.LoadPolicy(strings.NewReader(`default: allow`))
+	require.NoError(t, err)
+
+	n := wnetruntime.New(wnetruntime.OptionPolicy(p))
+	require.NoError(t, checkConnect(ctx, t, n, listenloopback(t)))
+
+	// invalid documents leave the existing rules in place.
+	require.Error(t, p.Reload(strings.NewReader(`default: maybe`)))
+	require.NoError(t, checkConnect(ctx, t, n, listenloopback(t)))
+
+	require.NoError(t, p.Reload(strings.NewReader(`default: deny`)))
+	require.ErrorIs(t, checkConnect(ctx, t, n, listenloopback(t)), syscall.EACCES)
+}
+
+func TestPolicyInterfaces(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	p, err := wnetruntime.LoadPolicy(strings.NewReader(`
+default: allow
+interfaces:
+  - action: deny
+    names: [lo*]
+`))
+	require.NoError(t, err)
+
+	require.ErrorIs(t, p.CheckInterface("lo"), syscall.EACCES)
+	require.NoError(t, p.CheckInterface("eth0"))
+
+	ifaces, err := wnetruntime.Unrestricted().Interfaces(ctx)
+	require.NoError(t, err)
+	require.True(t, slices.ContainsFunc(ifaces, func(iface wasip1syscall.Interface) bool { return iface.Name == "lo" }))
+
+	n := wnetruntime.New(wnetruntime.OptionPolicy(p))
+	ifaces, err = n.Interfaces(ctx)
+	require.NoError(t, err)
+	require.False(t, slices.ContainsFunc(ifaces, func(iface wasip1syscall.Interface) bool { return iface.Name == "lo" }))
+
+	// sockets can't be bound to hidden interfaces.
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+	defer n.Close(ctx, fd)
+	require.ErrorIs(t, n.SetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_BINDTODEVICE, []byte("lo\x00")), syscall.EACCES)
+
+	// interfaces have their own default, independent of the socket rules.
+	require.NoError(t, p.Reload(strings.NewReader(`default: deny`)))
+	ifaces, err = wnetruntime.New(wnetruntime.OptionPolicy(p)).Interfaces(ctx)
+	require.NoError(t, err)
+	require.True(t, slices.ContainsFunc(ifaces, func(iface wasip1syscall.Interface) bool { return iface.Name == "lo" }))
+
+	require.NoError(t, p.Reload(strings.NewReader(`{"default": "allow", "interface_default": "deny"}`)))
+	ifaces, err = wnetruntime.New(wnetruntime.OptionPolicy(p)).Interfaces(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ifaces)
+
+	_, err = wnetruntime.LoadPolicy(strings.NewReader(`{"interfaces": [{"action": "deny", "names": ["[lo"]}]}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,286 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func listen(t testing.TB, serve func(net.Conn)) net.Listener {
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { li.Close() })
+
+	go func() {
+		for {
+			conn, err := li.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+
+	return li
+}
+
+func tunnel(conn net.Conn, dst string) {
+	upstream, err := net.Dial("tcp", dst)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	go io.Copy(upstream, conn)
+	_, _ = io.Copy(conn, upstream)
+}
+
+// socks5 is a minimal socks5 proxy stand in supporting username/password authentication.
+func socks5(t testing.TB, username, password string) net.Listener {
+	return listen(t, func(conn net.Conn) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte{0x05, 0x02}); err != nil {
+			return
+		}
+
+		// username/password negotiation.
+		creds := make([]byte, 2)
+		if _, err := io.ReadFull(conn, creds); err != nil {
+			return
+		}
+		user := make([]byte, creds[1])
+		if _, err := io.ReadFull(conn, user); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, creds[:1]); err != nil {
+			return
+		}
+		pass := make([]byte, creds[0])
+		if _, err := io.ReadFull(conn, pass); err != nil {
+			return
+		}
+		if string(user) != username || string(pass) != password {
+			_, _ = conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		if _, err := conn.Write([]byte{0x01, 0x00}); err != nil {
+			return
+		}
+
+		req := make([]byte, 4+4+2)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		dst := netip.AddrPortFrom(netip.AddrFrom4([4]byte(req[4:8])), binary.BigEndian.Uint16(req[8:]))
+		if _, err := conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+			return
+		}
+
+		tunnel(conn, dst.String())
+	})
+}
+
+// connectproxy is a minimal http CONNECT proxy stand in.
+func connectproxy(t testing.TB, authorization string) net.Listener {
+	return listen(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+
+		if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != authorization {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			return
+		}
+
+		tunnel(conn, req.Host)
+	})
+}
+
+// handshake waits for the proxied connect to complete the way the guest does, by polling SO_ERROR.
+func handshake(ctx context.Context, t testing.TB, n wnetruntime.Socket, fd int) error {
+	for {
+		v, err := n.GetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_ERROR, nil)
+		require.NoError(t, err)
+
+		switch errno := syscall.Errno(v.(int)); errno {
+		case syscall.EINPROGRESS:
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+		case 0:
+			return nil
+		default:
+			return errno
+		}
+	}
+}
+
+func proxiedEcho(ctx context.Context, t testing.TB, upstream *url.URL, opts ...wnetruntime.Option) error {
+	target := listen(t, func(conn net.Conn) { _, _ = io.Copy(conn, conn) })
+	ap := netip.MustParseAddrPort(target.Addr().String())
+
+	n := wnetruntime.Unrestricted(append(opts, wnetruntime.OptionProxy(upstream))...)
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { n.Close(ctx, fd) })
+
+	sa := &unix.SockaddrInet4{Addr: ap.Addr().As4(), Port: int(ap.Port())}
+	require.ErrorIs(t, n.Connect(ctx, fd, sa), syscall.EINPROGRESS)
+	if err = handshake(ctx, t, n, fd); err != nil {
+		return err
+	}
+
+	peer, err := n.PeerAddr(ctx, fd)
+	require.NoError(t, err)
+	require.Equal(t, sa, peer)
+
+	_, err = n.SendTo(ctx, fd, sa, [][]byte{[]byte("hello")}, nil, 0)
+	require.NoError(t, err)
+
+	buf := make([]byte, 16)
+	for {
+		nread, _, _, err := n.RecvFrom(ctx, fd, [][]byte{buf}, nil, 0)
+		if err == syscall.EAGAIN {
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buf[:nread]))
+		return nil
+	}
+}
+
+func TestProxySOCKS5(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	proxy := socks5(t, "user", "secret")
+	err := proxiedEcho(ctx, t, &url.URL{Scheme: "socks5", Host: proxy.Addr().String(), User: url.UserPassword("user", "secret")})
+	require.NoError(t, err)
+
+	err = proxiedEcho(ctx, t, &url.URL{Scheme: "socks5", Host: proxy.Addr().String(), User: url.UserPassword("user", "wrong")})
+	require.ErrorIs(t, err, syscall.EACCES)
+}
+
+func TestProxyHTTPConnect(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	proxy := connectproxy(t, "Basic dXNlcjpzZWNyZXQ=")
+	err := proxiedEcho(ctx, t, &url.URL{Scheme: "http", Host: proxy.Addr().String(), User: url.UserPassword("user", "secret")})
+	require.NoError(t, err)
+
+	err = proxiedEcho(ctx, t, &url.URL{Scheme: "http", Host: proxy.Addr().String()})
+	require.ErrorIs(t, err, syscall.EACCES)
+}
+
+func TestProxySOCKS5HUnsupported(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	proxy := socks5(t, "user", "secret")
+	n := wnetruntime.Unrestricted(wnetruntime.OptionProxy(&url.URL{Scheme: "socks5h", Host: proxy.Addr().String()}))
+
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	defer n.Close(ctx, fd)
+
+	require.ErrorIs(t, n.Connect(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: 80}), syscall.EOPNOTSUPP)
+}
+
+func TestProxyPolicyAppliesToDestination(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	proxy := socks5(t, "user", "secret")
+	n := wnetruntime.New(
+		wnetruntime.OptionAllow(netip.MustParsePrefix("10.0.0.0/8")),
+		wnetruntime.OptionProxy(&url.URL{Scheme: "socks5", Host: proxy.Addr().String(), User: url.UserPassword("user", "secret")}),
+	)
+
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	defer n.Close(ctx, fd)
+
+	require.ErrorIs(t, n.Connect(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: 80}), syscall.EACCES)
+}
+
+func TestProxyResolvesWithHosts(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	proxy := socks5(t, "user", "secret")
+	ap := netip.MustParseAddrPort(proxy.Addr().String())
+	upstream := &url.URL{Scheme: "socks5", Host: net.JoinHostPort("proxy.example", fmt.Sprint(ap.Port())), User: url.UserPassword("user", "secret")}
+
+	err := proxiedEcho(ctx, t, upstream, wnetruntime.OptionHosts(map[string][]net.IP{"proxy.example": {net.IP(ap.Addr().AsSlice())}}))
+	require.NoError(t, err)
+}
+
+func TestProxyHandshakeDoesNotBlock(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	accepted := make(chan struct{})
+	silent := listen(t, func(conn net.Conn) {
+		close(accepted)
+		_, _ = io.Copy(io.Discard, conn)
+	})
+
+	n := wnetruntime.Unrestricted(wnetruntime.OptionProxy(&url.URL{Scheme: "socks5", Host: silent.Addr().String()}))
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+
+	sa := &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: 80}
+	require.ErrorIs(t, n.Connect(ctx, fd, sa), syscall.EINPROGRESS)
+	require.ErrorIs(t, n.Connect(ctx, fd, sa), syscall.EALREADY)
+
+	select {
+	case <-accepted:
+	case <-ctx.Done():
+		require.FailNow(t, "proxy never saw the connection")
+	}
+
+	v, err := n.GetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_ERROR, nil)
+	require.NoError(t, err)
+	require.Equal(t, int(syscall.EINPROGRESS), v)
+
+	_, err = n.PeerAddr(ctx, fd)
+	require.ErrorIs(t, err, syscall.ENOTCONN)
+
+	// closing the socket abandons the handshake.
+	require.NoError(t, n.Close(ctx, fd))
+}
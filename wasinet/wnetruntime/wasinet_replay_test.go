@@ -0,0 +1,142 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime/vnet"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type session struct {
+	ips      []net.IP
+	refused  error
+	local    unix.Sockaddr
+	received []string
+}
+
+// exchange a few messages with an echo server, the same sequence of calls
+// is made against the recorded and replayed sockets.
+func exchange(ctx context.Context, t testing.TB, n wnetruntime.Socket) (s session) {
+	var err error
+	s.ips, err = n.AddrIP(ctx, "ip4", "service.internal")
+	require.NoError(t, err)
+
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	s.refused = n.Connect(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{10, 0, 0, 1}, Port: 81})
+	require.NoError(t, n.Close(ctx, fd))
+
+	fd, err = n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, n.Connect(ctx, fd, vaddr))
+	s.local, err = n.LocalAddr(ctx, fd)
+	require.NoError(t, err)
+
+	for _, msg := range []string{"hello", "world"} {
+		_, err = n.SendTo(ctx, fd, nil, [][]byte{[]byte(msg)}, nil, 0)
+		require.NoError(t, err)
+
+		buf := make([]byte, 16)
+		for {
+			nread, _, _, err := n.RecvFrom(ctx, fd, [][]byte{buf}, nil, 0)
+			if err == syscall.EAGAIN {
+				continue
+			}
+			require.NoError(t, err)
+			s.received = append(s.received, string(buf[:nread]))
+			break
+		}
+	}
+
+	require.NoError(t, n.Shutdown(ctx, fd, syscall.SHUT_RDWR))
+	require.NoError(t, n.Close(ctx, fd))
+	return s
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	vn := vnet.New(vnet.OptionHosts(map[string][]net.IP{
+		"service.internal": {net.IPv4(10, 0, 0, 1).To4()},
+	}))
+	li, err := vn.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer li.Close()
+
+	go func() {
+		conn, err := li.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	recording := bytes.NewBuffer(nil)
+	recorded := exchange(ctx, t, wnetruntime.Record(vn.Socket(), recording))
+	require.ErrorIs(t, recorded.refused, syscall.ECONNREFUSED)
+	require.Equal(t, []string{"hello", "world"}, recorded.received)
+
+	// the network is no longer available, the replay must not depend on it.
+	require.NoError(t, li.Close())
+
+	replay, err := wnetruntime.Replay(bytes.NewReader(recording.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, recorded, exchange(ctx, t, replay))
+	require.Zero(t, replay.Remaining())
+
+	_, err = replay.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.ErrorIs(t, err, wnetruntime.ErrReplayDiverged)
+}
+
+func TestReplayDiverged(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	recording := bytes.NewBuffer(nil)
+	n := wnetruntime.Record(vnet.New().Socket(), recording)
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+	_, err = n.SendTo(ctx, fd, vaddr, [][]byte{[]byte("hello")}, nil, 0)
+	require.NoError(t, err)
+
+	replay, err := wnetruntime.Replay(recording)
+	require.NoError(t, err)
+	rfd, err := replay.Open(ctx, syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+	require.Equal(t, fd, rfd)
+
+	_, err = replay.SendTo(ctx, fd, vaddr, [][]byte{[]byte("world")}, nil, 0)
+	require.ErrorIs(t, err, wnetruntime.ErrReplayDiverged)
+
+	// a diverged call does not consume the recording.
+	_, err = replay.SendTo(ctx, fd, vaddr, [][]byte{[]byte("hello")}, nil, 0)
+	require.NoError(t, err)
+}
+
+func TestReplayInterfaces(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	recording := bytes.NewBuffer(nil)
+	recorded, err := wnetruntime.Record(vnet.New().Socket(), recording).Interfaces(ctx)
+	require.NoError(t, err)
+	require.Len(t, recorded, 1)
+
+	replay, err := wnetruntime.Replay(recording)
+	require.NoError(t, err)
+	replayed, err := replay.Interfaces(ctx)
+	require.NoError(t, err)
+	require.Equal(t, recorded, replayed)
+}
@@ -0,0 +1,142 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/ffi"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSocketOptions(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.Unrestricted()
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	defer n.Close(ctx, fd)
+
+	setint := func(level, name int, value int32) {
+		require.NoError(t, n.SetSocketOption(ctx, fd, level, name, int32le(value)))
+		v, err := n.GetSocketOption(ctx, fd, level, name, nil)
+		require.NoError(t, err)
+		require.Equal(t, int(value), v)
+	}
+
+	setint(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_NODELAY, 1)
+	setint(wasip1syscall.SOL_SOCKET, wasip1syscall.SO_KEEPALIVE, 1)
+	setint(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPIDLE, 5)
+	setint(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPINTVL, 2)
+	setint(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPCNT, 3)
+
+	require.NoError(t, n.SetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_LINGER, int32le(1, 7)))
+	linger, err := unix.GetsockoptLinger(fd, unix.SOL_SOCKET, unix.SO_LINGER)
+	require.NoError(t, err)
+	require.Equal(t, unix.Linger{Onoff: 1, Linger: 7}, *linger)
+}
+
+func TestSocketOptionsTyped(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	n := wnetruntime.Unrestricted()
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	defer n.Close(ctx, fd)
+
+	get := func(level, name int) any {
+		v, err := n.GetSocketOption(ctx, fd, level, name, nil)
+		require.NoError(t, err)
+		return v
+	}
+
+	require.NoError(t, n.SetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVTIMEO, timevalle(3, 500000)))
+	require.Equal(t, wasip1syscall.Timeval{Sec: 3, Usec: 500000}, get(wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVTIMEO))
+	require.NoError(t, n.SetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_LINGER, int32le(1, 7)))
+	require.Equal(t, wasip1syscall.Linger{Onoff: 1, Linger: 7}, get(wasip1syscall.SOL_SOCKET, wasip1syscall.SO_LINGER))
+	require.Equal(t, "", get(wasip1syscall.SOL_SOCKET, wasip1syscall.SO_BINDTODEVICE))
+
+	sa := &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}, Port: li.Addr().(*net.TCPAddr).Port}
+	for err = n.Connect(ctx, fd, sa); err == unix.EINPROGRESS || err == unix.EALREADY; err = n.Connect(ctx, fd, sa) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, err == nil || err == unix.EISCONN, err)
+
+	info, ok := get(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_INFO).(wasip1syscall.TCPInfo)
+	require.True(t, ok)
+	require.Equal(t, uint32(unix.BPF_TCP_ESTABLISHED), info.State)
+	require.NotZero(t, info.SndMSS)
+	require.NotZero(t, info.SndCwnd)
+}
+
+func TestSocketOptionsTypedReplay(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	recording := bytes.NewBuffer(nil)
+	n := wnetruntime.Record(wnetruntime.Unrestricted(), recording)
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, n.SetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_SNDTIMEO, timevalle(1, 0)))
+	recorded, err := n.GetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_SNDTIMEO, nil)
+	require.NoError(t, err)
+	require.NoError(t, n.Close(ctx, fd))
+
+	replay, err := wnetruntime.Replay(recording)
+	require.NoError(t, err)
+	fd, err = replay.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, replay.SetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_SNDTIMEO, timevalle(1, 0)))
+	replayed, err := replay.GetSocketOption(ctx, fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_SNDTIMEO, nil)
+	require.NoError(t, err)
+
+	// replayed options are reproduced by their abi encoding.
+	encoded, err := wasip1syscall.SockoptBytes(recorded)
+	require.NoError(t, err)
+	require.Equal(t, encoded, replayed)
+}
+
+func int32le(values ...int32) []byte {
+	buf := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+	}
+	return buf
+}
+
+func timevalle(sec, usec int64) []byte {
+	return binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, uint64(sec)), uint64(usec))
+}
+
+func TestSocketGetOptBufferTooSmall(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	getopt := wnetruntime.SocketGetOpt(func(ctx context.Context, fd int, level, name int, value []byte) (any, error) {
+		return wasip1syscall.Timeval{Sec: 3}, nil
+	})
+
+	var (
+		buf [4]byte
+	)
+
+	bufptr, buflen := ffi.Pointer(&buf)
+	errno := getopt(ctx, ffi.Native{}, 0, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVTIMEO, uintptr(bufptr), buflen)
+	require.Equal(t, wasip1syscall.EINVAL, errno)
+}
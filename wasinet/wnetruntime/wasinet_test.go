@@ -0,0 +1,136 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func listenloopback(t testing.TB) *net.TCPAddr {
+	li, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, li.Close())
+	})
+
+	return li.Addr().(*net.TCPAddr)
+}
+
+func checkConnect(ctx context.Context, t testing.TB, n wnetruntime.Socket, addr *net.TCPAddr) error {
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		unix.Close(fd)
+	})
+
+	sa := &unix.SockaddrInet4{Port: addr.Port, Addr: ([4]byte)(addr.IP.To4())}
+	if err = n.Connect(ctx, fd, sa); err == syscall.EINPROGRESS {
+		return nil
+	}
+
+	return err
+}
+
+func TestNewDeniesAll(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	require.ErrorIs(t, checkConnect(ctx, t, wnetruntime.New(), listenloopback(t)), syscall.EACCES)
+}
+
+func TestUnrestrictedAllowsAll(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	require.NoError(t, checkConnect(ctx, t, wnetruntime.Unrestricted(), listenloopback(t)))
+}
+
+func TestOptionAllow(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	allowed := wnetruntime.New(wnetruntime.OptionAllow(netip.MustParsePrefix("127.0.0.0/8")))
+	require.NoError(t, checkConnect(ctx, t, allowed, listenloopback(t)))
+
+	denied := wnetruntime.New(wnetruntime.OptionAllow(netip.MustParsePrefix("10.0.0.0/8")))
+	require.ErrorIs(t, checkConnect(ctx, t, denied, listenloopback(t)), syscall.EACCES)
+}
+
+func TestOptionAllowBind(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.New(wnetruntime.OptionAllow(netip.MustParsePrefix("127.0.0.1/32")))
+	fd, err := n.Open(ctx, syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	defer unix.Close(fd)
+
+	require.ErrorIs(t, n.Bind(ctx, fd, &unix.SockaddrInet4{}), syscall.EACCES)
+	require.NoError(t, n.Bind(ctx, fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}))
+}
+
+func TestOptionFSPrefixesBind(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	tmpdir := t.TempDir()
+	n := wnetruntime.New(wnetruntime.OptionFSPrefixes(wnetruntime.FSPrefix{Host: tmpdir, Guest: "/guest"}))
+
+	bind := func(path string) error {
+		fd, err := n.Open(ctx, syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+		require.NoError(t, err)
+		defer unix.Close(fd)
+
+		return n.Bind(ctx, fd, &unix.SockaddrUnix{Name: path})
+	}
+
+	require.ErrorIs(t, bind("/guest/../etc/socket"), syscall.EACCES)
+	require.ErrorIs(t, bind("/guestbar/socket"), syscall.EACCES)
+	require.ErrorIs(t, bind(filepath.Join(tmpdir, "direct")), syscall.EACCES)
+
+	// the socket is bound at the host path.
+	require.NoError(t, bind("/guest/nested/../socket"))
+	require.FileExists(t, filepath.Join(tmpdir, "socket"))
+	require.NoFileExists(t, "/guest/socket")
+}
+
+func TestOptionFSPrefixesSendTo(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	tmpdir := t.TempDir()
+	rfd, err := unix.Socket(unix.AF_UNIX, unix.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+	defer unix.Close(rfd)
+	require.NoError(t, unix.Bind(rfd, &unix.SockaddrUnix{Name: filepath.Join(tmpdir, "socket")}))
+
+	n := wnetruntime.New(wnetruntime.OptionFSPrefixes(wnetruntime.FSPrefix{Host: tmpdir, Guest: "/guest"}))
+	fd, err := n.Open(ctx, syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	require.NoError(t, err)
+	defer n.Close(ctx, fd)
+
+	send := func(path string) error {
+		_, err := n.SendTo(ctx, fd, &unix.SockaddrUnix{Name: path}, [][]byte{[]byte("hello")}, nil, 0)
+		return err
+	}
+
+	require.ErrorIs(t, send(filepath.Join(tmpdir, "socket")), syscall.EACCES)
+	require.ErrorIs(t, send("/guest/../socket"), syscall.EACCES)
+
+	// datagrams are delivered to the host path.
+	require.NoError(t, send("/guest/nested/../socket"))
+	buf := make([]byte, 16)
+	nread, err := unix.Read(rfd, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf[:nread]))
+}
@@ -0,0 +1,68 @@
+//go:build !wasip1 && linux
+
+package wnetruntime_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/egdaemon/wasinet/wasinet/ffi"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+	"github.com/egdaemon/wasinet/wasinet/testx"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustBundle(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	require.NoError(t, os.WriteFile(bundle, []byte("system"), 0600))
+	t.Setenv("SSL_CERT_FILE", bundle)
+
+	pem, err := wnetruntime.New().TrustBundle(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "system", string(pem))
+
+	pem, err = wnetruntime.New(wnetruntime.OptionTrustBundle([]byte("override"))).TrustBundle(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "override", string(pem))
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.pem"), []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("b"), 0600))
+	t.Setenv("SSL_CERT_FILE", filepath.Join(dir, "missing.pem"))
+	t.Setenv("SSL_CERT_DIR", dir)
+
+	pem, err = wnetruntime.SystemTrustBundle()
+	require.NoError(t, err)
+	require.Equal(t, "a\n", string(pem))
+
+	t.Setenv("SSL_CERT_DIR", filepath.Join(dir, "missing"))
+	_, err = wnetruntime.SystemTrustBundle()
+	require.ErrorIs(t, err, syscall.ENOENT)
+}
+
+func TestSocketTrustBundleUnavailable(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	var (
+		buf    [16]byte
+		reslen uint32
+	)
+
+	bufptr, buflen := ffi.Slice(buf[:])
+	resptr, _ := ffi.Pointer(&reslen)
+	unavailable := wnetruntime.SocketTrustBundle(func(ctx context.Context) ([]byte, error) {
+		return nil, errors.New("no bundle")
+	})
+
+	// the guest observes wasi's ENOENT regardless of the host's numbering.
+	require.Equal(t, wasip1syscall.ENOENT, unavailable(ctx, ffi.Native{}, uintptr(bufptr), buflen, uintptr(resptr)))
+}
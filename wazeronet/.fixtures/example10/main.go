@@ -0,0 +1,56 @@
+// Package example10 exercises multicast group membership.
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+// receive joins the group and waits for a datagram from the host.
+func receive(ifi *net.Interface, address string) {
+	gaddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		log.Fatalln("resolve failed", address, err)
+	}
+
+	pc, err := wasinet.ListenMulticastUDP(context.Background(), "udp", ifi, gaddr)
+	if err != nil {
+		log.Fatalln("listen multicast failed", address, err)
+	}
+	defer pc.Close()
+
+	buf := make([]byte, 128)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		log.Fatalln("read from failed", address, err)
+	}
+
+	if string(buf[:n]) != "hello" {
+		log.Fatalln("unexpected payload", string(buf[:n]))
+	}
+
+	if err = wasinet.LeaveGroup(pc, ifi, gaddr.IP); err != nil {
+		log.Fatalln("leave group failed", address, err)
+	}
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	idx, err := strconv.Atoi(os.Getenv("WASINET_MULTICAST_IFINDEX"))
+	if err != nil {
+		log.Fatalln("invalid interface index", err)
+	}
+	ifi := &net.Interface{Index: idx}
+
+	receive(ifi, os.Getenv("WASINET_MULTICAST4_ADDRESS"))
+
+	if address := os.Getenv("WASINET_MULTICAST6_ADDRESS"); address != "" {
+		receive(ifi, address)
+	}
+}
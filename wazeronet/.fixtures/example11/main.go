@@ -0,0 +1,48 @@
+// Package example11 exercises the host's network interfaces.
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	ifaces, err := wasinet.Interfaces()
+	if err != nil {
+		log.Fatalln("interfaces failed", err)
+	}
+
+	for _, iface := range ifaces {
+		log.Println("interface", iface.Index, iface.Name, iface.MTU, iface.Flags)
+		if iface.Name == os.Getenv("WASINET_HIDDEN_INTERFACE") {
+			log.Fatalln("hidden interface is visible", iface.Name)
+		}
+	}
+
+	ifi, err := wasinet.InterfaceByName(os.Getenv("WASINET_VISIBLE_INTERFACE"))
+	if err != nil {
+		log.Fatalln("interface by name failed", err)
+	}
+
+	if ifi.Flags&net.FlagUp == 0 {
+		log.Fatalln("expected the interface to be up", ifi.Flags)
+	}
+
+	addrs, err := wasinet.InterfaceAddrs(ifi)
+	if err != nil {
+		log.Fatalln("interface addrs failed", err)
+	}
+
+	if len(addrs) == 0 {
+		log.Fatalln("expected the interface to have addresses", ifi.Name)
+	}
+
+	if _, err = wasinet.InterfaceByName(os.Getenv("WASINET_HIDDEN_INTERFACE")); err == nil {
+		log.Fatalln("expected the hidden interface to be missing")
+	}
+}
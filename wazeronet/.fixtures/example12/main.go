@@ -0,0 +1,56 @@
+// Package example12 exercises record lookups through the host's resolver.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"slices"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	var (
+		dnserr *net.DNSError
+	)
+
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	ctx := context.Background()
+	r := &wasinet.Resolver{}
+
+	mx, err := r.LookupMX(ctx, "example.internal")
+	if err != nil || len(mx) != 1 || mx[0].Host != "mail.example.internal." || mx[0].Pref != 10 {
+		log.Fatalln("lookup mx failed", mx, err)
+	}
+
+	txt, err := r.LookupTXT(ctx, "example.internal")
+	if err != nil || !slices.Equal(txt, []string{"v=spf1 -all"}) {
+		log.Fatalln("lookup txt failed", txt, err)
+	}
+
+	ns, err := r.LookupNS(ctx, "example.internal")
+	if err != nil || len(ns) != 1 || ns[0].Host != "ns.example.internal." {
+		log.Fatalln("lookup ns failed", ns, err)
+	}
+
+	cname, srv, err := r.LookupSRV(ctx, "http", "tcp", "example.internal")
+	if err != nil || cname != "_http._tcp.example.internal." || len(srv) != 1 || srv[0].Target != "www.example.internal." || srv[0].Port != 8080 {
+		log.Fatalln("lookup srv failed", cname, srv, err)
+	}
+
+	if cname, err = r.LookupCNAME(ctx, "pinned.internal"); err != nil || cname != "pinned.internal." {
+		log.Fatalln("lookup cname failed", cname, err)
+	}
+
+	names, err := r.LookupAddr(ctx, "10.0.0.1")
+	if err != nil || !slices.Equal(names, []string{"pinned.internal."}) {
+		log.Fatalln("lookup addr failed", names, err)
+	}
+
+	if txt, err = r.LookupTXT(ctx, "example.denied.internal"); !errors.As(err, &dnserr) || !dnserr.IsNotFound {
+		log.Fatalln("expected denied lookup to fail", txt, err)
+	}
+}
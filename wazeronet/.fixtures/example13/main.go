@@ -0,0 +1,45 @@
+// Package example13 exercises names resolving to large sets of addresses.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	expected, err := strconv.Atoi(os.Getenv("WASINET_LOOKUP_COUNT"))
+	if err != nil {
+		log.Fatalln("invalid count", err)
+	}
+
+	ttl, err := time.ParseDuration(os.Getenv("WASINET_LOOKUP_TTL"))
+	if err != nil {
+		log.Fatalln("invalid ttl", err)
+	}
+
+	records, err := wasip1syscall.LookupIP(context.Background(), "ip", os.Getenv("WASINET_LOOKUP_NAME"))
+	if err != nil {
+		log.Fatalln("lookup failed", err)
+	}
+
+	if len(records) != expected {
+		log.Fatalln("unexpected number of addresses", len(records), "expected", expected)
+	}
+
+	for _, r := range records {
+		if r.TTL != ttl {
+			log.Fatalln("unexpected ttl", r.IP, r.TTL, "expected", ttl)
+		}
+	}
+
+	if len(records[0].IP) != 4 || len(records[len(records)-1].IP) != 16 {
+		log.Fatalln("unexpected address families", records[0].IP, records[len(records)-1].IP)
+	}
+}
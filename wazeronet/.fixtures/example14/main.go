@@ -0,0 +1,36 @@
+// Package example14 exercises dns failures reported by the host.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+func main() {
+	var dnserr *net.DNSError
+
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	_, err := wasip1syscall.LookupIP(context.Background(), "ip", os.Getenv("WASINET_LOOKUP_NAME"))
+	if !errors.As(err, &dnserr) {
+		log.Fatalln("expected a dns error", err)
+	}
+
+	switch expected := os.Getenv("WASINET_LOOKUP_ERROR"); expected {
+	case "notfound":
+		if !dnserr.IsNotFound || dnserr.IsTimeout {
+			log.Fatalln("expected the name to not exist", dnserr)
+		}
+	case "timeout":
+		if !dnserr.Timeout() || !dnserr.Temporary() || dnserr.IsNotFound {
+			log.Fatalln("expected the lookup to time out", dnserr)
+		}
+	default:
+		log.Fatalln("unknown expectation", expected)
+	}
+}
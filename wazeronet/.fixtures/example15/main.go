@@ -0,0 +1,39 @@
+// Package example15 exercises the guest's dns cache.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+func lookup(ctx context.Context, name string) {
+	addrs, err := wasip1syscall.LookupAddress(ctx, "dial", "tcp4", name)
+	if err != nil {
+		log.Fatalln("lookup failed", err)
+	}
+
+	if len(addrs) == 0 {
+		log.Fatalln("expected addresses", name)
+	}
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	ctx := context.Background()
+	name := os.Getenv("WASINET_LOOKUP_NAME")
+
+	wasinet.EnableDNSCache(wasinet.OptionDNSCacheSize(8))
+	defer wasinet.DisableDNSCache()
+
+	for range 10 {
+		lookup(ctx, name)
+	}
+
+	wasinet.FlushDNSCache()
+	lookup(ctx, name)
+}
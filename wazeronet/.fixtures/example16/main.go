@@ -0,0 +1,47 @@
+// Package example16 exercises the guest's deadline bounding name resolution on the host.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	var dnserr *net.DNSError
+
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	timeout, err := time.ParseDuration(os.Getenv("WASINET_DIAL_TIMEOUT"))
+	if err != nil {
+		log.Fatalln("invalid timeout", err)
+	}
+
+	started := time.Now()
+	_, err = (&wasinet.Dialer{Timeout: timeout}).Dial("tcp", os.Getenv("WASINET_DIAL_ADDRESS"))
+	if !errors.As(err, &dnserr) || !dnserr.Timeout() {
+		log.Fatalln("expected the lookup to time out", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 4*timeout {
+		log.Fatalln("lookup exceeded the dial timeout", elapsed, "timeout", timeout)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), timeout)
+	defer done()
+
+	started = time.Now()
+	_, err = (&wasinet.Resolver{}).LookupMX(ctx, "unresponsive.internal")
+	if !errors.As(err, &dnserr) || !dnserr.Timeout() {
+		log.Fatalln("expected the lookup to time out", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 4*timeout {
+		log.Fatalln("lookup exceeded the context deadline", elapsed, "timeout", timeout)
+	}
+}
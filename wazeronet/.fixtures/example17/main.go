@@ -0,0 +1,42 @@
+// Package example17 exercises releasing host sockets when connections are closed.
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	n, err := strconv.Atoi(os.Getenv("WASINET_CLOSE_COUNT"))
+	if err != nil {
+		log.Fatalln("invalid connection count", err)
+	}
+
+	for i := 0; i < n; i++ {
+		conn, err := wasinet.Dial("tcp", os.Getenv("WASINET_CLOSE_ADDRESS"))
+		if err != nil {
+			log.Fatalln("dial failed", err)
+		}
+
+		if err = conn.Close(); err != nil {
+			log.Fatalln("close failed", err)
+		}
+	}
+
+	// inform the host the connections have been closed while the guest is still running.
+	conn, err := wasinet.Dial("tcp", os.Getenv("WASINET_CLOSE_SYNC"))
+	if err != nil {
+		log.Fatalln("dial failed", err)
+	}
+	defer conn.Close()
+
+	if _, err = io.Copy(io.Discard, conn); err != nil {
+		log.Fatalln("sync failed", err)
+	}
+}
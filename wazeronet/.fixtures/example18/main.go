@@ -0,0 +1,78 @@
+// Package example18 exercises keep-alive on accepted connections.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+// accept a connection from the listener and report its keep-alive options.
+func accept(ctx context.Context, lc wasinet.ListenConfig) (li net.Listener, keepalive, idle int) {
+	li, err := lc.Listen(ctx, "tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalln("listen failed", err)
+	}
+	defer li.Close()
+
+	go func() {
+		conn, err := wasinet.Dial("tcp", li.Addr().String())
+		if err != nil {
+			log.Fatalln("dial failed", err)
+		}
+		defer conn.Close()
+		time.Sleep(time.Second)
+	}()
+
+	conn, err := li.Accept()
+	if err != nil {
+		log.Fatalln("accept failed", err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		log.Fatalln("raw connection failed", err)
+	}
+
+	if cerr := raw.Control(func(fd uintptr) {
+		if keepalive, err = wasip1syscall.GetSockoptInt(int(fd), wasip1syscall.SOL_SOCKET, wasip1syscall.SO_KEEPALIVE); err != nil {
+			return
+		}
+		idle, err = wasip1syscall.GetSockoptInt(int(fd), wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPIDLE)
+	}); cerr != nil || err != nil {
+		log.Fatalln("getsockopt failed", cerr, err)
+	}
+
+	return li, keepalive, idle
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	ctx := context.Background()
+
+	li, keepalive, idle := accept(ctx, wasinet.ListenConfig{KeepAlive: 30 * time.Second})
+	if keepalive != 1 || idle != 30 {
+		log.Fatalln("unexpected keep-alive", keepalive, idle)
+	}
+
+	disabled, keepalive, _ := accept(ctx, wasinet.ListenConfig{KeepAlive: -1})
+	if keepalive != 0 {
+		log.Fatalln("unexpected keep-alive", keepalive)
+	}
+
+	// the listener's concrete type is the same regardless of the keep-alive configuration.
+	if fmt.Sprintf("%T", li) != fmt.Sprintf("%T", disabled) {
+		log.Fatalf("listener types differ %T %T\n", li, disabled)
+	}
+
+	if _, ok := li.(interface{ SetDeadline(time.Time) error }); !ok {
+		log.Fatalf("listener %T does not support deadlines\n", li)
+	}
+}
@@ -0,0 +1,51 @@
+// Package example19 exercises write deadlines on streams the peer isn't reading.
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	conn, err := wasinet.Dial("tcp", os.Getenv("WASINET_SINK_ADDRESS"))
+	if err != nil {
+		log.Fatalln("dial failed", err)
+	}
+	defer conn.Close()
+
+	if err = conn.SetWriteDeadline(time.Now().Add(500 * time.Millisecond)); err != nil {
+		log.Fatalln("set write deadline failed", err)
+	}
+
+	var (
+		written int
+		buf     = make([]byte, 64*1024)
+	)
+
+	// the peer never reads, the host's buffers fill and the write waits for the deadline.
+	for {
+		n, err := conn.Write(buf)
+		written += n
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+
+		if err != nil {
+			log.Fatalln("write failed", written, err)
+		}
+
+		if n != len(buf) {
+			log.Fatalln("short write without an error", n)
+		}
+	}
+
+	if written == 0 {
+		log.Fatalln("nothing was written before the deadline")
+	}
+}
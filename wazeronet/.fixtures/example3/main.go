@@ -0,0 +1,21 @@
+// Package example3 leaves a listener open when it exits, the host is expected to release it.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	li, err := wasinet.Listen(context.Background(), "tcp", os.Getenv("WASINET_LISTEN_ADDRESS"))
+	if err != nil {
+		log.Fatalln("unable to listen", err)
+	}
+
+	log.Println("listening", li.Addr())
+}
@@ -0,0 +1,72 @@
+// Package example4 exercises streams and datagrams over a virtual network.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	if err := checkEcho("tcp", os.Getenv("WASINET_TCP_ADDRESS")); err != nil {
+		log.Fatalln("tcp echo failed", err)
+	}
+
+	if err := checkEcho("udp", os.Getenv("WASINET_UDP_ADDRESS")); err != nil {
+		log.Fatalln("udp echo failed", err)
+	}
+
+	if err := serveEcho(os.Getenv("WASINET_LISTEN_ADDRESS")); err != nil {
+		log.Fatalln("echo server failed", err)
+	}
+}
+
+func checkEcho(network, address string) error {
+	conn, err := wasinet.Dial(network, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	msg := fmt.Sprintf("hello %s", network)
+	if _, err = conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+
+	buf := make([]byte, len(msg))
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+
+	if string(buf) != msg {
+		return fmt.Errorf("unexpected response: %s", buf)
+	}
+
+	return nil
+}
+
+func serveEcho(address string) error {
+	li, err := wasinet.Listen(context.Background(), "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer li.Close()
+
+	conn, err := li.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = io.Copy(conn, conn); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+// Package example5 exercises host controlled name resolution.
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	conn, err := wasinet.Dial("tcp", os.Getenv("WASINET_PINNED_ADDRESS"))
+	if err != nil {
+		log.Fatalln("pinned name failed", err)
+	}
+	conn.Close()
+
+	_, err = wasinet.Dial("tcp", os.Getenv("WASINET_DENIED_ADDRESS"))
+	if dnserr := (*net.DNSError)(nil); !errors.As(err, &dnserr) || !dnserr.IsNotFound {
+		log.Fatalln("expected denied name to not be found", err)
+	}
+}
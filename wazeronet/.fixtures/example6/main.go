@@ -0,0 +1,30 @@
+// Package example6 exercises tls verification against the host's trusted certificates.
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	wasinet.HijackTrust()
+
+	resp, err := http.Get(os.Getenv("WASINET_HTTPS_URL"))
+	if err != nil {
+		log.Fatalln("request failed", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err = io.Copy(io.Discard, resp.Body); err != nil {
+		log.Fatalln("reading response failed", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalln("unexpected status", resp.StatusCode)
+	}
+}
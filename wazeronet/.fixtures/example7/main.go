@@ -0,0 +1,35 @@
+// Package example7 exercises dual stack dialing against an unresponsive ip6 address.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	address := os.Getenv("WASINET_DUALSTACK_ADDRESS")
+
+	started := time.Now()
+	conn, err := (&wasinet.Dialer{Timeout: 10 * time.Second, FallbackDelay: 100 * time.Millisecond}).Dial("tcp", address)
+	if err != nil {
+		log.Fatalln("dual stack dial failed", err)
+	}
+	conn.Close()
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		log.Fatalln("dual stack dial waited on the unresponsive address", elapsed)
+	}
+
+	// without the race the unresponsive address consumes the entire timeout.
+	_, err = (&wasinet.Dialer{Timeout: time.Second, FallbackDelay: -1}).Dial("tcp", address)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		log.Fatalln("expected the serial dial to time out", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Package example8 exercises tcp connection options.
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/egdaemon/wasinet/wasinet"
+	"github.com/egdaemon/wasinet/wasinet/stdlib/wasip1syscall"
+)
+
+type tcpconn interface {
+	net.Conn
+	syscall.Conn
+	SetNoDelay(bool) error
+	SetKeepAlive(bool) error
+	SetKeepAlivePeriod(time.Duration) error
+	SetKeepAliveConfig(net.KeepAliveConfig) error
+	SetLinger(int) error
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	conn, err := wasinet.Dial("tcp", os.Getenv("WASINET_LISTEN_ADDRESS"))
+	if err != nil {
+		log.Fatalln("dial failed", err)
+	}
+	defer conn.Close()
+
+	tcp, ok := conn.(tcpconn)
+	if !ok {
+		log.Fatalf("unexpected connection type %T\n", conn)
+	}
+
+	raw, err := tcp.SyscallConn()
+	if err != nil {
+		log.Fatalln("raw connection failed", err)
+	}
+
+	check := func(level, name, expected int) {
+		var value int
+		if cerr := raw.Control(func(fd uintptr) {
+			value, err = wasip1syscall.GetSockoptInt(int(fd), level, name)
+		}); cerr != nil || err != nil {
+			log.Fatalln("getsockopt failed", level, name, cerr, err)
+		}
+
+		if value != expected {
+			log.Fatalln("unexpected socket option", level, name, value, "expected", expected)
+		}
+	}
+
+	check(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_NODELAY, 1)
+	if err = tcp.SetNoDelay(false); err != nil {
+		log.Fatalln("set no delay failed", err)
+	}
+	check(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_NODELAY, 0)
+
+	if err = tcp.SetKeepAlive(true); err != nil {
+		log.Fatalln("set keepalive failed", err)
+	}
+	check(wasip1syscall.SOL_SOCKET, wasip1syscall.SO_KEEPALIVE, 1)
+
+	if err = tcp.SetKeepAlivePeriod(30 * time.Second); err != nil {
+		log.Fatalln("set keepalive period failed", err)
+	}
+	check(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPIDLE, 30)
+
+	if err = tcp.SetKeepAliveConfig(net.KeepAliveConfig{Enable: true, Idle: 5 * time.Second, Interval: 2 * time.Second, Count: 3}); err != nil {
+		log.Fatalln("set keepalive config failed", err)
+	}
+	check(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPIDLE, 5)
+	check(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPINTVL, 2)
+	check(wasip1syscall.IPPROTO_TCP, wasip1syscall.TCP_KEEPCNT, 3)
+
+	if err = tcp.SetLinger(0); err != nil {
+		log.Fatalln("set linger failed", err)
+	}
+
+	control := func(fn func(fd int) error) {
+		if cerr := raw.Control(func(fd uintptr) { err = fn(int(fd)) }); cerr != nil || err != nil {
+			log.Fatalln("raw control failed", cerr, err)
+		}
+	}
+
+	control(func(fd int) error {
+		sec, err := wasip1syscall.GetsockoptLinger(fd)
+		if err == nil && sec != 0 {
+			log.Fatalln("unexpected linger", sec)
+		}
+		return err
+	})
+
+	control(func(fd int) error {
+		if err := wasip1syscall.SetsockoptTimeval(fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVTIMEO, 2500*time.Millisecond); err != nil {
+			return err
+		}
+		d, err := wasip1syscall.GetsockoptTimeval(fd, wasip1syscall.SOL_SOCKET, wasip1syscall.SO_RCVTIMEO)
+		if err == nil && d != 2500*time.Millisecond {
+			log.Fatalln("unexpected receive timeout", d)
+		}
+		return err
+	})
+
+	control(func(fd int) error {
+		info, err := wasip1syscall.GetsockoptTCPInfo(fd)
+		if err == nil && (info.State != 1 || info.SndMSS == 0 || info.SndCwnd == 0) {
+			log.Fatalf("unexpected tcp info %+v\n", info)
+		}
+		return err
+	})
+}
@@ -0,0 +1,94 @@
+// Package example9 exercises ipv6 udp addressing.
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+
+	"github.com/egdaemon/wasinet/wasinet"
+)
+
+type udpconn interface {
+	net.Conn
+	ReadFromUDP([]byte) (int, *net.UDPAddr, error)
+	ReadMsgUDP(b, oob []byte) (n, oobn, flags int, addr *net.UDPAddr, err error)
+}
+
+// echo sends a datagram to the echo server and verifies the reply's source address.
+func echo(pc net.PacketConn, addr *net.UDPAddr) {
+	if _, err := pc.WriteTo([]byte("ping"), addr); err != nil {
+		log.Fatalln("write to failed", addr, err)
+	}
+
+	buf := make([]byte, 128)
+	n, from, err := pc.ReadFrom(buf)
+	if err != nil {
+		log.Fatalln("read from failed", err)
+	}
+
+	if string(buf[:n]) != "ping" {
+		log.Fatalln("unexpected payload", string(buf[:n]))
+	}
+
+	if from.(*net.UDPAddr).AddrPort() != addr.AddrPort() {
+		log.Fatalln("unexpected source address", from, "expected", addr)
+	}
+}
+
+func main() {
+	log.SetFlags(log.Flags() | log.Lshortfile)
+
+	server, err := net.ResolveUDPAddr("udp6", os.Getenv("WASINET_ECHO_ADDRESS"))
+	if err != nil {
+		log.Fatalln("resolve failed", err)
+	}
+
+	pc, err := wasinet.ListenPacket(context.Background(), "udp6", "[::1]:0")
+	if err != nil {
+		log.Fatalln("listen packet failed", err)
+	}
+	defer pc.Close()
+
+	echo(pc, server)
+
+	conn, err := wasinet.Dial("udp6", server.String())
+	if err != nil {
+		log.Fatalln("dial failed", err)
+	}
+	defer conn.Close()
+
+	udp, ok := conn.(udpconn)
+	if !ok {
+		log.Fatalf("unexpected connection type %T\n", conn)
+	}
+
+	buf := make([]byte, 128)
+	if _, err = udp.Write([]byte("ping")); err != nil {
+		log.Fatalln("write failed", err)
+	}
+	if _, from, err := udp.ReadFromUDP(buf); err != nil || from.AddrPort() != server.AddrPort() {
+		log.Fatalln("read from udp failed", from, err)
+	}
+
+	if _, err = udp.Write([]byte("ping")); err != nil {
+		log.Fatalln("write failed", err)
+	}
+	if _, _, _, from, err := udp.ReadMsgUDP(buf, nil); err != nil || from.AddrPort() != server.AddrPort() {
+		log.Fatalln("read msg udp failed", from, err)
+	}
+
+	// link local addresses require their zone to round trip.
+	if linklocal := os.Getenv("WASINET_LINKLOCAL_ADDRESS"); linklocal != "" {
+		ap := netip.MustParseAddrPort(linklocal)
+		lpc, err := wasinet.ListenPacket(context.Background(), "udp6", netip.AddrPortFrom(ap.Addr(), 0).String())
+		if err != nil {
+			log.Fatalln("listen packet link local failed", err)
+		}
+		defer lpc.Close()
+
+		echo(lpc, net.UDPAddrFromAddrPort(ap))
+	}
+}
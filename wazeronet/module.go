@@ -4,13 +4,64 @@ package wazeronet
 
 import (
 	"context"
+	"log"
+	"sync"
 
 	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 )
 
+// isolated tracks the socket given to each guest module instance,
+// guests never observe the file descriptors of another module.
+type isolated struct {
+	wnet    wnetruntime.Socket
+	m       sync.Mutex
+	modules map[api.Module]*wnetruntime.Isolated
+}
+
+func (t *isolated) socket(ctx context.Context, m api.Module) wnetruntime.Socket {
+	if n, ok := ctx.Value(notifierkey{}).(*notifier); ok {
+		n.track(t)
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if s, ok := t.modules[m]; ok {
+		return s
+	}
+
+	// guests instantiated without a close notifier are released
+	// the next time a module is registered.
+	t.sweep(ctx)
+
+	s := wnetruntime.Isolate(t.wnet)
+	t.modules[m] = s
+	return s
+}
+
+// sweep closes the sockets of modules that have been closed, must be called while holding the lock.
+func (t *isolated) sweep(ctx context.Context) {
+	for m, s := range t.modules {
+		if !m.IsClosed() {
+			continue
+		}
+
+		delete(t.modules, m)
+		if err := s.CloseAll(ctx); err != nil {
+			log.Println("unable to close module sockets", m.Name(), err)
+		}
+	}
+}
+
+// Module builds the wasinet host module, every guest module instance that imports it
+// is given its own descriptor table, see wnetruntime.Isolate. the sockets of a guest
+// are closed when the guest module closes provided it was instantiated with a context
+// from WithCloseNotifier, otherwise they are released lazily when the next guest registers.
 func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBuilder {
+	sockets := &isolated{wnet: wnet, modules: make(map[api.Module]*wnetruntime.Isolated)}
+
 	return runtime.NewHostModuleBuilder(wnetruntime.Namespace).
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -27,7 +78,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		proto int32,
 		fdptr uint32,
 	) uint32 {
-		errno := uint32(wnetruntime.SocketOpen(wnet.Open)(ctx, Memory(m.Memory()), af, socktype, proto, uintptr(fdptr)))
+		errno := uint32(wnetruntime.SocketOpen(sockets.socket(ctx, m).Open)(ctx, Memory(m.Memory()), af, socktype, proto, uintptr(fdptr)))
 		return errno
 	}).Export("sock_open").
 		NewFunctionBuilder().WithFunc(func(
@@ -35,7 +86,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		m api.Module,
 		fd uint32, addr uint32, addrlen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketBind(wnet.Bind)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
+		return uint32(wnetruntime.SocketBind(sockets.socket(ctx, m).Bind)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
 	}).Export("sock_bind").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -44,7 +95,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		addr uint32,
 		addrlen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketConnect(wnet.Connect)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
+		return uint32(wnetruntime.SocketConnect(sockets.socket(ctx, m).Connect)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
 	}).Export("sock_connect").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -52,7 +103,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		fd int32,
 		backlog int32,
 	) uint32 {
-		return uint32(wnetruntime.SocketListen(wnet.Listen)(ctx, Memory(m.Memory()), fd, backlog))
+		return uint32(wnetruntime.SocketListen(sockets.socket(ctx, m).Listen)(ctx, Memory(m.Memory()), fd, backlog))
 	}).Export("sock_listen").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -63,7 +114,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		valueptr uint32,
 		valuelen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketGetOpt(wnet.GetSocketOption)(ctx, Memory(m.Memory()), fd, level, name, uintptr(valueptr), valuelen))
+		return uint32(wnetruntime.SocketGetOpt(sockets.socket(ctx, m).GetSocketOption)(ctx, Memory(m.Memory()), fd, level, name, uintptr(valueptr), valuelen))
 	}).Export("sock_getsockopt").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -74,7 +125,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		valueptr uint32,
 		valuelen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketSetOpt(wnet.SetSocketOption)(ctx, Memory(m.Memory()), fd, level, name, uintptr(valueptr), valuelen))
+		return uint32(wnetruntime.SocketSetOpt(sockets.socket(ctx, m).SetSocketOption)(ctx, Memory(m.Memory()), fd, level, name, uintptr(valueptr), valuelen))
 	}).Export("sock_setsockopt").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -83,7 +134,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		addr uint32,
 		addrlen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketLocalAddr(wnet.LocalAddr)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
+		return uint32(wnetruntime.SocketLocalAddr(sockets.socket(ctx, m).LocalAddr)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
 	}).Export("sock_getlocaladdr").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -92,7 +143,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		addr uint32,
 		addrlen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketPeerAddr(wnet.PeerAddr)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
+		return uint32(wnetruntime.SocketPeerAddr(sockets.socket(ctx, m).PeerAddr)(ctx, Memory(m.Memory()), fd, uintptr(addr), addrlen))
 	}).Export("sock_getpeeraddr").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -102,8 +153,19 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		ipres uint32, maxipresLen uint32,
 		ipreslen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketAddrIP(wnet.AddrIP)(ctx, Memory(m.Memory()), uintptr(networkptr), networklen, uintptr(addressptr), addresslen, uintptr(ipres), maxipresLen, uintptr(ipreslen)))
+		return uint32(wnetruntime.SocketAddrIP(sockets.socket(ctx, m).AddrIP)(ctx, Memory(m.Memory()), uintptr(networkptr), networklen, uintptr(addressptr), addresslen, uintptr(ipres), maxipresLen, uintptr(ipreslen)))
 	}).Export("sock_getaddrip").
+		NewFunctionBuilder().WithFunc(func(
+		ctx context.Context,
+		m api.Module,
+		deadline int64,
+		networkptr uint32, networklen uint32,
+		addressptr uint32, addresslen uint32,
+		bufptr uint32, buflen uint32,
+		rescount uint32,
+	) uint32 {
+		return uint32(wnetruntime.SocketLookupIP(sockets.socket(ctx, m).LookupIP)(ctx, Memory(m.Memory()), deadline, uintptr(networkptr), networklen, uintptr(addressptr), addresslen, uintptr(bufptr), buflen, uintptr(rescount)))
+	}).Export("sock_lookup_ip").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
 		m api.Module,
@@ -111,8 +173,35 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		serviceptr uint32, servicelen uint32,
 		portptr uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketAddrPort(wnet.AddrPort)(ctx, Memory(m.Memory()), uintptr(networkptr), networklen, uintptr(serviceptr), servicelen, uintptr(portptr)))
+		return uint32(wnetruntime.SocketAddrPort(sockets.socket(ctx, m).AddrPort)(ctx, Memory(m.Memory()), uintptr(networkptr), networklen, uintptr(serviceptr), servicelen, uintptr(portptr)))
 	}).Export("sock_getaddrport").
+		NewFunctionBuilder().WithFunc(func(
+		ctx context.Context,
+		m api.Module,
+		bufptr uint32, buflen uint32,
+		reslen uint32,
+	) uint32 {
+		return uint32(wnetruntime.SocketTrustBundle(sockets.socket(ctx, m).TrustBundle)(ctx, Memory(m.Memory()), uintptr(bufptr), buflen, uintptr(reslen)))
+	}).Export("tls_trust_bundle").
+		NewFunctionBuilder().WithFunc(func(
+		ctx context.Context,
+		m api.Module,
+		bufptr uint32, buflen uint32,
+		reslen uint32,
+	) uint32 {
+		return uint32(wnetruntime.SocketInterfaces(sockets.socket(ctx, m).Interfaces)(ctx, Memory(m.Memory()), uintptr(bufptr), buflen, uintptr(reslen)))
+	}).Export("sock_interfaces").
+		NewFunctionBuilder().WithFunc(func(
+		ctx context.Context,
+		m api.Module,
+		deadline int64,
+		rtype uint32,
+		nameptr uint32, namelen uint32,
+		bufptr uint32, buflen uint32,
+		reslen uint32,
+	) uint32 {
+		return uint32(wnetruntime.SocketLookupRecords(sockets.socket(ctx, m).LookupRecords)(ctx, Memory(m.Memory()), deadline, rtype, uintptr(nameptr), namelen, uintptr(bufptr), buflen, uintptr(reslen)))
+	}).Export("dns_lookup").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
 		m api.Module,
@@ -124,7 +213,7 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		nreadptr uint32,
 		oflagsptr uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketRecvFrom(wnet.RecvFrom)(ctx, Memory(m.Memory()), fd, uintptr(iovs), iovslen, uintptr(oobptr), ooblen, uintptr(addrptr), addrlen, iflags, uintptr(nreadptr), uintptr(oflagsptr)))
+		return uint32(wnetruntime.SocketRecvFrom(sockets.socket(ctx, m).RecvFrom)(ctx, Memory(m.Memory()), fd, uintptr(iovs), iovslen, uintptr(oobptr), ooblen, uintptr(addrptr), addrlen, iflags, uintptr(nreadptr), uintptr(oflagsptr)))
 	}).Export("sock_recv_from").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context,
@@ -136,16 +225,21 @@ func Module(runtime wazero.Runtime, wnet wnetruntime.Socket) wazero.HostModuleBu
 		flags int32,
 		nwritten uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketSendTo(wnet.SendTo)(ctx, Memory(m.Memory()), fd, uintptr(iovsptr), iovslen, uintptr(oobptr), ooblen, uintptr(addrptr), addrlen, flags, uintptr(nwritten)))
+		return uint32(wnetruntime.SocketSendTo(sockets.socket(ctx, m).SendTo)(ctx, Memory(m.Memory()), fd, uintptr(iovsptr), iovslen, uintptr(oobptr), ooblen, uintptr(addrptr), addrlen, flags, uintptr(nwritten)))
 	}).Export("sock_send_to").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context, m api.Module, fd, how int32,
 	) uint32 {
-		return uint32(wnetruntime.SocketShutdown(wnet.Shutdown)(ctx, Memory(m.Memory()), fd, how))
+		return uint32(wnetruntime.SocketShutdown(sockets.socket(ctx, m).Shutdown)(ctx, Memory(m.Memory()), fd, how))
 	}).Export("sock_shutdown").
+		NewFunctionBuilder().WithFunc(func(
+		ctx context.Context, m api.Module, fd int32,
+	) uint32 {
+		return uint32(wnetruntime.SocketClose(sockets.socket(ctx, m).Close)(ctx, Memory(m.Memory()), fd))
+	}).Export("sock_close").
 		NewFunctionBuilder().WithFunc(func(
 		ctx context.Context, m api.Module, fd int32, nfd uint32, addrptr uint32, addrlen uint32,
 	) uint32 {
-		return uint32(wnetruntime.SocketAccept(wnet.Accept)(ctx, Memory(m.Memory()), fd, uintptr(nfd), uintptr(addrptr), addrlen))
+		return uint32(wnetruntime.SocketAccept(sockets.socket(ctx, m).Accept)(ctx, Memory(m.Memory()), fd, uintptr(nfd), uintptr(addrptr), addrlen))
 	}).Export("sock_accept")
 }
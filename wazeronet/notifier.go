@@ -0,0 +1,46 @@
+//go:build !wasip1
+
+package wazeronet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+type notifierkey struct{}
+
+// notifier closes the sockets of guest modules when they close.
+// wazero does not identify the module being closed, but the module
+// is already marked as closed when notified, so every host module
+// the guest has used is swept for closed modules.
+type notifier struct {
+	m        sync.Mutex
+	isolated map[*isolated]struct{}
+}
+
+func (t *notifier) track(i *isolated) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.isolated[i] = struct{}{}
+}
+
+func (t *notifier) CloseNotify(ctx context.Context, exitCode uint32) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	for i := range t.isolated {
+		i.m.Lock()
+		i.sweep(ctx)
+		i.m.Unlock()
+	}
+}
+
+// WithCloseNotifier returns a context that should be used to instantiate guest modules,
+// once a guest module closes (exit, panic, api.Module.Close) the host sockets it opened are closed.
+// this replaces any close notifier previously registered with experimental.WithCloseNotifier.
+func WithCloseNotifier(ctx context.Context) context.Context {
+	n := &notifier{isolated: make(map[*isolated]struct{})}
+	return experimental.WithCloseNotifier(context.WithValue(ctx, notifierkey{}, n), n)
+}
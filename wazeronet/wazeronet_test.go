@@ -3,17 +3,27 @@ package wazeronet_test
 import (
 	"context"
 	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/egdaemon/wasinet/wasinet/testx"
 	"github.com/egdaemon/wasinet/wasinet/wnetruntime"
+	"github.com/egdaemon/wasinet/wasinet/wnetruntime/vnet"
 	"github.com/egdaemon/wasinet/wazeronet"
 	"github.com/stretchr/testify/require"
 	"github.com/tetratelabs/wazero"
@@ -82,7 +92,7 @@ func compileAndRun(ctx context.Context, t *testing.T, path string, n wnetruntime
 	}
 	defer c.Close(ctx)
 
-	m, err := runtime.InstantiateModule(ctx, c, mcfg.WithName(path))
+	m, err := runtime.InstantiateModule(wazeronet.WithCloseNotifier(ctx), c, mcfg.WithName(path))
 	if err != nil {
 		return err
 	}
@@ -143,3 +153,654 @@ func TestUnix(t *testing.T) {
 		}))
 	})
 }
+
+func TestCloseReleasesSockets(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := li.Addr().String()
+	require.NoError(t, li.Close())
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example3", "main.go"), wnetruntime.Unrestricted(), func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_LISTEN_ADDRESS", addr)
+	}))
+
+	// the guest exited without closing its listener.
+	li, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	require.NoError(t, li.Close())
+}
+
+// closecounter counts the sockets released by the guest.
+type closecounter struct {
+	wnetruntime.Socket
+	closed atomic.Int32
+}
+
+func (t *closecounter) Close(ctx context.Context, fd int) error {
+	t.closed.Add(1)
+	return t.Socket.Close(ctx, fd)
+}
+
+func TestConnCloseReleasesSocket(t *testing.T) {
+	const connections = 5
+
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	go func() {
+		for conn, err := li.Accept(); err == nil; conn, err = li.Accept() {
+			conn.Close()
+		}
+	}()
+
+	sync, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer sync.Close()
+
+	n := &closecounter{Socket: wnetruntime.Unrestricted()}
+	closed := make(chan int32, 1)
+	go func() {
+		conn, err := sync.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		closed <- n.closed.Load()
+	}()
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example17", "main.go"), n, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_CLOSE_COUNT", strconv.Itoa(connections)).
+			WithEnv("WASINET_CLOSE_ADDRESS", li.Addr().String()).
+			WithEnv("WASINET_CLOSE_SYNC", sync.Addr().String())
+	}))
+
+	// the sockets were released by the guest, not when the module exited.
+	require.Equal(t, int32(connections), <-closed)
+}
+
+func TestVirtualNetwork(t *testing.T) {
+	n := vnet.New()
+	checkVirtualNetwork(t, n, n.Socket())
+}
+
+func TestFaultyNetwork(t *testing.T) {
+	n := vnet.New()
+	checkVirtualNetwork(t, n, wnetruntime.Faulty(
+		n.Socket(),
+		wnetruntime.OptionFaultSeed(1),
+		wnetruntime.OptionFaultLatency(time.Millisecond, 5*time.Millisecond),
+		wnetruntime.OptionFaultShortWrite(0.5),
+	))
+}
+
+func checkVirtualNetwork(t *testing.T, n *vnet.Network, s wnetruntime.Socket) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := n.Listen("tcp", "10.0.0.1:80")
+	require.NoError(t, err)
+	defer li.Close()
+
+	go func() {
+		for conn, err := li.Accept(); err == nil; conn, err = li.Accept() {
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	pc, err := n.ListenPacket("udp", "10.0.0.1:53")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			nread, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if _, err = pc.WriteTo(buf[:nread], from); err != nil {
+				return
+			}
+		}
+	}()
+
+	// the guest finishes by echoing a single connection.
+	served := make(chan error, 1)
+	go func() {
+		served <- func() error {
+			var (
+				err  error
+				conn net.Conn
+			)
+
+			for conn, err = n.Dial("tcp", "10.0.0.2:8080"); errors.Is(err, syscall.ECONNREFUSED); conn, err = n.Dial("tcp", "10.0.0.2:8080") {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			if _, err = conn.Write([]byte("hello guest")); err != nil {
+				return err
+			}
+
+			buf := make([]byte, len("hello guest"))
+			if _, err = io.ReadFull(conn, buf); err != nil {
+				return err
+			}
+
+			if string(buf) != "hello guest" {
+				return fmt.Errorf("unexpected response: %s", buf)
+			}
+
+			return nil
+		}()
+	}()
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example4", "main.go"), s, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv(
+			"WASINET_TCP_ADDRESS", "10.0.0.1:80",
+		).WithEnv(
+			"WASINET_UDP_ADDRESS", "10.0.0.1:53",
+		).WithEnv(
+			"WASINET_LISTEN_ADDRESS", "10.0.0.2:8080",
+		)
+	}))
+	require.NoError(t, <-served)
+}
+
+func TestDNSPolicy(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	n := wnetruntime.Unrestricted(
+		wnetruntime.OptionHosts(map[string][]net.IP{"service.internal": {net.IPv4(127, 0, 0, 1)}}),
+		wnetruntime.OptionDNSDeny("*.denied.internal"),
+	)
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example5", "main.go"), n, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv(
+			"WASINET_PINNED_ADDRESS", fmt.Sprintf("service.internal:%d", li.Addr().(*net.TCPAddr).Port),
+		).WithEnv(
+			"WASINET_DENIED_ADDRESS", "www.denied.internal:80",
+		)
+	}))
+}
+
+func TestTrustBundle(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	env := func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_HTTPS_URL", srv.URL)
+	}
+
+	trusted := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example6", "main.go"), wnetruntime.Unrestricted(wnetruntime.OptionTrustBundle(trusted)), env))
+
+	// the test server's certificate isnt trusted by the host system.
+	require.Error(t, compileAndRun(ctx, t, testx.Fixture("example6", "main.go"), wnetruntime.Unrestricted(), env))
+}
+
+func TestHijackWithoutTrustBundle(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	imports := func(fixture string) (names []string) {
+		compiled := filepath.Join(t.TempDir(), "main.wasm")
+		require.NoError(t, compile(ctx, testx.Fixture(fixture, "main.go"), compiled))
+		wasi, err := os.ReadFile(compiled)
+		require.NoError(t, err)
+
+		runtime := wazero.NewRuntime(ctx)
+		defer runtime.Close(ctx)
+
+		c, err := runtime.CompileModule(ctx, wasi)
+		require.NoError(t, err)
+		for _, fn := range c.ImportedFunctions() {
+			_, name, _ := fn.Import()
+			names = append(names, name)
+		}
+
+		return names
+	}
+
+	// hosts without the trust bundle can run guests that don't opt in.
+	require.NotContains(t, imports("example2"), "tls_trust_bundle")
+	require.Contains(t, imports("example6"), "tls_trust_bundle")
+}
+
+func TestResolver(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	n := wnetruntime.Unrestricted(
+		wnetruntime.OptionHosts(map[string][]net.IP{"pinned.internal": {net.IPv4(10, 0, 0, 1)}}),
+		wnetruntime.OptionResolver(testx.Nameserver(map[string][]testx.DNSRecord{
+			"example.internal.": {
+				testx.DNSMX(10, "mail.example.internal."),
+				testx.DNSTXT("v=spf1 -all"),
+				testx.DNSName(testx.DNSTypeNS, "ns.example.internal."),
+			},
+			"_http._tcp.example.internal.": {testx.DNSSRV(1, 5, 8080, "www.example.internal.")},
+			"example.denied.internal.":     {testx.DNSTXT("hidden")},
+		})),
+		wnetruntime.OptionDNSDeny("*.denied.internal"),
+	)
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example12", "main.go"), n, func(mc wazero.ModuleConfig) wazero.ModuleConfig { return mc }))
+}
+
+func TestLookupIPUnbounded(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	ips := make([]net.IP, 0, 41)
+	for i := range 40 {
+		ips = append(ips, net.IPv4(10, 0, 1, byte(i)))
+	}
+	ips = append(ips, net.IPv6loopback)
+
+	n := wnetruntime.Unrestricted(
+		wnetruntime.OptionHosts(map[string][]net.IP{"headless.internal": ips}),
+		wnetruntime.OptionDNSCacheLifetime(30*time.Second),
+	)
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example13", "main.go"), n, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_LOOKUP_NAME", "headless.internal").
+			WithEnv("WASINET_LOOKUP_COUNT", strconv.Itoa(len(ips))).
+			WithEnv("WASINET_LOOKUP_TTL", "30s")
+	}))
+}
+
+func TestLookupIPErrors(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	nxdomain := wnetruntime.Unrestricted(wnetruntime.OptionResolver(testx.Nameserver(nil)))
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example14", "main.go"), nxdomain, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_LOOKUP_NAME", "missing.internal").
+			WithEnv("WASINET_LOOKUP_ERROR", "notfound")
+	}))
+
+	unresponsive := wnetruntime.Unrestricted(wnetruntime.OptionResolver(&net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: os.ErrDeadlineExceeded}
+		},
+	}))
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example14", "main.go"), unresponsive, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_LOOKUP_NAME", "slow.internal").
+			WithEnv("WASINET_LOOKUP_ERROR", "timeout")
+	}))
+}
+
+func TestDNSCache(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	nameserver := testx.Nameserver(map[string][]testx.DNSRecord{
+		"cached.internal.": {testx.DNSA(net.IPv4(10, 0, 0, 1))},
+	})
+	dialed := atomic.Int32{}
+	dial := nameserver.Dial
+	nameserver.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed.Add(1)
+		return dial(ctx, network, address)
+	}
+
+	n := wnetruntime.Unrestricted(
+		wnetruntime.OptionResolver(nameserver),
+		wnetruntime.OptionDNSCacheLifetime(time.Minute),
+	)
+
+	_, err := n.LookupIP(ctx, "ip4", "cached.internal")
+	require.NoError(t, err)
+	single := dialed.Swap(0)
+	require.Positive(t, single)
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example15", "main.go"), n, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_LOOKUP_NAME", "cached.internal:80")
+	}))
+	require.Equal(t, 2*single, dialed.Load(), "expected a single lookup before and after the cache was flushed")
+}
+
+func TestLookupDeadline(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	// the nameserver never answers, lookups only end when their context does.
+	n := wnetruntime.Unrestricted(wnetruntime.OptionResolver(&net.Resolver{
+		PreferGo: true,
+		Dial: func(dctx context.Context, network, address string) (net.Conn, error) {
+			select {
+			case <-dctx.Done():
+				return nil, &net.OpError{Op: "dial", Net: network, Err: os.ErrDeadlineExceeded}
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+	}))
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example16", "main.go"), n, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_DIAL_ADDRESS", "unresponsive.internal:80").
+			WithEnv("WASINET_DIAL_TIMEOUT", "250ms")
+	}))
+}
+
+// blackhole listens on the ip6 loopback with a full accept queue, the kernel
+// drops additional connection attempts leaving them pending until they time out.
+func blackhole(t testing.TB) int {
+	fd, err := syscall.Socket(syscall.AF_INET6, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { syscall.Close(fd) })
+
+	require.NoError(t, syscall.Bind(fd, &syscall.SockaddrInet6{Addr: [16]byte(net.IPv6loopback)}))
+	require.NoError(t, syscall.Listen(fd, 0))
+
+	sa, err := syscall.Getsockname(fd)
+	require.NoError(t, err)
+	port := sa.(*syscall.SockaddrInet6).Port
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("[::1]:%d", port), time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return port
+}
+
+func TestDualStackFallback(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	port := blackhole(t)
+	li, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer li.Close()
+
+	go func() {
+		for conn, err := li.Accept(); err == nil; conn, err = li.Accept() {
+			conn.Close()
+		}
+	}()
+
+	n := wnetruntime.Unrestricted(
+		wnetruntime.OptionHosts(map[string][]net.IP{"dualstack.internal": {net.IPv6loopback, net.IPv4(127, 0, 0, 1)}}),
+	)
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example7", "main.go"), n, func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_DUALSTACK_ADDRESS", fmt.Sprintf("dualstack.internal:%d", port))
+	}))
+}
+
+func TestTCPOptions(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	go func() {
+		for conn, err := li.Accept(); err == nil; conn, err = li.Accept() {
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example8", "main.go"), wnetruntime.Unrestricted(), func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_LISTEN_ADDRESS", li.Addr().String())
+	}))
+}
+
+func TestListenKeepAlive(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example18", "main.go"), wnetruntime.Unrestricted(), func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc
+	}))
+}
+
+func TestWriteDeadline(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := li.Accept()
+		if err != nil {
+			return
+		}
+		// hold the connection open without reading until the guest exits.
+		accepted <- conn
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example19", "main.go"), wnetruntime.Unrestricted(), func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_SINK_ADDRESS", li.Addr().String())
+	}))
+}
+
+// udpecho replies to every datagram received on the address.
+func udpecho(t testing.TB, address string) *net.UDPConn {
+	conn, err := net.ListenPacket("udp6", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 128)
+		for n, from, err := conn.ReadFrom(buf); err == nil; n, from, err = conn.ReadFrom(buf) {
+			_, _ = conn.WriteTo(buf[:n], from)
+		}
+	}()
+
+	return conn.(*net.UDPConn)
+}
+
+// linklocal returns a link local ipv6 address of the host zoned to its interface.
+func linklocal() (netip.Addr, bool) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			if ip, ok := netip.AddrFromSlice(ipnet.IP); ok && ip.Is6() && ip.IsLinkLocalUnicast() {
+				return ip.WithZone(iface.Name), true
+			}
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+func TestUDPIPv6(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	loopback := udpecho(t, "[::1]:0")
+
+	linklocaladdr := ""
+	if ip, ok := linklocal(); ok {
+		port := udpecho(t, netip.AddrPortFrom(ip, 0).String()).LocalAddr().(*net.UDPAddr).Port
+		linklocaladdr = netip.AddrPortFrom(ip, uint16(port)).String()
+	} else {
+		t.Log("no link local address available, skipping zone round trip")
+	}
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example9", "main.go"), wnetruntime.Unrestricted(), func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_ECHO_ADDRESS", loopback.LocalAddr().String()).
+			WithEnv("WASINET_LINKLOCAL_ADDRESS", linklocaladdr)
+	}))
+}
+
+func TestInterfaces(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	interfaces := func(visible, hidden string) func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+			return mc.WithEnv("WASINET_VISIBLE_INTERFACE", visible).WithEnv("WASINET_HIDDEN_INTERFACE", hidden)
+		}
+	}
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example11", "main.go"), wnetruntime.Unrestricted(), interfaces("lo", "")))
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example11", "main.go"), vnet.New().Socket(), interfaces("lo", "eth0")))
+
+	p, err := wnetruntime.NewPolicy(wnetruntime.PolicyDocument{
+		Default: wnetruntime.ActionAllow,
+		Interfaces: []wnetruntime.InterfaceRule{
+			{Action: wnetruntime.ActionDeny, Names: []string{"lo*"}},
+		},
+	})
+	require.NoError(t, err)
+
+	// the loopback is hidden by the policy.
+	require.Error(t, compileAndRun(ctx, t, testx.Fixture("example11", "main.go"), wnetruntime.Unrestricted(wnetruntime.OptionPolicy(p)), interfaces("lo", "")))
+
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		if addrs, err := iface.Addrs(); err != nil || len(addrs) == 0 {
+			continue
+		}
+
+		require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example11", "main.go"), wnetruntime.Unrestricted(wnetruntime.OptionPolicy(p)), interfaces(iface.Name, "lo")))
+		return
+	}
+
+	t.Log("no additional interface available, skipping visibility of unhidden interfaces")
+}
+
+// multicastsender repeatedly sends datagrams to the group until the test completes.
+func multicastsender(t testing.TB, network string, laddr, group *net.UDPAddr) {
+	sender, err := net.ListenUDP(network, laddr)
+	require.NoError(t, err)
+
+	ctx, done := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		done()
+		sender.Close()
+	})
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(50 * time.Millisecond):
+				_, _ = sender.WriteTo([]byte("hello"), group)
+			}
+		}
+	}()
+}
+
+func TestMulticast(t *testing.T) {
+	ctx, done := testx.WithDeadline(t)
+	defer done()
+
+	var (
+		ifi        *net.Interface
+		ip4, ip6   net.IP
+		multicast6 string
+	)
+
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		require.NoError(t, err)
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				ifi, ip4 = &iface, ipnet.IP
+			} else if ok && ipnet.IP.IsLinkLocalUnicast() {
+				ip6 = ipnet.IP
+			}
+		}
+
+		if ifi != nil {
+			break
+		}
+		ip6 = nil
+	}
+
+	if ifi == nil {
+		t.Skip("no multicast capable interface available")
+	}
+
+	// reserve a port for the groups.
+	reserved, err := net.ListenPacket("udp", ":0")
+	require.NoError(t, err)
+	port := reserved.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, reserved.Close())
+
+	group4 := &net.UDPAddr{IP: net.IPv4(239, 255, 77, 78), Port: port}
+	multicastsender(t, "udp4", &net.UDPAddr{IP: ip4}, group4)
+
+	// link local groups require the zone, which is numeric since guests cannot resolve interface names.
+	if ip6 != nil {
+		group6 := &net.UDPAddr{IP: net.ParseIP("ff12::7778"), Port: port, Zone: ifi.Name}
+		multicastsender(t, "udp6", &net.UDPAddr{IP: ip6, Zone: ifi.Name}, group6)
+		multicast6 = net.JoinHostPort(group6.IP.String()+"%"+strconv.Itoa(ifi.Index), strconv.Itoa(group6.Port))
+	}
+
+	require.NoError(t, compileAndRun(ctx, t, testx.Fixture("example10", "main.go"), wnetruntime.Unrestricted(), func(mc wazero.ModuleConfig) wazero.ModuleConfig {
+		return mc.WithEnv("WASINET_MULTICAST_IFINDEX", strconv.Itoa(ifi.Index)).
+			WithEnv("WASINET_MULTICAST4_ADDRESS", group4.String()).
+			WithEnv("WASINET_MULTICAST6_ADDRESS", multicast6)
+	}))
+}